@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/user/media-manager/internal/config"
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/internal/preview"
+)
+
+const cacheUsage = `usage: media-manager cache <command>
+
+commands:
+  stats   show the size of the preview cache and how much of it is orphaned
+  prune   remove orphaned previews, then the least recently used ones until
+          the cache fits PreviewCacheMaxMB
+  verify  remove unreadable previews and forget previews missing on disk`
+
+var errCacheUsage = errors.New(cacheUsage)
+
+// runCache implements the "cache" subcommand, which maintains the preview
+// cache without starting the user interface.
+func runCache(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errCacheUsage
+	}
+	command := args[0]
+	if command != "stats" && command != "prune" && command != "verify" {
+		return errCacheUsage
+	}
+
+	cfg, err := config.LoadConfig("")
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	database, err := db.NewDatabase(cfg.DatabasePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer database.Close()
+
+	files, err := database.GetPreviewSources()
+	if err != nil {
+		return fmt.Errorf("failed to load media files: %w", err)
+	}
+	live := preview.LiveCacheKeys(files)
+	manager := preview.NewCacheManager(cfg.ThumbnailDir, cfg.PreviewCacheMaxBytes())
+
+	switch command {
+	case "stats":
+		stats, err := manager.Stats(live)
+		if err != nil {
+			return err
+		}
+		limit := "unlimited"
+		if stats.MaxBytes > 0 {
+			limit = formatSize(stats.MaxBytes)
+		}
+		fmt.Fprintf(out, "directory: %s\n", cfg.ThumbnailDir)
+		fmt.Fprintf(out, "previews:  %d (%s of %s)\n", stats.Files, formatSize(stats.Bytes), limit)
+		fmt.Fprintf(out, "orphans:   %d (%s)\n", stats.Orphans, formatSize(stats.OrphanBytes))
+		if !stats.Oldest.IsZero() {
+			fmt.Fprintf(out, "oldest:    last used %s\n", stats.Oldest.Format("2006-01-02 15:04"))
+		}
+	case "prune":
+		result, err := manager.Prune(live)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "removed %d previews, freed %s\n", result.Removed, formatSize(result.Freed))
+	case "verify":
+		broken, err := manager.Verify()
+		if err != nil {
+			return err
+		}
+		removed := manager.Remove(broken)
+		var missing []string
+		for _, file := range files {
+			if file.PreviewPath == "" {
+				continue
+			}
+			if _, err := os.Stat(file.PreviewPath); os.IsNotExist(err) {
+				missing = append(missing, file.PreviewPath)
+			}
+		}
+		forgotten, err := database.ClearPreviewPaths(missing)
+		if err != nil {
+			return fmt.Errorf("failed to clear missing previews: %w", err)
+		}
+		fmt.Fprintf(out, "removed %d unreadable previews, forgot %d missing ones\n", removed.Removed, forgotten)
+	}
+	return nil
+}
+
+// formatSize renders a byte count for display, e.g. "1.5 MB".
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
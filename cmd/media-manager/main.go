@@ -2,17 +2,25 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"strings"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/user/media-manager/internal/app"
 	"github.com/user/media-manager/internal/config"
 	"github.com/user/media-manager/internal/db"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		if err := runCache(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Check for dev-reset flag
 	resetAll := false
 	for _, arg := range os.Args[1:] {
@@ -108,42 +116,7 @@ func runApp(dir string) {
 	if err != nil {
 		log.Fatalf("Failed to create application!: %v", err)
 	}
+	// Run blocks until the window is closed; the app's scanner watches the
+	// media directory for changes while it is open
 	application.Run()
-
-	// Setup file watcher
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer watcher.Close()
-
-	go func() {
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					log.Println("Watcher events channel closed.")
-					return
-				}
-				log.Printf("FSNotify Event: Name=%s, Op=%s", event.Name, event.Op)
-				if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) || event.Has(fsnotify.Write) {
-					log.Printf("Detected relevant file system change for %s, triggering rescan.", event.Name)
-					application.RescanMediaDirectory()
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				log.Println("error:", err)
-			}
-		}
-	}()
-
-	err = watcher.Add(dir)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Keep main goroutine alive
-	<-make(chan struct{})
 }
@@ -7,6 +7,7 @@ require (
 	fyne.io/x/fyne v0.0.0-20250418202416-58a230ad1acb
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	golang.org/x/image v0.24.0
 	gorm.io/driver/sqlite v1.5.7
 	gorm.io/gorm v1.25.12
 
@@ -40,7 +41,6 @@ require (
 	github.com/srwiley/rasterx v0.0.0-20220730225603-2ab79fcdd4ef // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/yuin/goldmark v1.7.8 // indirect
-	golang.org/x/image v0.24.0 // indirect
 	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
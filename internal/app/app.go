@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
@@ -24,8 +27,17 @@ type MediaManagerApp struct {
 	mainView *views.MainView
 	mediaDir string
 	scanner  *scanner.MediaScanner
+	previews *preview.Queue
+
+	evictMu    sync.Mutex
+	evictTimer *time.Timer // pending eviction, nil if none
+	lastEvict  time.Time
 }
 
+// cacheEvictInterval is the least time between two evictions from the
+// preview cache while previews are generated.
+const cacheEvictInterval = time.Minute
+
 func NewMediaManagerApp(mediaDir string) (*MediaManagerApp, error) {
 	// Check CLEAR_DB_ON_START env var
 	clearDB := os.Getenv("CLEAR_DB_ON_START") == "true"
@@ -49,10 +61,20 @@ func NewMediaManagerApp(mediaDir string) (*MediaManagerApp, error) {
 
 	fmt.Printf("[DEBUG] app.go: Received mediaDir: %s\n", mediaDir)
 
+	models.MediaTypes.Configure(cfg.MediaExtensions, cfg.IgnoredExtensions)
+
+	// Locate ffmpeg and ffprobe once; without them videos get no previews
+	preview.SetToolPaths(cfg.FFmpegPath, cfg.FFprobePath)
+	if warning := preview.Tools().Warning(); warning != "" {
+		fmt.Printf("[WARN] %s\n", warning)
+	}
+
 	mediaScanner, err := scanner.NewMediaScanner(database)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create media scanner: %w", err)
 	}
+	mediaScanner.SetDebounce(time.Duration(cfg.WatchDebounceMs) * time.Millisecond)
+	mediaScanner.SetFullHashing(cfg.FullContentHash)
 
 	fyneApp := app.NewWithID("com.mediamanager.app")
 
@@ -86,6 +108,7 @@ func NewMediaManagerApp(mediaDir string) (*MediaManagerApp, error) {
 		db:       database,
 		mediaDir: mediaDir,
 		scanner:  mediaScanner,
+		previews: preview.NewQueue(cfg.PreviewWorkers),
 	}, nil
 }
 
@@ -94,55 +117,137 @@ func (app *MediaManagerApp) Run() {
 
 	// Initial scan of the media directory
 	fmt.Printf("[DEBUG] app.go: Starting initial scan of %s\n", app.mediaDir)
-	err := app.scanner.ScanDirectory(app.mediaDir)
+	summary, err := app.scanner.ScanDirectory(app.mediaDir)
 	if err != nil {
 		fmt.Printf("Error during initial scan: %v\n", err)
+	} else {
+		fmt.Printf("[DEBUG] app.go: Initial scan summary: %s\n", summary)
 	}
 
-	// Rebuild missing animated previews for videos
+	// Queue previews that are missing; they are generated in the background
 	app.RebuildMissingPreviews()
 
-	// Start watching the media directory for changes
+	// Keep the preview cache within its limit, dropping previews of files
+	// the scan found deleted
+	go app.pruneCache()
+
+	// Start watching the media directory (and all subdirectories) for changes
 	fmt.Printf("[DEBUG] app.go: Starting file watcher for %s\n", app.mediaDir)
+	app.scanner.SetOnChange(func() {
+		fyne.Do(app.mainView.LibraryChanged)
+	})
 	err = app.scanner.StartWatching([]string{app.mediaDir})
 	if err != nil {
 		fmt.Printf("Error starting file watcher: %v\n", err)
 	}
 
 	app.window.ShowAndRun()
+
+	if err := app.scanner.Close(); err != nil {
+		fmt.Printf("Error closing file watcher: %v\n", err)
+	}
+	app.previews.Close()
 }
 
-// RebuildMissingPreviews regenerates animated previews for videos with empty PreviewPath
+// RebuildMissingPreviews queues previews, and with them perceptual hashes,
+// for files that lack either. The jobs run at background priority, behind
+// previews of visible cards, and the grid is refreshed once they are all done.
 func (app *MediaManagerApp) RebuildMissingPreviews() {
-	fmt.Println("[DEBUG] Rebuilding missing animated previews...")
-	var videos []models.MediaFile
-	db := app.db.GetDB()
-	db.Where("file_type = ? AND (preview_path = '' OR preview_path IS NULL)", "video").Find(&videos)
-	fmt.Printf("[DEBUG] Found %d videos with missing previews.\n", len(videos))
-	if len(videos) == 0 {
-		fmt.Println("[DEBUG] No missing previews to rebuild.")
+	fmt.Println("[DEBUG] Queueing missing previews...")
+	files := append(app.filesWithoutPreview("video"), app.filesWithoutPreview("image")...)
+	fmt.Printf("[DEBUG] Found %d files with missing previews.\n", len(files))
+
+	remaining := int64(len(files))
+	for _, file := range files {
+		app.mainView.RequestPreview(file, preview.PriorityBackground, func(string, error) {
+			if atomic.AddInt64(&remaining, -1) == 0 {
+				fyne.Do(app.mainView.RefreshMediaGrid)
+			}
+		})
+	}
+}
+
+// pruneCache removes orphaned previews and evicts the least recently used
+// ones while the cache exceeds its size limit.
+func (app *MediaManagerApp) pruneCache() {
+	files, err := app.db.GetPreviewSources()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to load media files for cache pruning: %v\n", err)
+		return
+	}
+	manager := preview.NewCacheManager(app.config.ThumbnailDir, app.config.PreviewCacheMaxBytes())
+	result, err := manager.Prune(preview.LiveCacheKeys(files))
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to prune preview cache: %v\n", err)
+		return
+	}
+	fmt.Printf("[DEBUG] Pruned preview cache: removed %d previews, freed %d bytes\n", result.Removed, result.Freed)
+}
+
+// scheduleCacheEviction keeps the preview cache within its limit as previews
+// are generated. It is called whenever the preview queue runs dry and evicts
+// at most once per cacheEvictInterval; a call within the interval is deferred
+// to its end.
+func (app *MediaManagerApp) scheduleCacheEviction() {
+	app.evictMu.Lock()
+	defer app.evictMu.Unlock()
+	if app.evictTimer != nil {
 		return
 	}
-	for _, video := range videos {
-		if _, err := os.Stat(video.Path); os.IsNotExist(err) {
-			fmt.Printf("[WARN] File does not exist, removing DB record: %s\n", video.Path)
-			db.Delete(&video)
+	wait := cacheEvictInterval - time.Since(app.lastEvict)
+	if wait < 0 {
+		wait = 0
+	}
+	app.evictTimer = time.AfterFunc(wait, func() {
+		app.evictMu.Lock()
+		app.evictTimer = nil
+		app.lastEvict = time.Now()
+		app.evictMu.Unlock()
+
+		manager := preview.NewCacheManager(app.config.ThumbnailDir, app.config.PreviewCacheMaxBytes())
+		result, err := manager.Evict()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to evict previews from the cache: %v\n", err)
+			return
+		}
+		if result.Removed > 0 {
+			fmt.Printf("[DEBUG] Evicted %d previews, freed %d bytes\n", result.Removed, result.Freed)
+		}
+	})
+}
+
+// filesWithoutPreview returns the present files of the given type that have
+// no preview or no perceptual hash yet and whose preview backend can run.
+// Files that vanished from disk are flagged as missing, like a scan does,
+// so their tags, notes and albums are kept should they come back.
+func (app *MediaManagerApp) filesWithoutPreview(fileType string) []models.MediaFile {
+	var files []models.MediaFile
+	app.db.GetDB().Where("file_type = ? AND missing = ? AND (preview_path = '' OR preview_path IS NULL OR perceptual_hash IS NULL)", fileType, false).Find(&files)
+
+	present := files[:0]
+	var missingIDs []uint
+	for _, file := range files {
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			fmt.Printf("[WARN] File does not exist, marking it missing: %s\n", file.Path)
+			missingIDs = append(missingIDs, file.ID)
 			continue
 		}
-		gifPath := filepath.Join(app.config.ThumbnailDir, fmt.Sprintf("%d.gif", video.ID))
-		err := preview.GenerateAnimatedPreview(video.Path, gifPath)
-		if err != nil {
-			fmt.Printf("[ERROR] Failed to generate preview for %s: %v\n", video.Path, err)
+		if !preview.CanGenerate(file.Path, file.DetectedType) {
 			continue
 		}
-		video.PreviewPath = gifPath
-		db.Model(&video).Update("preview_path", gifPath)
-		fmt.Printf("[DEBUG] Rebuilt preview for %s -> %s\n", video.Path, gifPath)
+		present = append(present, file)
 	}
+	if len(missingIDs) > 0 {
+		if err := app.db.SaveScanResults(nil, nil, missingIDs); err != nil {
+			fmt.Printf("[ERROR] Failed to mark %d files missing: %v\n", len(missingIDs), err)
+		}
+	}
+	return present
 }
 
 func (app *MediaManagerApp) setupUI() {
 	mainView := views.NewMainView(app.config, app.db, app.window, app.mediaDir)
+	mainView.SetPreviewQueue(app.previews, app.scheduleCacheEviction)
 	app.mainView = mainView
 
 	// Create menu bar
@@ -172,10 +277,21 @@ func (app *MediaManagerApp) setupMenuBar() {
 			app.RescanMediaDirectory()
 		}),
 		fyne.NewMenuItemSeparator(),
-		fyne.NewMenuItem("Small Thumbnails", nil),
-		fyne.NewMenuItem("Medium Thumbnails", nil),
-		fyne.NewMenuItem("Large Thumbnails", nil),
 	)
+	for _, size := range []struct {
+		label  string
+		pixels int
+	}{
+		{"Small Thumbnails", preview.ThumbnailSmall},
+		{"Medium Thumbnails", preview.ThumbnailMedium},
+		{"Large Thumbnails", preview.ThumbnailLarge},
+	} {
+		item := fyne.NewMenuItem(size.label, func() {
+			app.setThumbnailSize(size.pixels)
+		})
+		item.Checked = app.config.ThumbnailSize == size.pixels
+		viewMenu.Items = append(viewMenu.Items, item)
+	}
 
 	helpMenu := fyne.NewMenu("Help",
 		fyne.NewMenuItem("About", func() {
@@ -187,20 +303,29 @@ func (app *MediaManagerApp) setupMenuBar() {
 	app.window.SetMainMenu(mainMenu)
 }
 
-func (app *MediaManagerApp) RescanMediaDirectory() {
-	fmt.Println("[DEBUG] app.go: Rescanning media directory...")
-	// Clear existing media files from the database for the current directory
-	err := app.db.DeleteMediaFilesByDirectory(app.mediaDir)
-	if err != nil {
-		fmt.Printf("Error clearing media files for rescan: %v\n", err)
+// setThumbnailSize switches the grid to another thumbnail size, checks its
+// item in the View menu and saves it as the new default.
+func (app *MediaManagerApp) setThumbnailSize(size int) {
+	app.mainView.SetThumbnailSize(size)
+	// Rebuilding the menu moves the check mark
+	app.setupMenuBar()
+
+	if err := config.SaveConfig(app.config); err != nil {
+		fmt.Printf("[ERROR] Failed to save thumbnail size: %v\n", err)
 	}
+}
 
-	// Re-scan the directory
-	err = app.scanner.ScanDirectory(app.mediaDir)
+func (app *MediaManagerApp) RescanMediaDirectory() {
+	fmt.Println("[DEBUG] app.go: Rescanning media directory...")
+	// Incremental scan: existing records (and their tags) are kept and only
+	// added, changed or vanished files touch the database
+	summary, err := app.scanner.ScanDirectory(app.mediaDir)
 	if err != nil {
 		fmt.Printf("Error during rescan: %v\n", err)
+	} else {
+		fmt.Printf("[DEBUG] app.go: Rescan summary: %s\n", summary)
 	}
-	app.mainView.RefreshMediaGrid()
+	app.mainView.LibraryChanged()
 	fmt.Println("[DEBUG] app.go: RescanMediaDirectory finished.")
 }
 
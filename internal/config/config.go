@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -15,12 +16,24 @@ type Config struct {
 	MediaDirs              []string
 	MainContentSplitOffset float32
 	SidebarSplitOffset     float32
-	WindowWidth            float32 // New field for window width
-	WindowHeight           float32 // New field for window height
-	WindowX                float32 // New field for window X position
-	WindowY                float32 // New field for window Y position
+	WindowWidth            float32           // New field for window width
+	WindowHeight           float32           // New field for window height
+	WindowX                float32           // New field for window X position
+	WindowY                float32           // New field for window Y position
+	WatchDebounceMs        int               // Quiet period before file watcher events for a path are applied
+	FullContentHash        bool              // Compute the full SHA-256 of every scanned file, not only of duplicate candidates
+	PreviewWorkers         int               // Concurrent preview generation jobs; 0 means one per CPU
+	PreviewCacheMaxMB      int               // Size limit of the thumbnail directory in MiB; 0 means unlimited
+	FFmpegPath             string            // ffmpeg executable; empty means "ffmpeg" on the PATH
+	FFprobePath            string            // ffprobe executable; empty means "ffprobe" on the PATH
+	MediaExtensions        map[string]string // extra extensions, e.g. ".jfif": "image/jpeg"
+	IgnoredExtensions      []string          // extensions left out of the library even if known
+	RecentSearches         []string          // last filter box queries, most recent first
 }
 
+// MaxRecentSearches is how many filter box queries are remembered.
+const MaxRecentSearches = 10
+
 func (c *Config) GetThumbnailDir() string {
 	return c.ThumbnailDir
 }
@@ -29,6 +42,28 @@ func (c *Config) GetThumbnailSize() int {
 	return c.ThumbnailSize
 }
 
+// PreviewCacheMaxBytes returns the size limit of the thumbnail directory in
+// bytes, 0 meaning unlimited.
+func (c *Config) PreviewCacheMaxBytes() int64 {
+	return int64(c.PreviewCacheMaxMB) << 20
+}
+
+// AddRecentSearch remembers query as the most recent search, dropping an
+// earlier copy and the oldest searches beyond MaxRecentSearches.
+func (c *Config) AddRecentSearch(query string) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return
+	}
+	recent := []string{query}
+	for _, previous := range c.RecentSearches {
+		if previous != query && len(recent) < MaxRecentSearches {
+			recent = append(recent, previous)
+		}
+	}
+	c.RecentSearches = recent
+}
+
 func NewConfig(mediaDir string) *Config {
 	homeDir, _ := os.UserHomeDir()
 	fmt.Printf("[DEBUG] config.go: Received mediaDir: %s\n", mediaDir)
@@ -41,7 +76,7 @@ func NewConfig(mediaDir string) *Config {
 	cfg := &Config{
 		DatabasePath:           filepath.Join(configDir, "media.db"),
 		ThumbnailDir:           filepath.Join(configDir, "thumbnails"),
-		ThumbnailSize:          300,
+		ThumbnailSize:          180,
 		MediaDirs:              []string{mediaDir},
 		MainContentSplitOffset: 0.25,
 		SidebarSplitOffset:     0.95,
@@ -49,10 +84,19 @@ func NewConfig(mediaDir string) *Config {
 		WindowHeight:           0, // Initialize with 0, meaning no saved size
 		WindowX:                0, // Initialize with 0, meaning no saved position
 		WindowY:                0, // Initialize with 0, meaning no saved position
+		WatchDebounceMs:        500,
+		PreviewCacheMaxMB:      1024,
 	}
 	fmt.Printf("[DEBUG] config.go: Config.MediaDirs: %v\n", cfg.MediaDirs)
 
-	// Load from environment variables
+	applyEnv(cfg)
+
+	return cfg
+}
+
+// applyEnv overrides cfg with the settings given in environment variables,
+// which win over config.json.
+func applyEnv(cfg *Config) {
 	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
 		cfg.DatabasePath = dbPath
 	}
@@ -67,7 +111,31 @@ func NewConfig(mediaDir string) *Config {
 		}
 	}
 
-	return cfg
+	if debounce := os.Getenv("WATCH_DEBOUNCE_MS"); debounce != "" {
+		if ms, err := strconv.Atoi(debounce); err == nil {
+			cfg.WatchDebounceMs = ms
+		}
+	}
+
+	if workers := os.Getenv("PREVIEW_WORKERS"); workers != "" {
+		if n, err := strconv.Atoi(workers); err == nil {
+			cfg.PreviewWorkers = n
+		}
+	}
+
+	if maxMB := os.Getenv("PREVIEW_CACHE_MAX_MB"); maxMB != "" {
+		if n, err := strconv.Atoi(maxMB); err == nil {
+			cfg.PreviewCacheMaxMB = n
+		}
+	}
+
+	if ffmpeg := os.Getenv("FFMPEG_PATH"); ffmpeg != "" {
+		cfg.FFmpegPath = ffmpeg
+	}
+
+	if ffprobe := os.Getenv("FFPROBE_PATH"); ffprobe != "" {
+		cfg.FFprobePath = ffprobe
+	}
 }
 
 func GetConfigFilePath() (string, error) {
@@ -91,7 +159,7 @@ func LoadConfig(mediaDir string) (*Config, error) {
 	cfg := &Config{
 		DatabasePath:           filepath.Join(filepath.Dir(configFilePath), "media.db"),
 		ThumbnailDir:           filepath.Join(filepath.Dir(configFilePath), "thumbnails"),
-		ThumbnailSize:          300,
+		ThumbnailSize:          180,
 		MediaDirs:              []string{mediaDir},
 		MainContentSplitOffset: 0.25,
 		SidebarSplitOffset:     0.95,
@@ -99,6 +167,8 @@ func LoadConfig(mediaDir string) (*Config, error) {
 		WindowHeight:           0, // Initialize with 0, meaning no saved size
 		WindowX:                0, // Initialize with 0, meaning no saved position
 		WindowY:                0, // Initialize with 0, meaning no saved position
+		WatchDebounceMs:        500,
+		PreviewCacheMaxMB:      1024,
 	}
 
 	data, err := os.ReadFile(configFilePath)
@@ -106,6 +176,7 @@ func LoadConfig(mediaDir string) (*Config, error) {
 		// If file doesn't exist, return default config
 		if os.IsNotExist(err) {
 			fmt.Printf("[DEBUG] config.json not found at %s, creating default config.\n", configFilePath)
+			applyEnv(cfg)
 			return cfg, nil
 		}
 		fmt.Printf("[DEBUG] Failed to read config file %s: %v\n", configFilePath, err)
@@ -125,6 +196,7 @@ func LoadConfig(mediaDir string) (*Config, error) {
 		cfg.MediaDirs = []string{mediaDir}
 		fmt.Printf("[DEBUG] MediaDirs overridden by command line argument: %v\n", cfg.MediaDirs)
 	}
+	applyEnv(cfg)
 
 	return cfg, nil
 }
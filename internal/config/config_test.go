@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useHome points the config file at a fresh home directory holding
+// configJSON, or no config file if it is empty.
+func useHome(t *testing.T, configJSON string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if configJSON == "" {
+		return
+	}
+	dir := filepath.Join(home, ".media-manager")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(configJSON), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+}
+
+func TestLoadConfigEnvOverrides(t *testing.T) {
+	for _, configJSON := range []string{"", `{"ThumbnailDir": "/from/json", "WatchDebounceMs": 100}`} {
+		useHome(t, configJSON)
+		t.Setenv("THUMBNAIL_DIR", "/from/env")
+		t.Setenv("WATCH_DEBOUNCE_MS", "250")
+		t.Setenv("PREVIEW_WORKERS", "3")
+		t.Setenv("PREVIEW_CACHE_MAX_MB", "64")
+		t.Setenv("FFMPEG_PATH", "/opt/ffmpeg/bin/ffmpeg")
+		t.Setenv("FFPROBE_PATH", "/opt/ffmpeg/bin/ffprobe")
+
+		cfg, err := LoadConfig("/media")
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if cfg.ThumbnailDir != "/from/env" || cfg.WatchDebounceMs != 250 {
+			t.Errorf("Expected the environment to win over %q, got thumbnail dir %q, debounce %d",
+				configJSON, cfg.ThumbnailDir, cfg.WatchDebounceMs)
+		}
+		if cfg.PreviewWorkers != 3 {
+			t.Errorf("Expected 3 preview workers, got %d", cfg.PreviewWorkers)
+		}
+		if cfg.PreviewCacheMaxBytes() != 64<<20 {
+			t.Errorf("Expected a 64 MiB preview cache, got %d bytes", cfg.PreviewCacheMaxBytes())
+		}
+		if cfg.FFmpegPath != "/opt/ffmpeg/bin/ffmpeg" || cfg.FFprobePath != "/opt/ffmpeg/bin/ffprobe" {
+			t.Errorf("Expected the tool paths from the environment, got %q and %q", cfg.FFmpegPath, cfg.FFprobePath)
+		}
+	}
+}
+
+func TestLoadConfigKeepsFileWithoutEnv(t *testing.T) {
+	useHome(t, `{"WatchDebounceMs": 100}`)
+	t.Setenv("WATCH_DEBOUNCE_MS", "")
+	t.Setenv("THUMBNAIL_SIZE", "not a number")
+
+	cfg, err := LoadConfig("/media")
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.WatchDebounceMs != 100 || cfg.ThumbnailSize != 180 {
+		t.Errorf("Expected the config file and defaults, got debounce %d, thumbnail size %d", cfg.WatchDebounceMs, cfg.ThumbnailSize)
+	}
+}
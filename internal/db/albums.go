@@ -0,0 +1,183 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// AlbumWithCount is an album together with the number of present files in
+// it.
+type AlbumWithCount struct {
+	models.Album
+	FileCount int64
+}
+
+// checkAlbumName validates the name of an album with the given ID (0 for a
+// new one) and returns it trimmed.
+func checkAlbumName(tx *gorm.DB, id uint, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("album name must not be empty")
+	}
+	var count int64
+	if err := tx.Model(&models.Album{}).Where("name = ? AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", fmt.Errorf("an album named %q already exists", name)
+	}
+	return name, nil
+}
+
+// CreateAlbum creates an empty album.
+func (d *Database) CreateAlbum(name string) (*models.Album, error) {
+	name, err := checkAlbumName(d.db, 0, name)
+	if err != nil {
+		return nil, err
+	}
+	album := &models.Album{Name: name}
+	if err := d.db.Create(album).Error; err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// RenameAlbum renames an album.
+func (d *Database) RenameAlbum(albumID uint, name string) error {
+	name, err := checkAlbumName(d.db, albumID, name)
+	if err != nil {
+		return err
+	}
+	return d.db.Model(&models.Album{}).Where("id = ?", albumID).Update("name", name).Error
+}
+
+// DeleteAlbum removes an album; its files stay in the library.
+func (d *Database) DeleteAlbum(albumID uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("album_id = ?", albumID).Delete(&models.AlbumItem{}).Error; err != nil {
+			return fmt.Errorf("failed to remove album items: %w", err)
+		}
+		return tx.Delete(&models.Album{}, albumID).Error
+	})
+}
+
+// GetAlbumsWithCounts returns every album sorted by name, with the number of
+// present (not missing) files in it.
+func (d *Database) GetAlbumsWithCounts() ([]AlbumWithCount, error) {
+	var albums []AlbumWithCount
+	err := d.db.Raw("SELECT albums.*, COUNT(media_files.id) AS file_count FROM albums"+
+		" LEFT JOIN album_items ON album_items.album_id = albums.id"+
+		" LEFT JOIN media_files ON media_files.id = album_items.media_file_id AND media_files.missing = ?"+
+		" GROUP BY albums.id ORDER BY albums.name COLLATE NOCASE", false).
+		Scan(&albums).Error
+	return albums, err
+}
+
+// albumFileIDs returns the IDs of the files in an album in album order.
+func albumFileIDs(tx *gorm.DB, albumID uint) ([]uint, error) {
+	var ids []uint
+	err := tx.Model(&models.AlbumItem{}).Where("album_id = ?", albumID).
+		Order("position").Order("media_file_id").Pluck("media_file_id", &ids).Error
+	return ids, err
+}
+
+// writeAlbumOrder numbers the items of an album in the order of fileIDs.
+func writeAlbumOrder(tx *gorm.DB, albumID uint, fileIDs []uint) error {
+	items := make([]models.AlbumItem, len(fileIDs))
+	for i, id := range fileIDs {
+		items[i] = models.AlbumItem{AlbumID: albumID, MediaFileID: id, Position: i}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "album_id"}, {Name: "media_file_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position"}),
+	}).CreateInBatches(items, 500).Error
+}
+
+// AddToAlbum appends files to the end of an album in the given order. Files
+// already in the album keep their place.
+func (d *Database) AddToAlbum(albumID uint, fileIDs []uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		ids, err := albumFileIDs(tx, albumID)
+		if err != nil {
+			return err
+		}
+		for _, id := range fileIDs {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		return writeAlbumOrder(tx, albumID, ids)
+	})
+}
+
+// RemoveFromAlbum takes files out of an album, closing the gaps they leave.
+func (d *Database) RemoveFromAlbum(albumID uint, fileIDs []uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("album_id = ? AND media_file_id IN ?", albumID, fileIDs).Delete(&models.AlbumItem{}).Error
+		if err != nil {
+			return err
+		}
+		ids, err := albumFileIDs(tx, albumID)
+		if err != nil {
+			return err
+		}
+		return writeAlbumOrder(tx, albumID, ids)
+	})
+}
+
+// MoveAlbumItem moves a file of an album next to another file of the album,
+// targetID: right before it, or right after it if after is set. Going by
+// file rather than by place keeps files the grid does not show, such as
+// missing ones, where they are relative to the others.
+func (d *Database) MoveAlbumItem(albumID, fileID, targetID uint, after bool) error {
+	if fileID == targetID {
+		return nil
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		ids, err := albumFileIDs(tx, albumID)
+		if err != nil {
+			return err
+		}
+		from := slices.Index(ids, fileID)
+		if from < 0 {
+			return fmt.Errorf("file %d is not in album %d", fileID, albumID)
+		}
+		ids = slices.Delete(ids, from, from+1)
+		to := slices.Index(ids, targetID)
+		if to < 0 {
+			return fmt.Errorf("file %d is not in album %d", targetID, albumID)
+		}
+		if after {
+			to++
+		}
+		ids = slices.Insert(ids, to, fileID)
+		return writeAlbumOrder(tx, albumID, ids)
+	})
+}
+
+// ReorderAlbum puts the files of an album in the order of fileIDs, which
+// must list exactly the album's files.
+func (d *Database) ReorderAlbum(albumID uint, fileIDs []uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		ids, err := albumFileIDs(tx, albumID)
+		if err != nil {
+			return err
+		}
+		sorted := slices.Clone(fileIDs)
+		slices.Sort(sorted)
+		slices.Sort(ids)
+		if !slices.Equal(sorted, ids) {
+			return fmt.Errorf("new order of album %d does not list its files", albumID)
+		}
+		return writeAlbumOrder(tx, albumID, fileIDs)
+	})
+}
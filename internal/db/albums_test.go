@@ -0,0 +1,149 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestAlbums(t *testing.T) {
+	database := newTestDatabase(t)
+	var files []models.MediaFile
+	for _, path := range []string{"/media/a.jpg", "/media/trip/b.jpg", "/other/c.mp4", "/other/d.jpg"} {
+		file := models.MediaFile{Path: path, Filename: path[len(path)-5:], FileType: "image"}
+		if err := database.CreateMediaFile(&file); err != nil {
+			t.Fatalf("Failed to insert %s: %v", path, err)
+		}
+		files = append(files, file)
+	}
+	a, b, c, d := files[0].ID, files[1].ID, files[2].ID, files[3].ID
+
+	album, err := database.CreateAlbum("Project")
+	if err != nil {
+		t.Fatalf("Failed to create album: %v", err)
+	}
+	if _, err := database.CreateAlbum(" Project "); err == nil {
+		t.Errorf("Expected a duplicate album name to be rejected")
+	}
+
+	albumOrder := func() []uint {
+		t.Helper()
+		found, err := database.QueryMediaFiles(MediaQuery{AlbumID: album.ID, Sort: SortByAlbumOrder})
+		if err != nil {
+			t.Fatalf("Failed to query album: %v", err)
+		}
+		var ids []uint
+		for _, file := range found {
+			ids = append(ids, file.ID)
+		}
+		return ids
+	}
+
+	// Files from different folders, appended in the given order; duplicates
+	// keep their place
+	if err := database.AddToAlbum(album.ID, []uint{c, a}); err != nil {
+		t.Fatalf("Failed to add to album: %v", err)
+	}
+	if err := database.AddToAlbum(album.ID, []uint{b, a, d}); err != nil {
+		t.Fatalf("Failed to add to album: %v", err)
+	}
+	if got := albumOrder(); !slices.Equal(got, []uint{c, a, b, d}) {
+		t.Errorf("Expected c, a, b, d, got %v", got)
+	}
+
+	if err := database.MoveAlbumItem(album.ID, d, c, false); err != nil {
+		t.Fatalf("Failed to move item: %v", err)
+	}
+	if err := database.MoveAlbumItem(album.ID, c, b, true); err != nil {
+		t.Fatalf("Failed to move item: %v", err)
+	}
+	if got := albumOrder(); !slices.Equal(got, []uint{d, a, b, c}) {
+		t.Errorf("Expected d, a, b, c after moving, got %v", got)
+	}
+	if err := database.ReorderAlbum(album.ID, []uint{a, b}); err == nil {
+		t.Errorf("Expected an incomplete order to be rejected")
+	}
+	if err := database.ReorderAlbum(album.ID, []uint{b, c, d, a}); err != nil {
+		t.Fatalf("Failed to reorder album: %v", err)
+	}
+
+	if err := database.RemoveFromAlbum(album.ID, []uint{c}); err != nil {
+		t.Fatalf("Failed to remove from album: %v", err)
+	}
+	if err := database.DeleteMediaFile(&files[3]); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	if got := albumOrder(); !slices.Equal(got, []uint{b, a}) {
+		t.Errorf("Expected b, a after removals, got %v", got)
+	}
+	if err := database.MoveAlbumItem(album.ID, c, a, false); err == nil {
+		t.Errorf("Expected moving a file outside the album to fail")
+	}
+	if err := database.MoveAlbumItem(album.ID, a, c, false); err == nil {
+		t.Errorf("Expected moving next to a file outside the album to fail")
+	}
+
+	// Album queries combine with other filters and the count skips missing files
+	if count, err := database.CountMediaFiles(MediaQuery{AlbumID: album.ID, Dir: "/media", Recursive: true}); err != nil || count != 2 {
+		t.Errorf("Expected 2 album files below /media, got %d (%v)", count, err)
+	}
+	if err := database.SaveScanResults(nil, nil, []uint{b}); err != nil {
+		t.Fatalf("Failed to mark file missing: %v", err)
+	}
+	if err := database.RenameAlbum(album.ID, "Client project"); err != nil {
+		t.Fatalf("Failed to rename album: %v", err)
+	}
+	albums, err := database.GetAlbumsWithCounts()
+	if err != nil || len(albums) != 1 || albums[0].Name != "Client project" || albums[0].FileCount != 1 {
+		t.Errorf("Expected the renamed album with one present file, got %+v (%v)", albums, err)
+	}
+
+	if err := database.DeleteAlbum(album.ID); err != nil {
+		t.Fatalf("Failed to delete album: %v", err)
+	}
+	if albums, _ := database.GetAlbumsWithCounts(); len(albums) != 0 {
+		t.Errorf("Expected no albums left, got %+v", albums)
+	}
+	if _, err := database.GetMediaFileByPath("/media/a.jpg"); err != nil {
+		t.Errorf("Expected files to survive their album: %v", err)
+	}
+}
+
+func TestMoveAlbumItemPastHiddenFile(t *testing.T) {
+	database := newTestDatabase(t)
+	var ids []uint
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"} {
+		file := models.MediaFile{Path: "/media/" + name, Filename: name, FileType: "image"}
+		if err := database.CreateMediaFile(&file); err != nil {
+			t.Fatalf("Failed to insert %s: %v", name, err)
+		}
+		ids = append(ids, file.ID)
+	}
+	a, b, c, d := ids[0], ids[1], ids[2], ids[3]
+	album, err := database.CreateAlbum("Trip")
+	if err != nil {
+		t.Fatalf("Failed to create album: %v", err)
+	}
+	if err := database.AddToAlbum(album.ID, ids); err != nil {
+		t.Fatalf("Failed to add to album: %v", err)
+	}
+	// b is missing, so the grid shows a, c, d
+	if err := database.SaveScanResults(nil, nil, []uint{b}); err != nil {
+		t.Fatalf("Failed to mark file missing: %v", err)
+	}
+
+	// Dragging a onto d puts it right before d, not at d's place in the grid
+	if err := database.MoveAlbumItem(album.ID, a, d, false); err != nil {
+		t.Fatalf("Failed to move item: %v", err)
+	}
+	if got, _ := albumFileIDs(database.GetDB(), album.ID); !slices.Equal(got, []uint{b, c, a, d}) {
+		t.Errorf("Expected b, c, a, d, got %v", got)
+	}
+	if err := database.MoveAlbumItem(album.ID, c, d, true); err != nil {
+		t.Fatalf("Failed to move item: %v", err)
+	}
+	if got, _ := albumFileIDs(database.GetDB(), album.ID); !slices.Equal(got, []uint{b, a, d, c}) {
+		t.Errorf("Expected b, a, d, c, got %v", got)
+	}
+}
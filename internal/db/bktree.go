@@ -0,0 +1,72 @@
+package db
+
+import "math/bits"
+
+// bkTree is a BK-tree over 64-bit perceptual hashes using the Hamming
+// distance. A lookup for all hashes within distance d of a query only has to
+// descend into children whose edge distance lies in [dist-d, dist+d], which
+// prunes most of the tree for small thresholds.
+type bkTree struct {
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     uint64
+	ids      []uint // files sharing this exact hash
+	children map[int]*bkNode
+}
+
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// add inserts the hash of the media file with the given ID.
+func (t *bkTree) add(hash uint64, id uint) {
+	t.size++
+	if t.root == nil {
+		t.root = &bkNode{hash: hash, ids: []uint{id}}
+		return
+	}
+	node := t.root
+	for {
+		dist := hammingDistance(hash, node.hash)
+		if dist == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+		child, ok := node.children[dist]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[dist] = &bkNode{hash: hash, ids: []uint{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// search calls fn for every file whose hash is within maxDistance of hash.
+func (t *bkTree) search(hash uint64, maxDistance int, fn func(id uint, distance int)) {
+	if t.root == nil {
+		return
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		dist := hammingDistance(hash, node.hash)
+		if dist <= maxDistance {
+			for _, id := range node.ids {
+				fn(id, dist)
+			}
+		}
+		for edge, child := range node.children {
+			if edge >= dist-maxDistance && edge <= dist+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+}
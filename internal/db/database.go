@@ -3,30 +3,52 @@ package db
 import (
 	"fmt"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/user/media-manager/pkg/models"
 )
 
 type Database struct {
 	db *gorm.DB
+
+	// similar is the lazily built BK-tree of perceptual hashes; nil when it
+	// has to be rebuilt
+	similarMu sync.Mutex
+	similar   *bkTree
+
+	// fts is set if the FTS5 search index is available
+	fts bool
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	// The file watcher writes from its own goroutine, so wait for locks
+	// instead of failing immediately with SQLITE_BUSY
+	db, err := gorm.Open(sqlite.Open(dbPath+"?_busy_timeout=5000"), &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Auto-migrate database schema
-	err = db.AutoMigrate(&models.MediaFile{}, &models.Tag{}, &models.Folder{})
+	err = db.AutoMigrate(&models.MediaFile{}, &models.MediaMetadata{}, &models.Tag{}, &models.Folder{}, &models.SmartAlbum{},
+		&models.Album{}, &models.AlbumItem{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if err := migrateTagNames(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate tags: %w", err)
+	}
+	fts, err := setupFullTextSearch(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up search index: %w", err)
+	}
 
-	return &Database{db: db}, nil
+	return &Database{db: db, fts: fts}, nil
 }
 
 func (d *Database) GetDB() *gorm.DB {
@@ -39,8 +61,64 @@ func (d *Database) GetMediaFiles(limit, offset int) ([]models.MediaFile, error)
 	return files, err
 }
 
+// CreateMediaFile inserts file unless a record with its path already exists.
+// Associations are not written.
 func (d *Database) CreateMediaFile(file *models.MediaFile) error {
-	return d.db.FirstOrCreate(file, models.MediaFile{Path: file.Path}).Error
+	return d.db.Omit(clause.Associations).FirstOrCreate(file, models.MediaFile{Path: file.Path}).Error
+}
+
+// GetMediaFileByPath returns the media file stored at path, or nil if there is none.
+func (d *Database) GetMediaFileByPath(path string) (*models.MediaFile, error) {
+	var files []models.MediaFile
+	if err := d.db.Where("path = ?", path).Limit(1).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return &files[0], nil
+}
+
+// userColumns hold what the user entered about a media file. Scans and
+// watched changes save records loaded earlier, so they leave these columns
+// alone rather than undo an edit made in the meantime.
+var userColumns = []string{"Notes", "Rating", "Favorite", "Flag", "ColorLabel"}
+
+// UpdateMediaFile saves the columns of an existing media file found on disk,
+// without touching its tags, metadata or userColumns.
+func (d *Database) UpdateMediaFile(file *models.MediaFile) error {
+	if err := d.db.Omit(append([]string{clause.Associations}, userColumns...)...).Save(file).Error; err != nil {
+		return err
+	}
+	d.invalidateSimilarityIndex()
+	return nil
+}
+
+// SetNotes stores the user's description of a media file.
+func (d *Database) SetNotes(fileID uint, notes string) error {
+	return d.db.Model(&models.MediaFile{}).Where("id = ?", fileID).Update("notes", notes).Error
+}
+
+// DeleteMediaFile removes a media file record together with its file_tags
+// and metadata rows.
+func (d *Database) DeleteMediaFile(file *models.MediaFile) error {
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(file).Association("Tags").Clear(); err != nil {
+			return fmt.Errorf("failed to clear tags of %s: %w", file.Path, err)
+		}
+		if err := tx.Where("media_file_id = ?", file.ID).Delete(&models.MediaMetadata{}).Error; err != nil {
+			return fmt.Errorf("failed to delete metadata of %s: %w", file.Path, err)
+		}
+		if err := tx.Where("media_file_id = ?", file.ID).Delete(&models.AlbumItem{}).Error; err != nil {
+			return fmt.Errorf("failed to remove %s from albums: %w", file.Path, err)
+		}
+		return tx.Delete(file).Error
+	})
+	if err != nil {
+		return err
+	}
+	d.invalidateSimilarityIndex()
+	return nil
 }
 
 func (d *Database) GetTags() ([]models.Tag, error) {
@@ -67,6 +145,38 @@ func (d *Database) ClearAllPreviews() error {
 	return nil
 }
 
+// SetPreview records the generated preview of a media file and the
+// perceptual hash computed with it.
+func (d *Database) SetPreview(id uint, previewPath string, hash uint64) error {
+	err := d.db.Model(&models.MediaFile{}).Where("id = ?", id).Updates(map[string]any{
+		"preview_path":    previewPath,
+		"perceptual_hash": int64(hash),
+	}).Error
+	if err != nil {
+		return err
+	}
+	d.invalidateSimilarityIndex()
+	return nil
+}
+
+// GetPreviewSources returns every media file with only the fields that
+// identify its preview: ID, path, size, modification time and preview path.
+func (d *Database) GetPreviewSources() ([]models.MediaFile, error) {
+	var files []models.MediaFile
+	err := d.db.Select("id", "path", "size", "mod_time", "preview_path").Find(&files).Error
+	return files, err
+}
+
+// ClearPreviewPaths forgets the previews at the given paths, so that they are
+// generated again, and returns how many records referred to them.
+func (d *Database) ClearPreviewPaths(paths []string) (int64, error) {
+	if len(paths) == 0 {
+		return 0, nil
+	}
+	result := d.db.Model(&models.MediaFile{}).Where("preview_path IN ?", paths).Update("preview_path", "")
+	return result.RowsAffected, result.Error
+}
+
 // GetFolders returns all folders in the database
 func (d *Database) GetFolders() ([]models.Folder, error) {
 	var folders []models.Folder
@@ -82,6 +192,52 @@ func (d *Database) DeleteMediaFilesByDirectory(dirPath string) error {
 	return d.db.Where("path LIKE ?", dirPath+"%").Delete(&models.MediaFile{}).Error
 }
 
+// GetMediaFilesByDirectory returns every media file stored below dirPath,
+// including records already marked as missing.
+func (d *Database) GetMediaFilesByDirectory(dirPath string) ([]models.MediaFile, error) {
+	var files []models.MediaFile
+	err := d.db.Where("path LIKE ? ESCAPE '\\'", dirPrefixPattern(dirPath)).Find(&files).Error
+	return files, err
+}
+
+// SaveScanResults applies the outcome of a directory scan in a single
+// transaction: new files are inserted, changed files are updated in place
+// (keeping their ID, tags and other associations) and vanished files are
+// flagged as missing rather than deleted. Associations and userColumns are
+// not written of changed files; see ReplaceMediaMetadata.
+func (d *Database) SaveScanResults(added, changed []models.MediaFile, missingIDs []uint) error {
+	// Changed files lose their perceptual hash until the preview is rebuilt
+	if len(changed) > 0 {
+		defer d.invalidateSimilarityIndex()
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if len(added) > 0 {
+			if err := tx.Omit(clause.Associations).CreateInBatches(added, 500).Error; err != nil {
+				return fmt.Errorf("failed to insert media files: %w", err)
+			}
+		}
+		for i := range changed {
+			if err := tx.Omit(append([]string{clause.Associations}, userColumns...)...).Save(&changed[i]).Error; err != nil {
+				return fmt.Errorf("failed to update media file %s: %w", changed[i].Path, err)
+			}
+		}
+		if len(missingIDs) > 0 {
+			err := tx.Model(&models.MediaFile{}).Where("id IN ?", missingIDs).Update("missing", true).Error
+			if err != nil {
+				return fmt.Errorf("failed to mark media files missing: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// dirPrefixPattern builds a LIKE pattern (escaped with '\') that matches
+// every path inside dirPath but not sibling directories sharing its prefix.
+func dirPrefixPattern(dirPath string) string {
+	dirPath = strings.TrimSuffix(filepath.Clean(dirPath), string(filepath.Separator))
+	return likeEscaper.Replace(dirPath+string(filepath.Separator)) + "%"
+}
+
 func (d *Database) Close() error {
 	db, err := d.db.DB()
 	if err != nil {
@@ -3,6 +3,7 @@ package db
 import (
 	"github.com/user/media-manager/pkg/models"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -46,3 +47,48 @@ func TestClearAllPreviews(t *testing.T) {
 		}
 	}
 }
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	database, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestReplaceMediaMetadata(t *testing.T) {
+	database := newTestDatabase(t)
+	file := models.MediaFile{Path: "/photos/a.jpg", FileType: "image"}
+	if err := database.GetDB().Create(&file).Error; err != nil {
+		t.Fatalf("Failed to insert test record: %v", err)
+	}
+
+	update := map[uint]*models.MediaMetadata{file.ID: {CameraModel: "Pixel 8", ISO: 100}}
+	if err := database.ReplaceMediaMetadata(update); err != nil {
+		t.Fatalf("ReplaceMediaMetadata failed: %v", err)
+	}
+	update = map[uint]*models.MediaMetadata{file.ID: {CameraModel: "Pixel 9", ISO: 200}}
+	if err := database.ReplaceMediaMetadata(update); err != nil {
+		t.Fatalf("ReplaceMediaMetadata failed: %v", err)
+	}
+
+	var loaded models.MediaFile
+	if err := database.GetDB().Preload("Metadata").First(&loaded, file.ID).Error; err != nil {
+		t.Fatalf("Failed to load media file: %v", err)
+	}
+	if loaded.Metadata == nil || loaded.Metadata.CameraModel != "Pixel 9" || loaded.Metadata.ISO != 200 {
+		t.Fatalf("Expected replaced metadata, got %+v", loaded.Metadata)
+	}
+
+	// Deleting the file removes its metadata too
+	if err := database.DeleteMediaFile(&loaded); err != nil {
+		t.Fatalf("DeleteMediaFile failed: %v", err)
+	}
+	var count int64
+	database.GetDB().Model(&models.MediaMetadata{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected metadata to be deleted with its file, %d rows left", count)
+	}
+}
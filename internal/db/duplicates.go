@@ -0,0 +1,72 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// DuplicateGroup is a set of media files with identical content.
+type DuplicateGroup struct {
+	ContentHash string
+	Size        int64
+	Files       []models.MediaFile
+}
+
+// WastedBytes is the disk space freed by keeping only one copy of the group.
+func (g DuplicateGroup) WastedBytes() int64 {
+	return g.Size * int64(len(g.Files)-1)
+}
+
+// GetDuplicateCandidates returns files without a full content hash that share
+// size and fingerprint with at least one other file. Only these need a full
+// hash to decide whether they really are duplicates.
+func (d *Database) GetDuplicateCandidates() ([]models.MediaFile, error) {
+	shared := d.db.Model(&models.MediaFile{}).
+		Select("size, fingerprint").
+		Where("missing = ? AND fingerprint IS NOT NULL AND fingerprint != ''", false).
+		Group("size, fingerprint").
+		Having("COUNT(*) > 1")
+
+	var files []models.MediaFile
+	err := d.db.Where("missing = ? AND (content_hash IS NULL OR content_hash = '')", false).
+		Where("(size, fingerprint) IN (?)", shared).
+		Find(&files).Error
+	return files, err
+}
+
+// SetContentHash stores the full content hash of a media file.
+func (d *Database) SetContentHash(id uint, hash string) error {
+	return d.db.Model(&models.MediaFile{}).Where("id = ?", id).Update("content_hash", hash).Error
+}
+
+// GetDuplicateGroups returns every group of two or more present files with the
+// same content hash, largest reclaimable space first.
+func (d *Database) GetDuplicateGroups() ([]DuplicateGroup, error) {
+	duplicated := d.db.Model(&models.MediaFile{}).
+		Select("content_hash").
+		Where("missing = ? AND content_hash IS NOT NULL AND content_hash != ''", false).
+		Group("content_hash").
+		Having("COUNT(*) > 1")
+
+	var files []models.MediaFile
+	err := d.db.Where("missing = ? AND content_hash IN (?)", false, duplicated).
+		Order("content_hash, path").
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []DuplicateGroup
+	for _, file := range files {
+		if len(groups) == 0 || groups[len(groups)-1].ContentHash != file.ContentHash {
+			groups = append(groups, DuplicateGroup{ContentHash: file.ContentHash, Size: file.Size})
+		}
+		last := &groups[len(groups)-1]
+		last.Files = append(last.Files, file)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].WastedBytes() > groups[j].WastedBytes()
+	})
+	return groups, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestDuplicateCandidatesAndGroups(t *testing.T) {
+	database := newTestDatabase(t)
+	files := []models.MediaFile{
+		{Path: "/a/1.jpg", Size: 100, Fingerprint: "fp1"},
+		{Path: "/b/1.jpg", Size: 100, Fingerprint: "fp1"},
+		{Path: "/c/1.jpg", Size: 100, Fingerprint: "fp1", Missing: true},
+		{Path: "/a/2.jpg", Size: 100, Fingerprint: "fp2"},
+		{Path: "/a/3.mp4", Size: 500, Fingerprint: "fp3", ContentHash: "big"},
+		{Path: "/b/3.mp4", Size: 500, Fingerprint: "fp3", ContentHash: "big"},
+	}
+	for i := range files {
+		if err := database.GetDB().Create(&files[i]).Error; err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+
+	candidates, err := database.GetDuplicateCandidates()
+	if err != nil {
+		t.Fatalf("GetDuplicateCandidates failed: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates without a content hash, got %d", len(candidates))
+	}
+	for _, candidate := range candidates {
+		if candidate.Fingerprint != "fp1" || candidate.Missing {
+			t.Errorf("Unexpected candidate: %+v", candidate)
+		}
+		if err := database.SetContentHash(candidate.ID, "small"); err != nil {
+			t.Fatalf("SetContentHash failed: %v", err)
+		}
+	}
+
+	groups, err := database.GetDuplicateGroups()
+	if err != nil {
+		t.Fatalf("GetDuplicateGroups failed: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 duplicate groups, got %d", len(groups))
+	}
+	// Largest reclaimable space first
+	if groups[0].ContentHash != "big" || groups[0].WastedBytes() != 500 {
+		t.Errorf("Unexpected first group: %+v", groups[0])
+	}
+	if groups[1].ContentHash != "small" || len(groups[1].Files) != 2 {
+		t.Errorf("Missing files must not be part of a group: %+v", groups[1])
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// The full-text index media_search holds, per media file (rowid = id), the
+// words of its file name, of the folders on its path, of its tags and their
+// ancestors (a file tagged "Places/Paris" is found by "places") and of its
+// notes. Triggers keep it in step with media_files, file_tags and
+// tags, so every writer, including raw SQL, updates it.
+//
+// FTS5 is only compiled into go-sqlite3 with the sqlite_fts5 build tag.
+// Without it, searches fall back to LIKE and the triggers are dropped, since
+// they would make every write to media_files fail; the index is rebuilt the
+// next time a build with FTS5 opens the database.
+
+// searchColumnWeights are the bm25 weights of filename, folders, tags and
+// notes: a word in the file name counts most.
+const searchColumnWeights = "10.0, 1.0, 5.0, 3.0"
+
+// searchTagsOf selects the names of the tags of the file with the given id
+// and of all their ancestors.
+const searchTagsOf = "(WITH RECURSIVE tag_path(id, parent_id, name) AS (" +
+	"SELECT tags.id, tags.parent_id, tags.name FROM file_tags JOIN tags ON tags.id = file_tags.tag_id" +
+	" WHERE file_tags.media_file_id = %s" +
+	" UNION SELECT tags.id, tags.parent_id, tags.name FROM tags JOIN tag_path ON tags.id = tag_path.parent_id)" +
+	" SELECT COALESCE(group_concat(name, ' '), '') FROM tag_path)"
+
+// searchFoldersOf selects the directory part of a media_files row's path.
+const searchFoldersOf = "substr(%[1]s.path, 1, length(%[1]s.path) - length(%[1]s.filename))"
+
+var searchTriggers = []string{
+	"CREATE TRIGGER IF NOT EXISTS media_search_insert AFTER INSERT ON media_files BEGIN" +
+		" INSERT INTO media_search(rowid, filename, folders, tags, notes) VALUES (new.id, new.filename, " +
+		fmt.Sprintf(searchFoldersOf, "new") + ", " + fmt.Sprintf(searchTagsOf, "new.id") + ", COALESCE(new.notes, '')); END",
+	"CREATE TRIGGER IF NOT EXISTS media_search_update AFTER UPDATE OF path, filename, notes ON media_files BEGIN" +
+		" UPDATE media_search SET filename = new.filename, folders = " + fmt.Sprintf(searchFoldersOf, "new") +
+		", notes = COALESCE(new.notes, '') WHERE rowid = new.id; END",
+	"CREATE TRIGGER IF NOT EXISTS media_search_delete AFTER DELETE ON media_files BEGIN" +
+		" DELETE FROM media_search WHERE rowid = old.id; END",
+	"CREATE TRIGGER IF NOT EXISTS media_search_tag_insert AFTER INSERT ON file_tags BEGIN" +
+		" UPDATE media_search SET tags = " + fmt.Sprintf(searchTagsOf, "new.media_file_id") + " WHERE rowid = new.media_file_id; END",
+	"CREATE TRIGGER IF NOT EXISTS media_search_tag_update AFTER UPDATE ON file_tags BEGIN" +
+		" UPDATE media_search SET tags = " + fmt.Sprintf(searchTagsOf, "media_search.rowid") +
+		" WHERE rowid IN (old.media_file_id, new.media_file_id); END",
+	"CREATE TRIGGER IF NOT EXISTS media_search_tag_delete AFTER DELETE ON file_tags BEGIN" +
+		" UPDATE media_search SET tags = " + fmt.Sprintf(searchTagsOf, "old.media_file_id") + " WHERE rowid = old.media_file_id; END",
+	"CREATE TRIGGER IF NOT EXISTS media_search_tag_path AFTER UPDATE OF name, parent_id ON tags BEGIN" +
+		" UPDATE media_search SET tags = " + fmt.Sprintf(searchTagsOf, "media_search.rowid") +
+		" WHERE rowid IN (WITH RECURSIVE subtree(id) AS (SELECT new.id" +
+		" UNION SELECT tags.id FROM tags JOIN subtree ON tags.parent_id = subtree.id)" +
+		" SELECT file_tags.media_file_id FROM file_tags JOIN subtree ON subtree.id = file_tags.tag_id); END",
+}
+
+var searchTriggerNames = []string{
+	"media_search_insert", "media_search_update", "media_search_delete",
+	"media_search_tag_insert", "media_search_tag_update", "media_search_tag_delete", "media_search_tag_path",
+}
+
+// oldSearchTriggerNames are triggers of earlier versions, which indexed only
+// the tags' own names.
+var oldSearchTriggerNames = []string{"media_search_tag_rename"}
+
+// setupFullTextSearch creates the full-text index and its triggers and
+// reports whether FTS5 is available. An index whose triggers are missing,
+// because it is new or was last opened without FTS5, is rebuilt.
+func setupFullTextSearch(db *gorm.DB) (bool, error) {
+	err := db.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS media_search USING fts5(" +
+		"filename, folders, tags, notes, tokenize = 'unicode61 remove_diacritics 2')").Error
+	if err != nil {
+		if !strings.Contains(err.Error(), "no such module") {
+			return false, err
+		}
+		fmt.Println("[WARN] SQLite was built without FTS5; searching with LIKE. Build with -tags sqlite_fts5 for full-text search")
+		return false, dropSearchTriggers(db)
+	}
+
+	var triggers int64
+	err = db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN ?", searchTriggerNames).
+		Scan(&triggers).Error
+	if err != nil {
+		return false, err
+	}
+	if int(triggers) == len(searchTriggerNames) {
+		return true, nil
+	}
+	return true, db.Transaction(func(tx *gorm.DB) error {
+		// Triggers left by an earlier version are replaced, not kept
+		if err := dropSearchTriggers(tx); err != nil {
+			return err
+		}
+		for _, trigger := range searchTriggers {
+			if err := tx.Exec(trigger).Error; err != nil {
+				return fmt.Errorf("failed to create search trigger: %w", err)
+			}
+		}
+		return rebuildSearchIndex(tx)
+	})
+}
+
+// dropSearchTriggers drops the triggers of the full-text index, including
+// those of earlier versions.
+func dropSearchTriggers(tx *gorm.DB) error {
+	for _, name := range append(slices.Clone(searchTriggerNames), oldSearchTriggerNames...) {
+		if err := tx.Exec("DROP TRIGGER IF EXISTS " + name).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSearchIndex refills the full-text index from the library.
+func rebuildSearchIndex(tx *gorm.DB) error {
+	if err := tx.Exec("DELETE FROM media_search").Error; err != nil {
+		return err
+	}
+	return tx.Exec("INSERT INTO media_search(rowid, filename, folders, tags, notes) SELECT media_files.id, media_files.filename, " +
+		fmt.Sprintf(searchFoldersOf, "media_files") + ", " + fmt.Sprintf(searchTagsOf, "media_files.id") +
+		", COALESCE(media_files.notes, '') FROM media_files").Error
+}
+
+// ftsWord quotes a word of the filter box for an FTS5 MATCH: a word also
+// matches longer words it starts, a phrase only the exact words.
+func ftsWord(text string, prefix bool) string {
+	quoted := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+	if prefix {
+		quoted += "*"
+	}
+	return quoted
+}
+
+// hasSearchWord reports whether text holds a word the full-text index can
+// match. The index splits text at everything but letters and digits, so a
+// term without any, such as "-" or "&", would match no file at all.
+func hasSearchWord(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func searchNames(t *testing.T, database *Database, text string) []string {
+	t.Helper()
+	search, err := ParseSearch(text)
+	if err != nil {
+		t.Fatalf("ParseSearch(%q) failed: %v", text, err)
+	}
+	files, err := database.QueryMediaFiles(MediaQuery{Search: search, Sort: SortByRelevance})
+	if err != nil {
+		t.Fatalf("QueryMediaFiles(%q) failed: %v", text, err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Filename)
+	}
+	return names
+}
+
+func TestFullTextSearch(t *testing.T) {
+	database := newTestDatabase(t)
+	files := []models.MediaFile{
+		{Path: "/media/holidays/lisbon/IMG_0001.jpg", Filename: "IMG_0001.jpg", FileType: "image"},
+		{Path: "/media/work/lisbon_office.jpg", Filename: "lisbon_office.jpg", FileType: "image"},
+		{Path: "/media/misc/sunset.jpg", Filename: "sunset.jpg", FileType: "image"},
+	}
+	for i := range files {
+		if err := database.CreateMediaFile(&files[i]); err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+
+	// Folder names are searched along with file names
+	if got := searchNames(t, database, "holidays"); !slices.Equal(got, []string{"IMG_0001.jpg"}) {
+		t.Errorf("Expected the file in the holidays folder, got %v", got)
+	}
+
+	// Notes and tags are searched as soon as they change
+	if err := database.SetNotes(files[2].ID, "Tram 28 at dusk"); err != nil {
+		t.Fatalf("Failed to set notes: %v", err)
+	}
+	tag, err := database.GetOrCreateTag("Portugal")
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := database.AddTagToFiles(tag.ID, []uint{files[2].ID}); err != nil {
+		t.Fatalf("Failed to tag file: %v", err)
+	}
+	if got := searchNames(t, database, "tram"); !slices.Equal(got, []string{"sunset.jpg"}) {
+		t.Errorf("Expected a match on notes, got %v", got)
+	}
+	if got := searchNames(t, database, "portugal dusk"); !slices.Equal(got, []string{"sunset.jpg"}) {
+		t.Errorf("Expected a match on tag and notes, got %v", got)
+	}
+	if err := database.RenameTag(tag.ID, "Lisboa"); err != nil {
+		t.Fatalf("Failed to rename tag: %v", err)
+	}
+	if got := searchNames(t, database, "lisboa"); !slices.Equal(got, []string{"sunset.jpg"}) {
+		t.Errorf("Expected the renamed tag to be searched, got %v", got)
+	}
+	if err := database.RemoveTagFromFiles(tag.ID, []uint{files[2].ID}); err != nil {
+		t.Fatalf("Failed to untag file: %v", err)
+	}
+	if got := searchNames(t, database, "lisboa"); len(got) != 0 {
+		t.Errorf("Expected the removed tag not to match, got %v", got)
+	}
+
+	// A match in the file name ranks above one in a folder name
+	got := searchNames(t, database, "lisbon")
+	if !database.fts {
+		slices.Sort(got)
+	}
+	if !slices.Equal(got, []string{"lisbon_office.jpg", "IMG_0001.jpg"}) && !slices.Equal(got, []string{"IMG_0001.jpg", "lisbon_office.jpg"}) {
+		t.Errorf("Expected both Lisbon files, got %v", got)
+	} else if database.fts && got[0] != "lisbon_office.jpg" {
+		t.Errorf("Expected the file name match first, got %v", got)
+	}
+
+	// Deleted and renamed files leave the index
+	if err := database.DeleteMediaFile(&files[1]); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+	files[0].Path, files[0].Filename = "/media/holidays/porto/IMG_0001.jpg", "IMG_0001.jpg"
+	if err := database.UpdateMediaFile(&files[0]); err != nil {
+		t.Fatalf("Failed to move file: %v", err)
+	}
+	if got := searchNames(t, database, "lisbon"); len(got) != 0 {
+		t.Errorf("Expected no Lisbon files left, got %v", got)
+	}
+	if got := searchNames(t, database, "porto"); !slices.Equal(got, []string{"IMG_0001.jpg"}) {
+		t.Errorf("Expected the moved file under its new folder, got %v", got)
+	}
+}
+
+func TestFullTextSearchTagPaths(t *testing.T) {
+	database := newTestDatabase(t)
+	file := models.MediaFile{Path: "/media/IMG_0002.jpg", Filename: "IMG_0002.jpg", FileType: "image"}
+	if err := database.CreateMediaFile(&file); err != nil {
+		t.Fatalf("Failed to insert test record: %v", err)
+	}
+	tag, err := database.GetOrCreateTag("Places/Paris")
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := database.AddTagToFiles(tag.ID, []uint{file.ID}); err != nil {
+		t.Fatalf("Failed to tag file: %v", err)
+	}
+
+	// The names of a tag's ancestors are searched too
+	if got := searchNames(t, database, "places"); !slices.Equal(got, []string{"IMG_0002.jpg"}) {
+		t.Errorf("Expected a match on the parent tag, got %v", got)
+	}
+
+	// Renaming or moving an ancestor updates the files beneath it
+	if err := database.RenameTag(*tag.ParentID, "Cities"); err != nil {
+		t.Fatalf("Failed to rename tag: %v", err)
+	}
+	if got := searchNames(t, database, "cities"); !slices.Equal(got, []string{"IMG_0002.jpg"}) {
+		t.Errorf("Expected the renamed parent tag to be searched, got %v", got)
+	}
+	france, err := database.GetOrCreateTag("France")
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := database.SetTagParent(tag.ID, &france.ID); err != nil {
+		t.Fatalf("Failed to move tag: %v", err)
+	}
+	if got := searchNames(t, database, "france paris"); !slices.Equal(got, []string{"IMG_0002.jpg"}) {
+		t.Errorf("Expected the new parent tag to be searched, got %v", got)
+	}
+	if got := searchNames(t, database, "cities"); len(got) != 0 {
+		t.Errorf("Expected the old parent tag not to match, got %v", got)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// setMarkColumn writes one of the user's marks to the given files.
+func (d *Database) setMarkColumn(fileIDs []uint, column string, value any) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return d.db.Model(&models.MediaFile{}).Where("id IN ?", fileIDs).Update(column, value).Error
+}
+
+// SetRating gives files a rating of 0 (unrated) to models.MaxRating stars.
+func (d *Database) SetRating(fileIDs []uint, rating int) error {
+	if rating < 0 || rating > models.MaxRating {
+		return fmt.Errorf("rating must be between 0 and %d, got %d", models.MaxRating, rating)
+	}
+	return d.setMarkColumn(fileIDs, "rating", rating)
+}
+
+// SetFavorite adds files to or removes them from the favorites.
+func (d *Database) SetFavorite(fileIDs []uint, favorite bool) error {
+	return d.setMarkColumn(fileIDs, "favorite", favorite)
+}
+
+// SetFlag picks or rejects files, or clears their flag.
+func (d *Database) SetFlag(fileIDs []uint, flag models.Flag) error {
+	switch flag {
+	case models.FlagNone, models.FlagPick, models.FlagReject:
+	default:
+		return fmt.Errorf("unknown flag %d", flag)
+	}
+	return d.setMarkColumn(fileIDs, "flag", flag)
+}
+
+// SetColorLabel labels files with a color, or clears their label.
+func (d *Database) SetColorLabel(fileIDs []uint, label models.ColorLabel) error {
+	if label != models.LabelNone && !slices.Contains(models.ColorLabels, label) {
+		return fmt.Errorf("unknown color label %q", label)
+	}
+	return d.setMarkColumn(fileIDs, "color_label", label)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestMarks(t *testing.T) {
+	database := newTestDatabase(t)
+	var files []models.MediaFile
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"} {
+		file := models.MediaFile{Path: "/media/" + name, Filename: name, FileType: "image"}
+		if err := database.CreateMediaFile(&file); err != nil {
+			t.Fatalf("Failed to insert %s: %v", name, err)
+		}
+		files = append(files, file)
+	}
+	a, b, c, d := files[0].ID, files[1].ID, files[2].ID, files[3].ID
+
+	if err := database.SetRating([]uint{a, c}, 4); err != nil {
+		t.Fatalf("Failed to rate files: %v", err)
+	}
+	if err := database.SetRating([]uint{b}, 5); err != nil {
+		t.Fatalf("Failed to rate files: %v", err)
+	}
+	if err := database.SetFavorite([]uint{c}, true); err != nil {
+		t.Fatalf("Failed to mark favorite: %v", err)
+	}
+	if err := database.SetFlag([]uint{a}, models.FlagPick); err != nil {
+		t.Fatalf("Failed to pick file: %v", err)
+	}
+	if err := database.SetFlag([]uint{d}, models.FlagReject); err != nil {
+		t.Fatalf("Failed to reject file: %v", err)
+	}
+	if err := database.SetColorLabel([]uint{a, b}, models.LabelRed); err != nil {
+		t.Fatalf("Failed to label files: %v", err)
+	}
+	if err := database.SetRating([]uint{a}, 6); err == nil {
+		t.Errorf("Expected a rating above %d to be rejected", models.MaxRating)
+	}
+	if err := database.SetFlag([]uint{a}, 2); err == nil {
+		t.Errorf("Expected an unknown flag to be rejected")
+	}
+	if err := database.SetColorLabel([]uint{a}, "orange"); err == nil {
+		t.Errorf("Expected an unknown color label to be rejected")
+	}
+
+	record, err := database.GetMediaFileByPath("/media/a.jpg")
+	if err != nil {
+		t.Fatalf("Failed to load record: %v", err)
+	}
+	if record.Rating != 4 || record.Favorite || record.Flag != models.FlagPick || record.ColorLabel != models.LabelRed {
+		t.Errorf("Unexpected marks: rating %d, favorite %v, flag %v, label %q",
+			record.Rating, record.Favorite, record.Flag, record.ColorLabel)
+	}
+
+	// A rescan saving a record loaded before the marks were set keeps them
+	stale := files[0]
+	stale.Size = 1234
+	if err := database.UpdateMediaFile(&stale); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if err := database.SaveScanResults(nil, []models.MediaFile{files[1]}, nil); err != nil {
+		t.Fatalf("Failed to save scan results: %v", err)
+	}
+	if record, _ := database.GetMediaFileByPath("/media/a.jpg"); record.Size != 1234 || record.Rating != 4 || record.Flag != models.FlagPick {
+		t.Errorf("Expected the update to keep the marks, got size %d, rating %d, flag %v", record.Size, record.Rating, record.Flag)
+	}
+	if record, _ := database.GetMediaFileByPath("/media/b.jpg"); record.Rating != 5 || record.ColorLabel != models.LabelRed {
+		t.Errorf("Expected the scan to keep the marks, got rating %d, label %q", record.Rating, record.ColorLabel)
+	}
+
+	cases := map[string][]string{
+		"rating>=4":              {"a.jpg", "b.jpg", "c.jpg"},
+		"rating:5":               {"b.jpg"},
+		"rating:0":               {"d.jpg"},
+		"rating:1..4":            {"a.jpg", "c.jpg"},
+		"is:favorite":            {"c.jpg"},
+		"flag:pick":              {"a.jpg"},
+		"-flag:reject":           {"a.jpg", "b.jpg", "c.jpg"},
+		"flag:none rating>0":     {"b.jpg", "c.jpg"},
+		"label:red":              {"a.jpg", "b.jpg"},
+		"label:none":             {"c.jpg", "d.jpg"},
+		"label:RED -is:favorite": {"a.jpg", "b.jpg"},
+	}
+	for input, want := range cases {
+		search, err := ParseSearch(input)
+		if err != nil {
+			t.Errorf("ParseSearch(%q) failed: %v", input, err)
+			continue
+		}
+		var got []string
+		for _, path := range queryPaths(t, database, MediaQuery{Search: search}) {
+			got = append(got, path[len("/media/"):])
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Search %q: expected %v, got %v", input, want, got)
+		}
+	}
+	for _, input := range []string{"rating:6", "rating>five", "flag:maybe", "label:orange", "flag>pick"} {
+		if _, err := ParseSearch(input); err == nil {
+			t.Errorf("Expected %q to be rejected", input)
+		}
+	}
+
+	// Highest rated first, favorites first among equal ratings
+	got := queryPaths(t, database, MediaQuery{Sort: SortByRating, Descending: true})
+	want := []string{"/media/b.jpg", "/media/c.jpg", "/media/a.jpg", "/media/d.jpg"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v sorted by rating, got %v", want, got)
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// ReplaceMediaMetadata stores the metadata of several media files in one
+// transaction, keyed by media file ID. Existing rows are replaced; a nil
+// value removes the file's metadata.
+func (d *Database) ReplaceMediaMetadata(metadata map[uint]*models.MediaMetadata) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var rows []models.MediaMetadata
+		for fileID, meta := range metadata {
+			if err := tx.Where("media_file_id = ?", fileID).Delete(&models.MediaMetadata{}).Error; err != nil {
+				return fmt.Errorf("failed to delete metadata of media file %d: %w", fileID, err)
+			}
+			if meta != nil {
+				row := *meta
+				row.ID = 0
+				row.MediaFileID = fileID
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+				return fmt.Errorf("failed to insert metadata: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetMediaMetadata returns the metadata of a media file, or nil if it has none.
+func (d *Database) GetMediaMetadata(fileID uint) (*models.MediaMetadata, error) {
+	var rows []models.MediaMetadata
+	if err := d.db.Where("media_file_id = ?", fileID).Limit(1).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// MediaSort is the order of the results of QueryMediaFiles.
+type MediaSort int
+
+const (
+	SortByName MediaSort = iota
+	SortByModTime
+	SortBySize
+	SortByPath
+	// SortByRelevance puts the best matches of the search's words first; it
+	// sorts by name if there are none or FTS5 is unavailable
+	SortByRelevance
+	// SortByAlbumOrder keeps the user's order of the album of the query; it
+	// sorts by name without an album
+	SortByAlbumOrder
+	// SortByRating sorts by stars, favorites first among equal ratings
+	SortByRating
+	// SortByResolution sorts by pixel count, width times height
+	SortByResolution
+	// SortByDuration sorts videos by length
+	SortByDuration
+)
+
+var mediaSortColumns = map[MediaSort]string{
+	SortByName:       "media_files.filename COLLATE NOCASE",
+	SortByModTime:    "media_files.mod_time",
+	SortBySize:       "media_files.size",
+	SortByPath:       "media_files.path",
+	SortByRelevance:  "media_files.filename COLLATE NOCASE",
+	SortByAlbumOrder: "album_items.position",
+	SortByRating:     "media_files.rating",
+	SortByResolution: "media_files.width * media_files.height",
+	SortByDuration:   "media_files.duration",
+}
+
+// MediaQuery selects media files for QueryMediaFiles and CountMediaFiles.
+// Zero values do not restrict the result.
+type MediaQuery struct {
+	Dir            string // only files inside this directory
+	Recursive      bool   // also files in subdirectories of Dir
+	TagID          uint   // only files carrying this tag or a tag beneath it
+	AlbumID        uint   // only files in this album
+	Filter         string // case-insensitive substring of the file name
+	Search         Search // parsed filter box query, see ParseSearch
+	FileType       string // "image" or "video"
+	IncludeMissing bool   // also files that vanished from disk
+	Sort           MediaSort
+	Descending     bool
+	Limit          int
+	Offset         int
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// where applies the query's filters, but not its order or page, to tx.
+func (q MediaQuery) where(d *Database, tx *gorm.DB) *gorm.DB {
+	if q.Dir != "" {
+		tx = tx.Where("media_files.path LIKE ? ESCAPE '\\'", dirPrefixPattern(q.Dir))
+		if !q.Recursive {
+			nested := dirPrefixPattern(q.Dir) + likeEscaper.Replace(string(filepath.Separator)) + "%"
+			tx = tx.Where("media_files.path NOT LIKE ? ESCAPE '\\'", nested)
+		}
+	}
+	if q.AlbumID != 0 {
+		tx = tx.Joins("JOIN album_items ON album_items.media_file_id = media_files.id AND album_items.album_id = ?", q.AlbumID)
+	}
+	if q.TagID != 0 {
+		tx = tx.Where("media_files.id IN (?)", d.db.Raw(tagSubtreeCTE+
+			" SELECT file_tags.media_file_id FROM file_tags"+
+			" JOIN subtree ON subtree.id = file_tags.tag_id WHERE subtree.root_id = ?", q.TagID))
+	}
+	if q.Filter != "" {
+		tx = tx.Where("media_files.filename LIKE ? ESCAPE '\\'", "%"+likeEscaper.Replace(q.Filter)+"%")
+	}
+	if q.FileType != "" {
+		tx = tx.Where("media_files.file_type = ?", q.FileType)
+	}
+	tx = q.Search.where(d, tx)
+	if !q.IncludeMissing {
+		tx = tx.Where("media_files.missing = ?", false)
+	}
+	return tx
+}
+
+// QueryMediaFiles returns the media files matching q, in q's order and page.
+// Ties are broken by path so that pages are stable.
+func (d *Database) QueryMediaFiles(q MediaQuery) ([]models.MediaFile, error) {
+	tx := q.where(d, d.db.Model(&models.MediaFile{}))
+	if match := q.Search.matchExpr(); q.Sort == SortByRelevance && d.fts && match != "" {
+		// bm25 is lower for better matches
+		tx = tx.Joins("JOIN (SELECT rowid AS ranked_id, bm25(media_search, "+searchColumnWeights+") AS rank"+
+			" FROM media_search WHERE media_search MATCH ?) AS ranked ON ranked.ranked_id = media_files.id", match).
+			Order("ranked.rank")
+	}
+	order := []string{mediaSortColumns[q.Sort]}
+	if q.Sort == SortByAlbumOrder && q.AlbumID == 0 {
+		order = []string{mediaSortColumns[SortByName]}
+	}
+	if q.Sort == SortByRating {
+		order = append(order, "media_files.favorite")
+	}
+	if q.Descending {
+		for i := range order {
+			order[i] += " DESC"
+		}
+	}
+	if q.Sort != SortByPath {
+		order = append(order, "media_files.path")
+	}
+	tx = tx.Order(strings.Join(order, ", "))
+	if q.Limit > 0 {
+		tx = tx.Limit(q.Limit)
+	}
+	if q.Offset > 0 {
+		tx = tx.Offset(q.Offset)
+	}
+	var files []models.MediaFile
+	err := tx.Find(&files).Error
+	return files, err
+}
+
+// CountMediaFiles returns how many media files match q, ignoring its page.
+func (d *Database) CountMediaFiles(q MediaQuery) (int64, error) {
+	var count int64
+	err := q.where(d, d.db.Model(&models.MediaFile{})).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func queryPaths(t *testing.T, database *Database, q MediaQuery) []string {
+	t.Helper()
+	files, err := database.QueryMediaFiles(q)
+	if err != nil {
+		t.Fatalf("QueryMediaFiles(%+v) failed: %v", q, err)
+	}
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+func TestQueryMediaFiles(t *testing.T) {
+	database := newTestDatabase(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []models.MediaFile{
+		{Path: "/media/b.jpg", Filename: "b.jpg", FileType: "image", Size: 300, Width: 4000, Height: 3000, ModTime: base.Add(2 * time.Hour)},
+		{Path: "/media/A.mp4", Filename: "A.mp4", FileType: "video", Size: 100, Width: 1920, Height: 1080, Duration: 60, ModTime: base},
+		{Path: "/media/trip/c.jpg", Filename: "c.jpg", FileType: "image", Size: 200, Width: 1000, Height: 3000, ModTime: base.Add(time.Hour)},
+		{Path: "/media/gone.jpg", Filename: "gone.jpg", FileType: "image", Missing: true},
+		{Path: "/media_other/d.jpg", Filename: "d.jpg", FileType: "image"},
+		{Path: "/media/100%_b.jpg", Filename: "100%_b.jpg", FileType: "image", Size: 50, ModTime: base},
+	}
+	for i := range files {
+		if err := database.GetDB().Create(&files[i]).Error; err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+
+	assertPaths := func(name string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: expected %v, got %v", name, want, got)
+				return
+			}
+		}
+	}
+
+	assertPaths("folder", queryPaths(t, database, MediaQuery{Dir: "/media"}),
+		"/media/100%_b.jpg", "/media/A.mp4", "/media/b.jpg")
+	assertPaths("recursive", queryPaths(t, database, MediaQuery{Dir: "/media", Recursive: true, Sort: SortByPath}),
+		"/media/100%_b.jpg", "/media/A.mp4", "/media/b.jpg", "/media/trip/c.jpg")
+	assertPaths("filter", queryPaths(t, database, MediaQuery{Dir: "/media", Recursive: true, Filter: "B"}),
+		"/media/100%_b.jpg", "/media/b.jpg")
+	assertPaths("filter wildcards are literal", queryPaths(t, database, MediaQuery{Filter: "%_"}),
+		"/media/100%_b.jpg")
+	assertPaths("type", queryPaths(t, database, MediaQuery{FileType: "video"}), "/media/A.mp4")
+	assertPaths("size descending", queryPaths(t, database, MediaQuery{Dir: "/media", Recursive: true, Sort: SortBySize, Descending: true}),
+		"/media/b.jpg", "/media/trip/c.jpg", "/media/A.mp4", "/media/100%_b.jpg")
+	assertPaths("resolution descending", queryPaths(t, database, MediaQuery{Dir: "/media", Recursive: true, Sort: SortByResolution, Descending: true}),
+		"/media/b.jpg", "/media/trip/c.jpg", "/media/A.mp4", "/media/100%_b.jpg")
+	assertPaths("longest first", queryPaths(t, database, MediaQuery{Dir: "/media", Recursive: true, Sort: SortByDuration, Descending: true}),
+		"/media/A.mp4", "/media/100%_b.jpg", "/media/b.jpg", "/media/trip/c.jpg")
+	assertPaths("modified, ties by path", queryPaths(t, database, MediaQuery{Dir: "/media", Sort: SortByModTime}),
+		"/media/100%_b.jpg", "/media/A.mp4", "/media/b.jpg")
+	assertPaths("page", queryPaths(t, database, MediaQuery{Dir: "/media", Recursive: true, Sort: SortByPath, Limit: 2, Offset: 1}),
+		"/media/A.mp4", "/media/b.jpg")
+	assertPaths("missing", queryPaths(t, database, MediaQuery{Dir: "/media", Filter: "gone", IncludeMissing: true}),
+		"/media/gone.jpg")
+
+	count, err := database.CountMediaFiles(MediaQuery{Dir: "/media", Recursive: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("CountMediaFiles failed: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 files below /media, got %d", count)
+	}
+}
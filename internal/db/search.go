@@ -0,0 +1,529 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// Search is a parsed query of the filter box, such as
+//
+//	tag:beach type:video size>100MB taken:2023-06..2023-08 -tag:private "exact phrase"
+//	rating>=4 -flag:reject label:red is:favorite
+//
+// Its terms must all match. A term is a word or quoted phrase, looked up in
+// the full-text index of file names, folders, tags and notes, or a field, an
+// operator and a value; a leading "-" negates it.
+type Search struct {
+	Input string
+	terms []searchTerm
+}
+
+// searchTerm is one condition of a Search.
+type searchTerm struct {
+	negate bool
+	// text is the word or phrase of a text term; prefix is set for words,
+	// which also match longer words they start
+	text   string
+	prefix bool
+	// cond returns the SQL condition on media_files and its arguments of
+	// other terms
+	cond func(d *Database) (string, []any)
+}
+
+// SearchError is a syntax error in a search. Pos is the byte offset in the
+// input where it was found.
+type SearchError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SearchError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Msg, e.Pos+1)
+}
+
+// IsEmpty reports whether the search has no terms and so matches every file.
+func (s Search) IsEmpty() bool {
+	return len(s.terms) == 0
+}
+
+// And returns a search matching the files both s and other match.
+func (s Search) And(other Search) Search {
+	return Search{
+		Input: strings.TrimSpace(s.Input + " " + other.Input),
+		terms: append(slices.Clip(s.terms), other.terms...),
+	}
+}
+
+// searchFields are the fields a term can be about, with the operators they
+// accept.
+var searchFields = map[string]func(op, value string) (func(d *Database) (string, []any), error){
+	"tag":      tagCondition,
+	"type":     typeCondition,
+	"ext":      extCondition,
+	"path":     pathCondition,
+	"camera":   cameraCondition,
+	"is":       isCondition,
+	"flag":     flagCondition,
+	"label":    labelCondition,
+	"rating":   numberCondition("media_files.rating", parseRating),
+	"size":     numberCondition("media_files.size", parseSize),
+	"width":    numberCondition("media_files.width", parseCount),
+	"height":   numberCondition("media_files.height", parseCount),
+	"duration": numberCondition("media_files.duration", parseSeconds),
+	"taken": dateCondition(func(cond string) string {
+		return "media_files.id IN (SELECT media_file_id FROM media_metadata WHERE " + cond + ")"
+	}, "media_metadata.captured_at"),
+	"modified": dateCondition(func(cond string) string { return cond }, "media_files.mod_time"),
+}
+
+// searchOps are the operators between a field and its value, longest first.
+var searchOps = []string{">=", "<=", ":", "=", ">", "<"}
+
+// ParseSearch parses the query language of the filter box. An empty input
+// is an empty search.
+func ParseSearch(input string) (Search, error) {
+	search := Search{Input: input}
+	pos := 0
+	for {
+		for pos < len(input) && unicode.IsSpace(rune(input[pos])) {
+			pos++
+		}
+		if pos == len(input) {
+			return search, nil
+		}
+		start := pos
+		var term searchTerm
+		if input[pos] == '-' && pos+1 < len(input) && !unicode.IsSpace(rune(input[pos+1])) {
+			term.negate = true
+			pos++
+		}
+
+		if input[pos] == '"' {
+			phrase, end, err := readQuoted(input, pos)
+			if err != nil {
+				return Search{}, err
+			}
+			pos = end
+			if phrase == "" {
+				return Search{}, &SearchError{Pos: start, Msg: "empty phrase"}
+			}
+			if hasSearchWord(phrase) {
+				term.text = phrase
+				search.terms = append(search.terms, term)
+			}
+			continue
+		}
+
+		// A field name is a run of letters directly followed by an operator
+		nameEnd := pos
+		for nameEnd < len(input) && unicode.IsLetter(rune(input[nameEnd])) {
+			nameEnd++
+		}
+		op := ""
+		if nameEnd > pos {
+			for _, candidate := range searchOps {
+				if strings.HasPrefix(input[nameEnd:], candidate) {
+					op = candidate
+					break
+				}
+			}
+		}
+		if op == "" {
+			end := pos
+			for end < len(input) && !unicode.IsSpace(rune(input[end])) {
+				end++
+			}
+			// Punctuation such as the dash in "beach - sunset" is not a word
+			if hasSearchWord(input[pos:end]) {
+				term.text, term.prefix = input[pos:end], true
+				search.terms = append(search.terms, term)
+			}
+			pos = end
+			continue
+		}
+
+		field := strings.ToLower(input[pos:nameEnd])
+		compile, ok := searchFields[field]
+		if !ok {
+			return Search{}, &SearchError{Pos: pos, Msg: fmt.Sprintf("unknown field %q", field)}
+		}
+		valuePos := nameEnd + len(op)
+		var value string
+		if valuePos < len(input) && input[valuePos] == '"' {
+			quoted, end, err := readQuoted(input, valuePos)
+			if err != nil {
+				return Search{}, err
+			}
+			value, pos = quoted, end
+		} else {
+			end := valuePos
+			for end < len(input) && !unicode.IsSpace(rune(input[end])) {
+				end++
+			}
+			value, pos = input[valuePos:end], end
+		}
+		if value == "" {
+			return Search{}, &SearchError{Pos: valuePos, Msg: fmt.Sprintf("missing value for %s", field)}
+		}
+		cond, err := compile(op, value)
+		if err != nil {
+			return Search{}, &SearchError{Pos: valuePos, Msg: fmt.Sprintf("%s: %v", field, err)}
+		}
+		term.cond = cond
+		search.terms = append(search.terms, term)
+	}
+}
+
+// readQuoted reads the double-quoted string starting at input[pos] and
+// returns its contents and the offset after the closing quote.
+func readQuoted(input string, pos int) (string, int, error) {
+	end := strings.IndexByte(input[pos+1:], '"')
+	if end < 0 {
+		return "", 0, &SearchError{Pos: pos, Msg: "unterminated quote"}
+	}
+	return input[pos+1 : pos+1+end], pos + end + 2, nil
+}
+
+// where applies the search to tx.
+func (s Search) where(d *Database, tx *gorm.DB) *gorm.DB {
+	for _, term := range s.terms {
+		var cond string
+		var args []any
+		if term.cond != nil {
+			cond, args = term.cond(d)
+		} else {
+			cond, args = d.textCondition(term)
+		}
+		if term.negate {
+			cond = "NOT (" + cond + ")"
+		}
+		tx = tx.Where(cond, args...)
+	}
+	return tx
+}
+
+// matchExpr returns the FTS5 query of the search's text terms that are not
+// negated, used to rank the results; "" if there are none.
+func (s Search) matchExpr() string {
+	var words []string
+	for _, term := range s.terms {
+		if term.cond == nil && !term.negate {
+			words = append(words, ftsWord(term.text, term.prefix))
+		}
+	}
+	return strings.Join(words, " AND ")
+}
+
+// textCondition matches a word or phrase against the full-text index, or,
+// without FTS5, as a substring of the path, the notes or the name of a tag
+// or of one of its ancestors.
+func (d *Database) textCondition(term searchTerm) (string, []any) {
+	if d.fts {
+		return "media_files.id IN (SELECT rowid FROM media_search WHERE media_search MATCH ?)",
+			[]any{ftsWord(term.text, term.prefix)}
+	}
+	pattern := "%" + likeEscaper.Replace(term.text) + "%"
+	return "(media_files.path LIKE ? ESCAPE '\\' OR media_files.notes LIKE ? ESCAPE '\\'" +
+		" OR media_files.id IN (" + tagSubtreeCTE + " SELECT file_tags.media_file_id FROM file_tags" +
+		" JOIN subtree ON subtree.id = file_tags.tag_id JOIN tags ON tags.id = subtree.root_id" +
+		" WHERE tags.name LIKE ? ESCAPE '\\'))", []any{pattern, pattern, pattern}
+}
+
+func requireColon(op string) error {
+	if op != ":" && op != "=" {
+		return fmt.Errorf("use : instead of %s", op)
+	}
+	return nil
+}
+
+// tagCondition matches files tagged with the named tag or a tag beneath it.
+// The value is a tag name or a full path such as "Places/Paris".
+func tagCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	return func(d *Database) (string, []any) {
+		var tags []models.Tag
+		if err := d.db.Find(&tags).Error; err != nil {
+			fmt.Printf("[ERROR] Failed to load tags for search: %v\n", err)
+		}
+		var ids []uint
+		for id, path := range TagPaths(tags) {
+			if strings.EqualFold(path, value) {
+				ids = append(ids, id)
+			}
+		}
+		for _, tag := range tags {
+			if strings.EqualFold(tag.Name, value) {
+				ids = append(ids, tag.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return "1 = 0", nil
+		}
+		return "media_files.id IN (?)", []any{d.db.Raw(tagSubtreeCTE+
+			" SELECT file_tags.media_file_id FROM file_tags"+
+			" JOIN subtree ON subtree.id = file_tags.tag_id WHERE subtree.root_id IN ?", ids)}
+	}, nil
+}
+
+func typeCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	kind := models.MediaKind(strings.ToLower(value))
+	switch kind {
+	case models.KindImage, models.KindVideo, models.KindAudio:
+	default:
+		return nil, fmt.Errorf("expected image, video or audio, got %q", value)
+	}
+	return func(*Database) (string, []any) {
+		return "media_files.file_type = ?", []any{string(kind)}
+	}, nil
+}
+
+func extCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	ext := strings.TrimPrefix(value, ".")
+	return func(*Database) (string, []any) {
+		return "media_files.filename LIKE ? ESCAPE '\\'", []any{"%." + likeEscaper.Replace(ext)}
+	}, nil
+}
+
+func pathCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	return func(*Database) (string, []any) {
+		return "media_files.path LIKE ? ESCAPE '\\'", []any{"%" + likeEscaper.Replace(value) + "%"}
+	}, nil
+}
+
+func cameraCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	return func(*Database) (string, []any) {
+		pattern := "%" + likeEscaper.Replace(value) + "%"
+		return "media_files.id IN (SELECT media_file_id FROM media_metadata" +
+			" WHERE camera_make LIKE ? ESCAPE '\\' OR camera_model LIKE ? ESCAPE '\\')", []any{pattern, pattern}
+	}, nil
+}
+
+// isCondition matches files with a flag; "is:mismatch" finds files whose
+// content contradicts their extension, "is:favorite" the favorites.
+func isCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(value) {
+	case "mismatch":
+		return func(*Database) (string, []any) { return "media_files.type_mismatch = ?", []any{true} }, nil
+	case "favorite":
+		return func(*Database) (string, []any) { return "media_files.favorite = ?", []any{true} }, nil
+	}
+	return nil, fmt.Errorf("unknown flag %q", value)
+}
+
+// flagCondition matches picked ("flag:pick"), rejected or unflagged files.
+func flagCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	flag, ok := models.ParseFlag(value)
+	if !ok {
+		return nil, fmt.Errorf("expected pick, reject or none, got %q", value)
+	}
+	return func(*Database) (string, []any) { return "media_files.flag = ?", []any{flag} }, nil
+}
+
+// labelCondition matches files with a color label, or none.
+func labelCondition(op, value string) (func(d *Database) (string, []any), error) {
+	if err := requireColon(op); err != nil {
+		return nil, err
+	}
+	label, ok := models.ParseColorLabel(value)
+	if !ok {
+		return nil, fmt.Errorf("unknown color label %q", value)
+	}
+	return func(*Database) (string, []any) { return "media_files.color_label = ?", []any{label} }, nil
+}
+
+// numberCondition compares a numeric column. ":" and "=" also accept an
+// inclusive range such as "1920..3840", open at either end.
+func numberCondition(column string, parse func(string) (int64, error)) func(op, value string) (func(d *Database) (string, []any), error) {
+	return func(op, value string) (func(d *Database) (string, []any), error) {
+		if low, high, ok := strings.Cut(value, ".."); ok {
+			if err := requireColon(op); err != nil {
+				return nil, err
+			}
+			var conds []string
+			var args []any
+			if low != "" {
+				n, err := parse(low)
+				if err != nil {
+					return nil, err
+				}
+				conds, args = append(conds, column+" >= ?"), append(args, n)
+			}
+			if high != "" {
+				n, err := parse(high)
+				if err != nil {
+					return nil, err
+				}
+				conds, args = append(conds, column+" <= ?"), append(args, n)
+			}
+			if len(conds) == 0 {
+				return nil, fmt.Errorf("empty range")
+			}
+			cond := strings.Join(conds, " AND ")
+			return func(*Database) (string, []any) { return cond, args }, nil
+		}
+		n, err := parse(value)
+		if err != nil {
+			return nil, err
+		}
+		if op == ":" {
+			op = "="
+		}
+		cond := column + " " + op + " ?"
+		return func(*Database) (string, []any) { return cond, []any{n} }, nil
+	}
+}
+
+// sizeUnits are the suffixes of sizes in searches, in binary multiples as
+// sizes are shown elsewhere.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseSize parses a size such as "100MB", "1.5G" or "2048".
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(value)
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSuffix(upper, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+func parseCount(value string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
+
+// parseRating parses a number of stars.
+func parseRating(value string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > models.MaxRating {
+		return 0, fmt.Errorf("expected 0 to %d stars, got %q", models.MaxRating, value)
+	}
+	return n, nil
+}
+
+// parseSeconds parses a duration in seconds ("90") or with units ("1m30s").
+func parseSeconds(value string) (int64, error) {
+	if n, err := parseCount(value); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return int64(d.Seconds()), nil
+}
+
+// dateLayouts are the accepted dates, each covering a year, month or day.
+var dateLayouts = []struct {
+	layout string
+	years  int
+	months int
+	days   int
+}{
+	{"2006-01-02", 0, 0, 1},
+	{"2006-01", 0, 1, 0},
+	{"2006", 1, 0, 0},
+}
+
+// parsePeriod parses a date and returns the local time span [start, end)
+// it covers: a year, month or day.
+func parsePeriod(value string) (time.Time, time.Time, error) {
+	for _, layout := range dateLayouts {
+		if len(value) != len(layout.layout) {
+			continue
+		}
+		start, err := time.ParseInLocation(layout.layout, value, time.Local)
+		if err == nil {
+			return start, start.AddDate(layout.years, layout.months, layout.days), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q, expected YYYY, YYYY-MM or YYYY-MM-DD", value)
+}
+
+// dateCondition compares a date column with a year, month or day. ":" and
+// "=" match the whole period and also accept a range such as
+// "2023-06..2023-08", which includes both ends. wrap turns the comparison
+// into a condition on media_files.
+func dateCondition(wrap func(string) string, column string) func(op, value string) (func(d *Database) (string, []any), error) {
+	return func(op, value string) (func(d *Database) (string, []any), error) {
+		var conds []string
+		var args []any
+		from, to := value, value
+		if low, high, ok := strings.Cut(value, ".."); ok {
+			if err := requireColon(op); err != nil {
+				return nil, err
+			}
+			from, to = low, high
+			if from == "" && to == "" {
+				return nil, fmt.Errorf("empty range")
+			}
+		}
+		if from != "" && (op == ":" || op == "=" || op == ">=" || op == ">") {
+			start, end, err := parsePeriod(from)
+			if err != nil {
+				return nil, err
+			}
+			if op == ">" {
+				start = end
+			}
+			conds, args = append(conds, column+" >= ?"), append(args, start)
+		}
+		if to != "" && (op == ":" || op == "=" || op == "<=" || op == "<") {
+			start, end, err := parsePeriod(to)
+			if err != nil {
+				return nil, err
+			}
+			if op == "<" {
+				end = start
+			}
+			conds, args = append(conds, column+" < ?"), append(args, end)
+		}
+		cond := wrap(strings.Join(conds, " AND "))
+		return func(*Database) (string, []any) { return cond, args }, nil
+	}
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestSearch(t *testing.T) {
+	database := newTestDatabase(t)
+	june := time.Date(2023, 6, 15, 12, 0, 0, 0, time.Local)
+	files := []models.MediaFile{
+		{Path: "/media/beach.jpg", Filename: "beach.jpg", FileType: "image", Size: 3 << 20, Width: 4000, Height: 3000, ModTime: june},
+		{Path: "/media/beach party.mp4", Filename: "beach party.mp4", FileType: "video", Size: 200 << 20, Width: 3840, Height: 2160, Duration: 95, ModTime: june},
+		{Path: "/media/private/secret.jpg", Filename: "secret.jpg", FileType: "image", Size: 1 << 20, Width: 1920, Height: 1080, ModTime: june.AddDate(1, 0, 0)},
+		{Path: "/media/renamed.mp4", Filename: "renamed.mp4", FileType: "image", Size: 1 << 10, TypeMismatch: true, ModTime: june},
+	}
+	for i := range files {
+		if err := database.GetDB().Create(&files[i]).Error; err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+	taken := june.AddDate(0, 1, 0)
+	if err := database.ReplaceMediaMetadata(map[uint]*models.MediaMetadata{
+		files[0].ID: {CameraMake: "Canon", CameraModel: "EOS R5", CapturedAt: &taken},
+	}); err != nil {
+		t.Fatalf("Failed to store metadata: %v", err)
+	}
+	places, err := database.GetOrCreateTag("Places/Beach")
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	private, err := database.GetOrCreateTag("private")
+	if err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := database.AddTagToFiles(places.ID, []uint{files[0].ID, files[1].ID}); err != nil {
+		t.Fatalf("Failed to tag files: %v", err)
+	}
+	if err := database.AddTagToFiles(private.ID, []uint{files[1].ID}); err != nil {
+		t.Fatalf("Failed to tag files: %v", err)
+	}
+
+	cases := map[string][]string{
+		"":                                {"beach party.mp4", "beach.jpg", "renamed.mp4", "secret.jpg"},
+		"beach":                           {"beach party.mp4", "beach.jpg"},
+		`"beach party"`:                   {"beach party.mp4"},
+		"-beach":                          {"renamed.mp4", "secret.jpg"},
+		"type:video":                      {"beach party.mp4"},
+		"tag:places":                      {"beach party.mp4", "beach.jpg"},
+		"tag:Places/Beach -tag:private":   {"beach.jpg"},
+		"size>100MB":                      {"beach party.mp4"},
+		"size:1m..4M":                     {"beach.jpg", "secret.jpg"},
+		"width>=3840 height<3000":         {"beach party.mp4"},
+		"duration>1m30s":                  {"beach party.mp4"},
+		"taken:2023-06..2023-08":          {"beach.jpg"},
+		"taken:2023-06":                   {},
+		"modified<2024":                   {"beach party.mp4", "beach.jpg", "renamed.mp4"},
+		"modified:2024":                   {"secret.jpg"},
+		"camera:canon":                    {"beach.jpg"},
+		"ext:mp4 is:mismatch":             {"renamed.mp4"},
+		`path:private`:                    {"secret.jpg"},
+		`tag:"Places/Beach" type:image`:   {"beach.jpg"},
+		"tag:nonexistent":                 {},
+		"-tag:nonexistent type:video":     {"beach party.mp4"},
+		"  Beach   TYPE:image  ":          {"beach.jpg"},
+		`-"party" beach`:                  {"beach.jpg"},
+		"size<=1KB":                       {"renamed.mp4"},
+		"width:1920..":                    {"beach party.mp4", "beach.jpg", "secret.jpg"},
+		"is:mismatch -ext:mp4":            {},
+		"beach ext:jpg -camera:nikon":     {"beach.jpg"},
+		"modified>2023-06-15 type:image":  {"secret.jpg"},
+		"modified>=2023-06-15 type:video": {"beach party.mp4"},
+		"beach - party & -":               {"beach party.mp4"},
+		`"--" -& type:video`:              {"beach party.mp4"},
+	}
+	for input, want := range cases {
+		search, err := ParseSearch(input)
+		if err != nil {
+			t.Errorf("ParseSearch(%q) failed: %v", input, err)
+			continue
+		}
+		matched, err := database.QueryMediaFiles(MediaQuery{Search: search})
+		if err != nil {
+			t.Errorf("Query %q failed: %v", input, err)
+			continue
+		}
+		var got []string
+		for _, file := range matched {
+			got = append(got, file.Filename)
+		}
+		if !slices.Equal(got, want) && !(len(got) == 0 && len(want) == 0) {
+			t.Errorf("Search %q: expected %v, got %v", input, want, got)
+		}
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	cases := map[string]int{
+		`beach "unterminated`: 6,
+		"colour:red":          0,
+		"size>big":            5,
+		"type:document":       5,
+		"taken:June":          6,
+		"tag>beach":           4,
+		"width:":              6,
+		"is:favourite":        3,
+		`""`:                  0,
+	}
+	for input, pos := range cases {
+		_, err := ParseSearch(input)
+		var searchErr *SearchError
+		if !errors.As(err, &searchErr) {
+			t.Errorf("Expected a syntax error for %q, got %v", input, err)
+			continue
+		}
+		if searchErr.Pos != pos {
+			t.Errorf("Expected the error for %q at %d, got %d (%v)", input, pos, searchErr.Pos, err)
+		}
+	}
+}
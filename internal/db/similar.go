@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// SimilarMediaFile is a media file whose perceptual hash lies within the
+// requested Hamming distance of another file's hash.
+type SimilarMediaFile struct {
+	File     models.MediaFile
+	Distance int
+}
+
+// SetPerceptualHash stores the perceptual hash of a media file.
+func (d *Database) SetPerceptualHash(id uint, hash uint64) error {
+	err := d.db.Model(&models.MediaFile{}).Where("id = ?", id).Update("perceptual_hash", int64(hash)).Error
+	if err != nil {
+		return err
+	}
+	d.invalidateSimilarityIndex()
+	return nil
+}
+
+// FindSimilarMediaFiles returns the present files whose perceptual hash is at
+// most maxDistance bits away from that of the file with the given ID, closest
+// first. The file itself is not included. A file without a perceptual hash
+// has no similar files.
+func (d *Database) FindSimilarMediaFiles(id uint, maxDistance int) ([]SimilarMediaFile, error) {
+	var file models.MediaFile
+	if err := d.db.First(&file, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load media file %d: %w", id, err)
+	}
+	if file.PerceptualHash == nil {
+		return nil, nil
+	}
+
+	index, err := d.similarityIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	distances := make(map[uint]int)
+	index.search(uint64(*file.PerceptualHash), maxDistance, func(match uint, distance int) {
+		if match != id {
+			distances[match] = distance
+		}
+	})
+	if len(distances) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint, 0, len(distances))
+	for match := range distances {
+		ids = append(ids, match)
+	}
+	var files []models.MediaFile
+	if err := d.db.Where("id IN ? AND missing = ?", ids, false).Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	similar := make([]SimilarMediaFile, len(files))
+	for i, match := range files {
+		similar[i] = SimilarMediaFile{File: match, Distance: distances[match.ID]}
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Distance != similar[j].Distance {
+			return similar[i].Distance < similar[j].Distance
+		}
+		return similar[i].File.Path < similar[j].File.Path
+	})
+	return similar, nil
+}
+
+// similarityIndex returns the BK-tree of all stored perceptual hashes,
+// rebuilding it first if hashes were written or files removed since it was
+// last built.
+func (d *Database) similarityIndex() (*bkTree, error) {
+	d.similarMu.Lock()
+	defer d.similarMu.Unlock()
+	if d.similar != nil {
+		return d.similar, nil
+	}
+
+	var rows []struct {
+		ID             uint
+		PerceptualHash int64
+	}
+	err := d.db.Model(&models.MediaFile{}).
+		Select("id, perceptual_hash").
+		Where("perceptual_hash IS NOT NULL").
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load perceptual hashes: %w", err)
+	}
+
+	index := &bkTree{}
+	for _, row := range rows {
+		index.add(uint64(row.PerceptualHash), row.ID)
+	}
+	fmt.Printf("[DEBUG] Built similarity index with %d hashes\n", index.size)
+	d.similar = index
+	return index, nil
+}
+
+// invalidateSimilarityIndex drops the in-memory BK-tree so the next query
+// rebuilds it from the database.
+func (d *Database) invalidateSimilarityIndex() {
+	d.similarMu.Lock()
+	d.similar = nil
+	d.similarMu.Unlock()
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestBKTreeSearch(t *testing.T) {
+	hashes := []uint64{0x0, 0x1, 0x3, 0xff, 0xffff, 0x0}
+	var tree bkTree
+	for i, hash := range hashes {
+		tree.add(hash, uint(i))
+	}
+
+	found := make(map[uint]int)
+	tree.search(0x0, 2, func(id uint, distance int) { found[id] = distance })
+	want := map[uint]int{0: 0, 1: 1, 2: 2, 5: 0}
+	if len(found) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, found)
+	}
+	for id, distance := range want {
+		if found[id] != distance {
+			t.Errorf("Expected id %d at distance %d, got %v", id, distance, found)
+		}
+	}
+}
+
+func TestFindSimilarMediaFiles(t *testing.T) {
+	database := newTestDatabase(t)
+	files := []models.MediaFile{
+		{Path: "/a/original.jpg"},
+		{Path: "/a/resized.jpg"},
+		{Path: "/a/other.jpg"},
+		{Path: "/a/gone.jpg", Missing: true},
+		{Path: "/a/unhashed.jpg"},
+	}
+	for i := range files {
+		if err := database.GetDB().Create(&files[i]).Error; err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+	hashes := map[uint]uint64{
+		files[0].ID: 0xF0F0F0F0F0F0F0F0,
+		files[1].ID: 0xF0F0F0F0F0F0F0F3,
+		files[2].ID: 0x0F0F0F0F0F0F0F0F,
+		files[3].ID: 0xF0F0F0F0F0F0F0F0,
+	}
+	for id, hash := range hashes {
+		if err := database.SetPerceptualHash(id, hash); err != nil {
+			t.Fatalf("SetPerceptualHash failed: %v", err)
+		}
+	}
+
+	similar, err := database.FindSimilarMediaFiles(files[0].ID, 10)
+	if err != nil {
+		t.Fatalf("FindSimilarMediaFiles failed: %v", err)
+	}
+	if len(similar) != 1 || similar[0].File.ID != files[1].ID || similar[0].Distance != 2 {
+		t.Fatalf("Expected only the resized copy at distance 2, got %+v", similar)
+	}
+
+	// The index is rebuilt after hashes change
+	if err := database.SetPerceptualHash(files[2].ID, 0xF0F0F0F0F0F0F0F1); err != nil {
+		t.Fatalf("SetPerceptualHash failed: %v", err)
+	}
+	similar, err = database.FindSimilarMediaFiles(files[0].ID, 10)
+	if err != nil {
+		t.Fatalf("FindSimilarMediaFiles failed: %v", err)
+	}
+	if len(similar) != 2 || similar[0].File.ID != files[2].ID {
+		t.Fatalf("Expected the updated file first, got %+v", similar)
+	}
+
+	similar, err = database.FindSimilarMediaFiles(files[4].ID, 10)
+	if err != nil || len(similar) != 0 {
+		t.Errorf("Expected no matches for a file without a hash, got %+v, %v", similar, err)
+	}
+}
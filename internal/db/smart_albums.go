@@ -0,0 +1,90 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// SmartAlbumWithCount is a smart album together with the number of present
+// files matching its query.
+type SmartAlbumWithCount struct {
+	models.SmartAlbum
+	FileCount int64
+}
+
+// checkSmartAlbum validates the name and query of a smart album with the
+// given ID (0 for a new one) and returns the trimmed name.
+func (d *Database) checkSmartAlbum(id uint, name, query string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("smart album name must not be empty")
+	}
+	if _, err := ParseSearch(query); err != nil {
+		return "", fmt.Errorf("invalid query: %w", err)
+	}
+	var count int64
+	err := d.db.Model(&models.SmartAlbum{}).Where("name = ? AND id <> ?", name, id).Count(&count).Error
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", fmt.Errorf("a smart album named %q already exists", name)
+	}
+	return name, nil
+}
+
+// CreateSmartAlbum saves query, in the query language of the filter box, as
+// a smart album.
+func (d *Database) CreateSmartAlbum(name, query string) (*models.SmartAlbum, error) {
+	name, err := d.checkSmartAlbum(0, name, query)
+	if err != nil {
+		return nil, err
+	}
+	album := &models.SmartAlbum{Name: name, Query: strings.TrimSpace(query)}
+	if err := d.db.Create(album).Error; err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// UpdateSmartAlbum renames a smart album and replaces its query.
+func (d *Database) UpdateSmartAlbum(id uint, name, query string) error {
+	name, err := d.checkSmartAlbum(id, name, query)
+	if err != nil {
+		return err
+	}
+	return d.db.Model(&models.SmartAlbum{}).Where("id = ?", id).
+		Updates(map[string]any{"name": name, "query": strings.TrimSpace(query)}).Error
+}
+
+// DeleteSmartAlbum removes a smart album; the files it matched are not
+// touched.
+func (d *Database) DeleteSmartAlbum(id uint) error {
+	return d.db.Delete(&models.SmartAlbum{}, id).Error
+}
+
+// GetSmartAlbumsWithCounts returns every smart album sorted by name, with
+// the number of present files matching it. Counts are computed on each call,
+// so they follow the library as the scanner adds and removes files.
+func (d *Database) GetSmartAlbumsWithCounts() ([]SmartAlbumWithCount, error) {
+	var albums []models.SmartAlbum
+	if err := d.db.Order("name COLLATE NOCASE").Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	counted := make([]SmartAlbumWithCount, len(albums))
+	for i, album := range albums {
+		counted[i].SmartAlbum = album
+		search, err := ParseSearch(album.Query)
+		if err != nil {
+			fmt.Printf("[WARN] Smart album %q has an invalid query: %v\n", album.Name, err)
+			continue
+		}
+		counted[i].FileCount, err = d.CountMediaFiles(MediaQuery{Search: search})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count smart album %q: %w", album.Name, err)
+		}
+	}
+	return counted, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestSmartAlbums(t *testing.T) {
+	database := newTestDatabase(t)
+	insert := func(file models.MediaFile) models.MediaFile {
+		t.Helper()
+		if err := database.CreateMediaFile(&file); err != nil {
+			t.Fatalf("Failed to insert %s: %v", file.Path, err)
+		}
+		return file
+	}
+	insert(models.MediaFile{Path: "/media/clip.mp4", Filename: "clip.mp4", FileType: "video", Size: 300 << 20})
+	insert(models.MediaFile{Path: "/media/photo.jpg", Filename: "photo.jpg", FileType: "image", Size: 2 << 20})
+
+	album, err := database.CreateSmartAlbum(" Big videos ", "type:video size>100MB")
+	if err != nil {
+		t.Fatalf("Failed to create smart album: %v", err)
+	}
+	if album.Name != "Big videos" {
+		t.Errorf("Expected the name to be trimmed, got %q", album.Name)
+	}
+	if _, err := database.CreateSmartAlbum("Big videos", "type:video"); err == nil {
+		t.Errorf("Expected a duplicate name to be rejected")
+	}
+	if _, err := database.CreateSmartAlbum("Broken", "size>lots"); err == nil {
+		t.Errorf("Expected an invalid query to be rejected")
+	}
+	if _, err := database.CreateSmartAlbum("Images", "type:image"); err != nil {
+		t.Fatalf("Failed to create smart album: %v", err)
+	}
+
+	counts := func() map[string]int64 {
+		t.Helper()
+		albums, err := database.GetSmartAlbumsWithCounts()
+		if err != nil {
+			t.Fatalf("Failed to load smart albums: %v", err)
+		}
+		byName := make(map[string]int64, len(albums))
+		for _, album := range albums {
+			byName[album.Name] = album.FileCount
+		}
+		return byName
+	}
+	if got := counts(); got["Big videos"] != 1 || got["Images"] != 1 || len(got) != 2 {
+		t.Errorf("Unexpected counts: %v", got)
+	}
+
+	// Counts follow files the scanner adds and marks missing
+	second := insert(models.MediaFile{Path: "/media/trip.mov", Filename: "trip.mov", FileType: "video", Size: 1 << 30})
+	if got := counts(); got["Big videos"] != 2 {
+		t.Errorf("Expected the new video to be counted, got %v", got)
+	}
+	if err := database.SaveScanResults(nil, nil, []uint{second.ID}); err != nil {
+		t.Fatalf("Failed to mark file missing: %v", err)
+	}
+	if got := counts(); got["Big videos"] != 1 {
+		t.Errorf("Expected the missing video not to be counted, got %v", got)
+	}
+
+	if err := database.UpdateSmartAlbum(album.ID, "Images", "type:video"); err == nil {
+		t.Errorf("Expected renaming onto another album to be rejected")
+	}
+	if err := database.UpdateSmartAlbum(album.ID, "All videos", "type:video"); err != nil {
+		t.Fatalf("Failed to update smart album: %v", err)
+	}
+	if err := database.DeleteSmartAlbum(album.ID); err != nil {
+		t.Fatalf("Failed to delete smart album: %v", err)
+	}
+	if got := counts(); len(got) != 1 || got["Images"] != 1 {
+		t.Errorf("Expected only the image album left, got %v", got)
+	}
+
+	// The filter box narrows a smart album down
+	images, _ := ParseSearch("type:image")
+	filter, _ := ParseSearch("clip")
+	if count, err := database.CountMediaFiles(MediaQuery{Search: images.And(filter)}); err != nil || count != 0 {
+		t.Errorf("Expected no image named clip, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,329 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// fileTag is a row of the file_tags join table behind MediaFile.Tags.
+type fileTag struct {
+	MediaFileID uint `gorm:"primaryKey"`
+	TagID       uint `gorm:"primaryKey"`
+}
+
+func (fileTag) TableName() string { return "file_tags" }
+
+// TagWithCount is a tag together with the number of present files carrying it.
+type TagWithCount struct {
+	models.Tag
+	FileCount int64
+}
+
+// TagPathSeparator separates the levels of a tag path such as
+// "Places/Paris/Louvre".
+const TagPathSeparator = "/"
+
+// tagSubtreeCTE pairs every tag (root_id) with itself and each of its
+// descendants (id).
+const tagSubtreeCTE = "WITH RECURSIVE subtree(root_id, id) AS (" +
+	"SELECT id, id FROM tags " +
+	"UNION SELECT subtree.root_id, tags.id FROM tags JOIN subtree ON tags.parent_id = subtree.id)"
+
+// migrateTagNames replaces the global unique index on tags.name of older
+// databases with uniqueness among siblings.
+func migrateTagNames(db *gorm.DB) error {
+	if err := db.Exec("DROP INDEX IF EXISTS idx_tags_name").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_parent_name ON tags(COALESCE(parent_id, 0), name)").Error
+}
+
+// TagPaths returns the full path ("Places/Paris") of every given tag, keyed
+// by tag ID. tags must contain the ancestors of every tag.
+func TagPaths(tags []models.Tag) map[uint]string {
+	byID := make(map[uint]models.Tag, len(tags))
+	for _, tag := range tags {
+		byID[tag.ID] = tag
+	}
+	paths := make(map[uint]string, len(tags))
+	var pathOf func(tag models.Tag, depth int) string
+	pathOf = func(tag models.Tag, depth int) string {
+		if path, ok := paths[tag.ID]; ok {
+			return path
+		}
+		path := tag.Name
+		// depth guards against a corrupt parent cycle
+		if tag.ParentID != nil && depth < len(tags) {
+			if parent, ok := byID[*tag.ParentID]; ok {
+				path = pathOf(parent, depth+1) + TagPathSeparator + tag.Name
+			}
+		}
+		paths[tag.ID] = path
+		return path
+	}
+	for _, tag := range tags {
+		pathOf(tag, 0)
+	}
+	return paths
+}
+
+// GetTagsWithCounts returns every tag sorted by name, with the number of
+// present (not missing) files tagged with it or with a tag beneath it.
+func (d *Database) GetTagsWithCounts() ([]TagWithCount, error) {
+	var tags []TagWithCount
+	err := d.db.Raw(tagSubtreeCTE+
+		" SELECT tags.*, COUNT(DISTINCT media_files.id) AS file_count FROM tags"+
+		" JOIN subtree ON subtree.root_id = tags.id"+
+		" LEFT JOIN file_tags ON file_tags.tag_id = subtree.id"+
+		" LEFT JOIN media_files ON media_files.id = file_tags.media_file_id AND media_files.missing = ?"+
+		" GROUP BY tags.id ORDER BY tags.name COLLATE NOCASE", false).
+		Scan(&tags).Error
+	return tags, err
+}
+
+// GetTagDescendantIDs returns the IDs of the tag and of every tag beneath it.
+func (d *Database) GetTagDescendantIDs(tagID uint) ([]uint, error) {
+	var ids []uint
+	err := d.db.Raw(tagSubtreeCTE+" SELECT id FROM subtree WHERE root_id = ?", tagID).Scan(&ids).Error
+	return ids, err
+}
+
+// GetOrCreateTag returns the tag at the given path, such as "beach" or
+// "Places/Paris/Louvre", creating it and any missing ancestors.
+func (d *Database) GetOrCreateTag(path string) (*models.Tag, error) {
+	var names []string
+	for _, name := range strings.Split(path, TagPathSeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("tag name must not be empty")
+	}
+
+	var tag models.Tag
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var parentID *uint
+		for _, name := range names {
+			tag = models.Tag{Name: name, ParentID: parentID}
+			if err := whereSibling(tx, parentID).Where("name = ?", name).FirstOrCreate(&tag).Error; err != nil {
+				return fmt.Errorf("failed to create tag %q: %w", name, err)
+			}
+			id := tag.ID
+			parentID = &id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// whereSibling restricts a tag query to the children of parentID, or to
+// top-level tags when parentID is nil.
+func whereSibling(tx *gorm.DB, parentID *uint) *gorm.DB {
+	if parentID == nil {
+		return tx.Where("parent_id IS NULL")
+	}
+	return tx.Where("parent_id = ?", *parentID)
+}
+
+// checkTagName rejects empty names, names containing the path separator and
+// names already used by a sibling other than tagID.
+func checkTagName(tx *gorm.DB, tagID uint, parentID *uint, name string) error {
+	if name == "" {
+		return fmt.Errorf("tag name must not be empty")
+	}
+	if strings.Contains(name, TagPathSeparator) {
+		return fmt.Errorf("tag name %q must not contain %q", name, TagPathSeparator)
+	}
+	var clashes int64
+	err := whereSibling(tx.Model(&models.Tag{}), parentID).
+		Where("name = ? AND id != ?", name, tagID).
+		Count(&clashes).Error
+	if err != nil {
+		return err
+	}
+	if clashes > 0 {
+		return fmt.Errorf("a tag named %q already exists there", name)
+	}
+	return nil
+}
+
+// AddTagToFiles tags every given file. Files that already carry the tag are
+// left alone.
+func (d *Database) AddTagToFiles(tagID uint, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	rows := make([]fileTag, len(fileIDs))
+	for i, fileID := range fileIDs {
+		rows[i] = fileTag{MediaFileID: fileID, TagID: tagID}
+	}
+	return d.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 500).Error
+}
+
+// RemoveTagFromFiles removes the tag from every given file.
+func (d *Database) RemoveTagFromFiles(tagID uint, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return d.db.Where("tag_id = ? AND media_file_id IN ?", tagID, fileIDs).Delete(&fileTag{}).Error
+}
+
+// GetTagsForFiles returns the tags of each given file, keyed by file ID and
+// sorted by name.
+func (d *Database) GetTagsForFiles(fileIDs []uint) (map[uint][]models.Tag, error) {
+	tags := make(map[uint][]models.Tag)
+	for start := 0; start < len(fileIDs); start += 500 {
+		batch := fileIDs[start:min(start+500, len(fileIDs))]
+		var rows []struct {
+			MediaFileID uint
+			models.Tag
+		}
+		err := d.db.Model(&models.Tag{}).
+			Select("file_tags.media_file_id, tags.*").
+			Joins("JOIN file_tags ON file_tags.tag_id = tags.id").
+			Where("file_tags.media_file_id IN ?", batch).
+			Order("tags.name COLLATE NOCASE").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			tags[row.MediaFileID] = append(tags[row.MediaFileID], row.Tag)
+		}
+	}
+	return tags, nil
+}
+
+// GetMediaFilesByTag returns the present files carrying the tag or any tag
+// beneath it, sorted by path.
+func (d *Database) GetMediaFilesByTag(tagID uint) ([]models.MediaFile, error) {
+	return d.QueryMediaFiles(MediaQuery{TagID: tagID, Sort: SortByPath})
+}
+
+// RenameTag gives a tag a new name. Renaming to the name of a sibling is an
+// error; merge the tags instead.
+func (d *Database) RenameTag(tagID uint, name string) error {
+	name = strings.TrimSpace(name)
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.First(&tag, tagID).Error; err != nil {
+			return err
+		}
+		if err := checkTagName(tx, tagID, tag.ParentID, name); err != nil {
+			return err
+		}
+		return tx.Model(&tag).Update("name", name).Error
+	})
+}
+
+// SetTagParent moves a tag, with everything beneath it, under parentID, or
+// to the top level when parentID is nil.
+func (d *Database) SetTagParent(tagID uint, parentID *uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.First(&tag, tagID).Error; err != nil {
+			return err
+		}
+		if parentID != nil {
+			var inside int64
+			err := tx.Raw(tagSubtreeCTE+" SELECT COUNT(*) FROM subtree WHERE root_id = ? AND id = ?", tagID, *parentID).
+				Scan(&inside).Error
+			if err != nil {
+				return err
+			}
+			if inside > 0 {
+				return fmt.Errorf("cannot move tag %q beneath itself", tag.Name)
+			}
+		}
+		if err := checkTagName(tx, tagID, parentID, tag.Name); err != nil {
+			return err
+		}
+		return tx.Model(&tag).Update("parent_id", parentID).Error
+	})
+}
+
+// SetTagColor sets the hex color ("#rrggbb") a tag is displayed with.
+func (d *Database) SetTagColor(tagID uint, color string) error {
+	return d.db.Model(&models.Tag{}).Where("id = ?", tagID).Update("color", color).Error
+}
+
+// MergeTags moves every file tagged with one of sourceIDs over to targetID
+// and deletes the source tags. Tags beneath a source move beneath the target.
+func (d *Database) MergeTags(sourceIDs []uint, targetID uint) error {
+	var sources []uint
+	for _, id := range sourceIDs {
+		if id != targetID {
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var inside int64
+		err := tx.Raw(tagSubtreeCTE+" SELECT COUNT(*) FROM subtree WHERE root_id IN ? AND id = ?", sources, targetID).
+			Scan(&inside).Error
+		if err != nil {
+			return err
+		}
+		if inside > 0 {
+			return fmt.Errorf("cannot merge a tag into a tag beneath it")
+		}
+		err = tx.Exec("INSERT OR IGNORE INTO file_tags (media_file_id, tag_id) "+
+			"SELECT media_file_id, ? FROM file_tags WHERE tag_id IN ?", targetID, sources).Error
+		if err != nil {
+			return fmt.Errorf("failed to retag files: %w", err)
+		}
+		if err := tx.Where("tag_id IN ?", sources).Delete(&fileTag{}).Error; err != nil {
+			return fmt.Errorf("failed to untag files: %w", err)
+		}
+		if err := tx.Delete(&models.Tag{}, sources).Error; err != nil {
+			return fmt.Errorf("failed to delete merged tags: %w", err)
+		}
+		return reparentChildren(tx, sources, &targetID)
+	})
+}
+
+// DeleteTag removes a tag from every file and deletes it. Tags beneath it
+// move up one level.
+func (d *Database) DeleteTag(tagID uint) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.First(&tag, tagID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", tagID).Delete(&fileTag{}).Error; err != nil {
+			return fmt.Errorf("failed to untag files: %w", err)
+		}
+		if err := tx.Delete(&models.Tag{}, tagID).Error; err != nil {
+			return err
+		}
+		return reparentChildren(tx, []uint{tagID}, tag.ParentID)
+	})
+}
+
+// reparentChildren moves the children of parentIDs beneath newParentID.
+func reparentChildren(tx *gorm.DB, parentIDs []uint, newParentID *uint) error {
+	var children []models.Tag
+	if err := tx.Where("parent_id IN ?", parentIDs).Find(&children).Error; err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := checkTagName(tx, child.ID, newParentID, child.Name); err != nil {
+			return fmt.Errorf("cannot move %q: %w", child.Name, err)
+		}
+		if err := tx.Model(&child).Update("parent_id", newParentID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
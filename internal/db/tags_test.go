@@ -0,0 +1,178 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func tagNames(tags []models.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+func TestTagManagement(t *testing.T) {
+	database := newTestDatabase(t)
+	files := []models.MediaFile{
+		{Path: "/photos/a.jpg"},
+		{Path: "/photos/b.jpg"},
+		{Path: "/photos/c.jpg", Missing: true},
+	}
+	for i := range files {
+		if err := database.GetDB().Create(&files[i]).Error; err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+	ids := []uint{files[0].ID, files[1].ID, files[2].ID}
+
+	beach, err := database.GetOrCreateTag(" beach ")
+	if err != nil {
+		t.Fatalf("GetOrCreateTag failed: %v", err)
+	}
+	again, _ := database.GetOrCreateTag("beach")
+	if again.ID != beach.ID || beach.Name != "beach" {
+		t.Fatalf("Expected the same trimmed tag, got %+v and %+v", beach, again)
+	}
+	sea, _ := database.GetOrCreateTag("Sea")
+
+	if err := database.AddTagToFiles(beach.ID, ids); err != nil {
+		t.Fatalf("AddTagToFiles failed: %v", err)
+	}
+	// Adding twice is a no-op
+	if err := database.AddTagToFiles(beach.ID, ids[:1]); err != nil {
+		t.Fatalf("AddTagToFiles failed on an existing tag: %v", err)
+	}
+	database.AddTagToFiles(sea.ID, ids[:1])
+	if err := database.RemoveTagFromFiles(beach.ID, ids[1:2]); err != nil {
+		t.Fatalf("RemoveTagFromFiles failed: %v", err)
+	}
+
+	counts, err := database.GetTagsWithCounts()
+	if err != nil {
+		t.Fatalf("GetTagsWithCounts failed: %v", err)
+	}
+	if len(counts) != 2 || counts[0].Name != "beach" || counts[0].FileCount != 1 || counts[1].FileCount != 1 {
+		t.Fatalf("Unexpected counts (missing files must not count): %+v", counts)
+	}
+
+	byFile, err := database.GetTagsForFiles(ids)
+	if err != nil {
+		t.Fatalf("GetTagsForFiles failed: %v", err)
+	}
+	if names := tagNames(byFile[files[0].ID]); len(names) != 2 || names[0] != "beach" || names[1] != "Sea" {
+		t.Errorf("Unexpected tags of a.jpg: %v", names)
+	}
+	if len(byFile[files[1].ID]) != 0 {
+		t.Errorf("Expected b.jpg to be untagged, got %v", tagNames(byFile[files[1].ID]))
+	}
+
+	if err := database.RenameTag(sea.ID, "beach"); err == nil {
+		t.Errorf("Expected renaming onto an existing name to fail")
+	}
+	if err := database.RenameTag(sea.ID, "ocean"); err != nil {
+		t.Fatalf("RenameTag failed: %v", err)
+	}
+	if err := database.SetTagColor(sea.ID, "#3366ff"); err != nil {
+		t.Fatalf("SetTagColor failed: %v", err)
+	}
+
+	// Merging keeps a single association per file and drops the source tag
+	if err := database.MergeTags([]uint{sea.ID}, beach.ID); err != nil {
+		t.Fatalf("MergeTags failed: %v", err)
+	}
+	tags, _ := database.GetTags()
+	if len(tags) != 1 || tags[0].ID != beach.ID {
+		t.Fatalf("Expected only the merge target to remain, got %+v", tags)
+	}
+	tagged, err := database.GetMediaFilesByTag(beach.ID)
+	if err != nil {
+		t.Fatalf("GetMediaFilesByTag failed: %v", err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != files[0].ID {
+		t.Errorf("Expected only a.jpg to be tagged, got %+v", tagged)
+	}
+
+	if err := database.DeleteTag(beach.ID); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	var links int64
+	database.GetDB().Table("file_tags").Count(&links)
+	if links != 0 {
+		t.Errorf("Expected no file_tags rows left, got %d", links)
+	}
+}
+
+func TestTagHierarchy(t *testing.T) {
+	database := newTestDatabase(t)
+	files := []models.MediaFile{
+		{Path: "/photos/louvre.jpg"},
+		{Path: "/photos/eiffel.jpg"},
+		{Path: "/photos/alice.jpg"},
+	}
+	for i := range files {
+		if err := database.GetDB().Create(&files[i]).Error; err != nil {
+			t.Fatalf("Failed to insert test record: %v", err)
+		}
+	}
+
+	louvre, err := database.GetOrCreateTag("Places / Paris/Louvre")
+	if err != nil {
+		t.Fatalf("GetOrCreateTag failed: %v", err)
+	}
+	paris, _ := database.GetOrCreateTag("Places/Paris")
+	places, _ := database.GetOrCreateTag("Places")
+	if louvre.ParentID == nil || *louvre.ParentID != paris.ID || paris.ParentID == nil || *paris.ParentID != places.ID {
+		t.Fatalf("Expected Places > Paris > Louvre, got %+v %+v %+v", places, paris, louvre)
+	}
+	// The same name may be used beneath different parents
+	alice, _ := database.GetOrCreateTag("People/Alice")
+	if other, _ := database.GetOrCreateTag("Places/Alice"); other.ID == alice.ID {
+		t.Errorf("Expected People/Alice and Places/Alice to be different tags")
+	}
+
+	database.AddTagToFiles(louvre.ID, []uint{files[0].ID})
+	database.AddTagToFiles(paris.ID, []uint{files[0].ID, files[1].ID})
+	database.AddTagToFiles(alice.ID, []uint{files[2].ID})
+
+	tagged, err := database.GetMediaFilesByTag(places.ID)
+	if err != nil {
+		t.Fatalf("GetMediaFilesByTag failed: %v", err)
+	}
+	if len(tagged) != 2 || tagged[0].Path != "/photos/eiffel.jpg" || tagged[1].Path != "/photos/louvre.jpg" {
+		t.Errorf("Expected the files tagged beneath Places once each, got %+v", tagged)
+	}
+
+	counts, _ := database.GetTagsWithCounts()
+	for _, tag := range counts {
+		if tag.ID == places.ID && tag.FileCount != 2 {
+			t.Errorf("Expected Places to count 2 files, got %d", tag.FileCount)
+		}
+	}
+
+	tags, _ := database.GetTags()
+	if path := TagPaths(tags)[louvre.ID]; path != "Places/Paris/Louvre" {
+		t.Errorf("Expected the path Places/Paris/Louvre, got %q", path)
+	}
+
+	if err := database.SetTagParent(places.ID, &louvre.ID); err == nil {
+		t.Errorf("Expected moving a tag beneath its own descendant to fail")
+	}
+	if err := database.MergeTags([]uint{places.ID}, paris.ID); err == nil {
+		t.Errorf("Expected merging a tag into its descendant to fail")
+	}
+	if err := database.SetTagParent(paris.ID, nil); err != nil {
+		t.Fatalf("SetTagParent failed: %v", err)
+	}
+
+	// Deleting Paris moves Louvre up to the top level
+	if err := database.DeleteTag(paris.ID); err != nil {
+		t.Fatalf("DeleteTag failed: %v", err)
+	}
+	database.GetDB().First(louvre, louvre.ID)
+	if louvre.ParentID != nil {
+		t.Errorf("Expected Louvre to move to the top level, got parent %d", *louvre.ParentID)
+	}
+}
@@ -0,0 +1,358 @@
+// Package exif reads the EXIF tags of JPEG and TIFF photos that the library
+// cares about: camera, lens, exposure, orientation, capture date and GPS
+// position.
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoExif is returned for files that carry no EXIF data.
+var ErrNoExif = errors.New("no EXIF data")
+
+// Data holds the EXIF fields extracted from a photo. Zero values mean the tag
+// was absent.
+type Data struct {
+	Make         string
+	Model        string
+	LensModel    string
+	ExposureTime float64 // seconds
+	FNumber      float64
+	ISO          int
+	FocalLength  float64 // millimetres
+	Orientation  int     // 1-8 as defined by EXIF; 1 is upright
+	CapturedAt   time.Time
+	HasGPS       bool
+	Latitude     float64 // degrees, negative south of the equator
+	Longitude    float64 // degrees, negative west of Greenwich
+}
+
+// Tags read from the IFDs.
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagExposureTime     = 0x829A
+	tagFNumber          = 0x829D
+	tagISO              = 0x8827
+	tagDateTimeOriginal = 0x9003
+	tagFocalLength      = 0x920A
+	tagLensModel        = 0xA434
+	tagGPSLatitudeRef   = 0x0001
+	tagGPSLatitude      = 0x0002
+	tagGPSLongitudeRef  = 0x0003
+	tagGPSLongitude     = 0x0004
+)
+
+// TIFF field types.
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+var typeSizes = map[uint16]uint32{
+	typeByte: 1, typeASCII: 1, typeShort: 2, typeLong: 4, typeRational: 8,
+	typeUndefined: 1, typeSLong: 4, typeSRational: 8,
+}
+
+// maxIFDEntries guards against corrupt files claiming huge directories.
+const maxIFDEntries = 1000
+
+// Read extracts the EXIF data of the JPEG or TIFF file at path. It returns
+// ErrNoExif for other formats and for files without EXIF.
+func Read(path string) (*Data, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var magic [4]byte
+	if _, err := io.ReadFull(file, magic[:]); err != nil {
+		return nil, ErrNoExif
+	}
+	switch {
+	case magic[0] == 0xFF && magic[1] == 0xD8:
+		payload, err := findJPEGExif(file)
+		if err != nil {
+			return nil, err
+		}
+		return decode(bytes.NewReader(payload), int64(len(payload)))
+	case string(magic[:]) == "II*\x00" || string(magic[:]) == "MM\x00*":
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return decode(file, info.Size())
+	default:
+		return nil, ErrNoExif
+	}
+}
+
+// findJPEGExif walks the JPEG marker segments up to the image data and
+// returns the TIFF payload of the APP1 "Exif" segment.
+func findJPEGExif(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(2, io.SeekStart); err != nil {
+		return nil, err
+	}
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, ErrNoExif
+		}
+		if header[0] != 0xFF {
+			return nil, fmt.Errorf("corrupt JPEG marker %#x", header[0])
+		}
+		marker := header[1]
+		// Start of scan or end of image: no more metadata segments
+		if marker == 0xDA || marker == 0xD9 {
+			return nil, ErrNoExif
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:])) - 2
+		if length < 0 {
+			return nil, fmt.Errorf("corrupt JPEG segment length")
+		}
+		if marker != 0xE1 || length < 6 {
+			if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, fmt.Errorf("truncated APP1 segment: %w", err)
+		}
+		// APP1 is also used for XMP; only the one starting with "Exif\0\0" counts
+		if string(segment[:6]) == "Exif\x00\x00" {
+			return segment[6:], nil
+		}
+	}
+}
+
+// reader decodes IFD entries from a TIFF structure.
+type reader struct {
+	r     io.ReaderAt
+	size  int64
+	order binary.ByteOrder
+}
+
+type entry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // raw value bytes, already resolved from the offset if needed
+}
+
+func decode(r io.ReaderAt, size int64) (*Data, error) {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return nil, ErrNoExif
+	}
+	tr := &reader{r: r, size: size}
+	switch string(header[:2]) {
+	case "II":
+		tr.order = binary.LittleEndian
+	case "MM":
+		tr.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid TIFF byte order %q", header[:2])
+	}
+	if tr.order.Uint16(header[2:]) != 42 {
+		return nil, fmt.Errorf("invalid TIFF header")
+	}
+
+	ifd0, err := tr.readIFD(tr.order.Uint32(header[4:]))
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Data{}
+	var dateTime, dateTimeOriginal string
+	for _, e := range ifd0 {
+		switch e.tag {
+		case tagMake:
+			data.Make = tr.ascii(e)
+		case tagModel:
+			data.Model = tr.ascii(e)
+		case tagOrientation:
+			data.Orientation = int(tr.uint(e))
+		case tagDateTime:
+			dateTime = tr.ascii(e)
+		case tagExifIFD:
+			exifIFD, err := tr.readIFD(tr.uint(e))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read EXIF IFD: %w", err)
+			}
+			for _, e := range exifIFD {
+				switch e.tag {
+				case tagExposureTime:
+					data.ExposureTime = tr.rational(e, 0)
+				case tagFNumber:
+					data.FNumber = tr.rational(e, 0)
+				case tagISO:
+					data.ISO = int(tr.uint(e))
+				case tagDateTimeOriginal:
+					dateTimeOriginal = tr.ascii(e)
+				case tagFocalLength:
+					data.FocalLength = tr.rational(e, 0)
+				case tagLensModel:
+					data.LensModel = tr.ascii(e)
+				}
+			}
+		case tagGPSIFD:
+			gpsIFD, err := tr.readIFD(tr.uint(e))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read GPS IFD: %w", err)
+			}
+			tr.readGPS(gpsIFD, data)
+		}
+	}
+
+	// DateTimeOriginal is when the photo was taken; DateTime when it was last
+	// edited. Neither carries a time zone, so it is interpreted as local time.
+	if dateTimeOriginal != "" {
+		dateTime = dateTimeOriginal
+	}
+	if dateTime != "" {
+		if t, err := time.ParseInLocation("2006:01:02 15:04:05", dateTime, time.Local); err == nil {
+			data.CapturedAt = t
+		}
+	}
+	return data, nil
+}
+
+func (tr *reader) readIFD(offset uint32) ([]entry, error) {
+	var countBytes [2]byte
+	if _, err := tr.r.ReadAt(countBytes[:], int64(offset)); err != nil {
+		return nil, fmt.Errorf("IFD offset %d out of range", offset)
+	}
+	count := tr.order.Uint16(countBytes[:])
+	if count > maxIFDEntries {
+		return nil, fmt.Errorf("IFD claims %d entries", count)
+	}
+
+	raw := make([]byte, int(count)*12)
+	if _, err := tr.r.ReadAt(raw, int64(offset)+2); err != nil {
+		return nil, fmt.Errorf("truncated IFD at %d", offset)
+	}
+
+	entries := make([]entry, 0, count)
+	for i := 0; i < int(count); i++ {
+		field := raw[i*12 : (i+1)*12]
+		e := entry{
+			tag:   tr.order.Uint16(field[0:]),
+			typ:   tr.order.Uint16(field[2:]),
+			count: tr.order.Uint32(field[4:]),
+		}
+		unit, ok := typeSizes[e.typ]
+		if !ok {
+			continue
+		}
+		size := int64(unit) * int64(e.count)
+		if size <= 4 {
+			e.value = field[8 : 8+size]
+		} else {
+			valueOffset := int64(tr.order.Uint32(field[8:]))
+			if size > tr.size || valueOffset+size > tr.size {
+				continue
+			}
+			e.value = make([]byte, size)
+			if _, err := tr.r.ReadAt(e.value, valueOffset); err != nil {
+				continue
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (tr *reader) readGPS(entries []entry, data *Data) {
+	var latRef, lonRef string
+	var lat, lon []float64
+	for _, e := range entries {
+		switch e.tag {
+		case tagGPSLatitudeRef:
+			latRef = tr.ascii(e)
+		case tagGPSLongitudeRef:
+			lonRef = tr.ascii(e)
+		case tagGPSLatitude:
+			lat = tr.rationals(e)
+		case tagGPSLongitude:
+			lon = tr.rationals(e)
+		}
+	}
+	if len(lat) != 3 || len(lon) != 3 {
+		return
+	}
+	data.Latitude = lat[0] + lat[1]/60 + lat[2]/3600
+	data.Longitude = lon[0] + lon[1]/60 + lon[2]/3600
+	if latRef == "S" {
+		data.Latitude = -data.Latitude
+	}
+	if lonRef == "W" {
+		data.Longitude = -data.Longitude
+	}
+	data.HasGPS = true
+}
+
+func (tr *reader) ascii(e entry) string {
+	if e.typ != typeASCII && e.typ != typeUndefined {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(e.value), "\x00"))
+}
+
+// uint returns the first value of a BYTE, SHORT or LONG entry.
+func (tr *reader) uint(e entry) uint32 {
+	switch {
+	case e.typ == typeByte && len(e.value) >= 1:
+		return uint32(e.value[0])
+	case e.typ == typeShort && len(e.value) >= 2:
+		return uint32(tr.order.Uint16(e.value))
+	case (e.typ == typeLong || e.typ == typeSLong) && len(e.value) >= 4:
+		return tr.order.Uint32(e.value)
+	}
+	return 0
+}
+
+// rational returns the i-th value of a RATIONAL or SRATIONAL entry.
+func (tr *reader) rational(e entry, i int) float64 {
+	if (e.typ != typeRational && e.typ != typeSRational) || len(e.value) < (i+1)*8 {
+		return 0
+	}
+	numBytes, denBytes := e.value[i*8:], e.value[i*8+4:]
+	var num, den float64
+	if e.typ == typeSRational {
+		num, den = float64(int32(tr.order.Uint32(numBytes))), float64(int32(tr.order.Uint32(denBytes)))
+	} else {
+		num, den = float64(tr.order.Uint32(numBytes)), float64(tr.order.Uint32(denBytes))
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+func (tr *reader) rationals(e entry) []float64 {
+	values := make([]float64, 0, e.count)
+	for i := 0; i < int(e.count) && (i+1)*8 <= len(e.value); i++ {
+		values = append(values, tr.rational(e, i))
+	}
+	return values
+}
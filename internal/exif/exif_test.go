@@ -0,0 +1,210 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tiffField is one IFD entry for buildTIFF. IFD pointers are filled in by
+// buildTIFF; value holds the raw bytes in big-endian order.
+type tiffField struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func ascii(s string) []byte { return append([]byte(s), 0) }
+
+func short(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+
+func rationals(values ...[2]uint32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = binary.BigEndian.AppendUint32(b, v[0])
+		b = binary.BigEndian.AppendUint32(b, v[1])
+	}
+	return b
+}
+
+// buildTIFF lays out a big-endian TIFF with IFD0 followed by the EXIF and GPS
+// sub-IFDs.
+func buildTIFF(ifd0, exifIFD, gpsIFD []tiffField) []byte {
+	ifdSize := func(fields []tiffField) int { return 2 + 12*len(fields) + 4 }
+	// Reserve the sub-IFD pointer entries in IFD0
+	if exifIFD != nil {
+		ifd0 = append(ifd0, tiffField{tag: tagExifIFD, typ: typeLong, count: 1})
+	}
+	if gpsIFD != nil {
+		ifd0 = append(ifd0, tiffField{tag: tagGPSIFD, typ: typeLong, count: 1})
+	}
+
+	offset0 := 8
+	exifOffset := offset0 + ifdSize(ifd0)
+	gpsOffset := exifOffset + ifdSize(exifIFD)
+	dataOffset := gpsOffset + ifdSize(gpsIFD)
+
+	var data []byte
+	writeIFD := func(buf *bytes.Buffer, fields []tiffField) {
+		binary.Write(buf, binary.BigEndian, uint16(len(fields)))
+		for _, f := range fields {
+			switch f.tag {
+			case tagExifIFD:
+				f.value = binary.BigEndian.AppendUint32(nil, uint32(exifOffset))
+			case tagGPSIFD:
+				f.value = binary.BigEndian.AppendUint32(nil, uint32(gpsOffset))
+			}
+			binary.Write(buf, binary.BigEndian, f.tag)
+			binary.Write(buf, binary.BigEndian, f.typ)
+			binary.Write(buf, binary.BigEndian, f.count)
+			if len(f.value) <= 4 {
+				var inline [4]byte
+				copy(inline[:], f.value)
+				buf.Write(inline[:])
+			} else {
+				binary.Write(buf, binary.BigEndian, uint32(dataOffset+len(data)))
+				data = append(data, f.value...)
+			}
+		}
+		binary.Write(buf, binary.BigEndian, uint32(0))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("MM\x00*")
+	binary.Write(&buf, binary.BigEndian, uint32(offset0))
+	writeIFD(&buf, ifd0)
+	if exifIFD != nil {
+		writeIFD(&buf, exifIFD)
+	}
+	if gpsIFD != nil {
+		writeIFD(&buf, gpsIFD)
+	}
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// writeJPEGWithExif writes a small JPEG whose APP1 segment carries tiff.
+func writeJPEGWithExif(t *testing.T, path string, tiff []byte) {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	var out bytes.Buffer
+	out.Write(encoded.Bytes()[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(payload)+2))
+	out.Write(payload)
+	out.Write(encoded.Bytes()[2:])
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write JPEG: %v", err)
+	}
+}
+
+func TestReadJPEG(t *testing.T) {
+	tiff := buildTIFF(
+		[]tiffField{
+			{tag: tagMake, typ: typeASCII, count: 6, value: ascii("Canon")},
+			{tag: tagModel, typ: typeASCII, count: 8, value: ascii("EOS R6 ")},
+			{tag: tagOrientation, typ: typeShort, count: 1, value: short(6)},
+			{tag: tagDateTime, typ: typeASCII, count: 20, value: ascii("2024:01:01 00:00:00")},
+		},
+		[]tiffField{
+			{tag: tagExposureTime, typ: typeRational, count: 1, value: rationals([2]uint32{1, 250})},
+			{tag: tagFNumber, typ: typeRational, count: 1, value: rationals([2]uint32{28, 10})},
+			{tag: tagISO, typ: typeShort, count: 1, value: short(400)},
+			{tag: tagDateTimeOriginal, typ: typeASCII, count: 20, value: ascii("2023:07:14 18:30:05")},
+			{tag: tagFocalLength, typ: typeRational, count: 1, value: rationals([2]uint32{50, 1})},
+			{tag: tagLensModel, typ: typeASCII, count: 16, value: ascii("RF50mm F1.8 STM")},
+		},
+		[]tiffField{
+			{tag: tagGPSLatitudeRef, typ: typeASCII, count: 2, value: ascii("S")},
+			{tag: tagGPSLatitude, typ: typeRational, count: 3, value: rationals([2]uint32{33, 1}, [2]uint32{51, 1}, [2]uint32{36, 1})},
+			{tag: tagGPSLongitudeRef, typ: typeASCII, count: 2, value: ascii("E")},
+			{tag: tagGPSLongitude, typ: typeRational, count: 3, value: rationals([2]uint32{151, 1}, [2]uint32{12, 1}, [2]uint32{0, 1})},
+		},
+	)
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	writeJPEGWithExif(t, path, tiff)
+
+	data, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if data.Make != "Canon" || data.Model != "EOS R6" || data.LensModel != "RF50mm F1.8 STM" {
+		t.Errorf("Unexpected camera: %q %q %q", data.Make, data.Model, data.LensModel)
+	}
+	if data.Orientation != 6 || data.ISO != 400 {
+		t.Errorf("Unexpected orientation %d or ISO %d", data.Orientation, data.ISO)
+	}
+	if data.ExposureTime != 0.004 || data.FNumber != 2.8 || data.FocalLength != 50 {
+		t.Errorf("Unexpected exposure: %v s, f/%v, %v mm", data.ExposureTime, data.FNumber, data.FocalLength)
+	}
+	want := time.Date(2023, 7, 14, 18, 30, 5, 0, time.Local)
+	if !data.CapturedAt.Equal(want) {
+		t.Errorf("Expected DateTimeOriginal %v, got %v", want, data.CapturedAt)
+	}
+	if !data.HasGPS || math.Abs(data.Latitude+33.86) > 0.001 || math.Abs(data.Longitude-151.2) > 0.001 {
+		t.Errorf("Unexpected GPS position: %v %v,%v", data.HasGPS, data.Latitude, data.Longitude)
+	}
+}
+
+func TestReadTIFF(t *testing.T) {
+	tiff := buildTIFF([]tiffField{
+		{tag: tagModel, typ: typeASCII, count: 4, value: ascii("X-T")},
+		{tag: tagOrientation, typ: typeShort, count: 1, value: short(3)},
+	}, nil, nil)
+	path := filepath.Join(t.TempDir(), "scan.tiff")
+	if err := os.WriteFile(path, tiff, 0644); err != nil {
+		t.Fatalf("Failed to write TIFF: %v", err)
+	}
+
+	data, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if data.Model != "X-T" || data.Orientation != 3 || data.HasGPS || !data.CapturedAt.IsZero() {
+		t.Errorf("Unexpected data: %+v", data)
+	}
+}
+
+func TestReadWithoutExif(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.jpg")
+	f, err := os.Create(plain)
+	if err != nil {
+		t.Fatalf("Failed to create JPEG: %v", err)
+	}
+	jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	f.Close()
+
+	text := filepath.Join(dir, "notes.jpg")
+	os.WriteFile(text, []byte("not an image"), 0644)
+
+	for _, path := range []string{plain, text} {
+		if _, err := Read(path); !errors.Is(err, ErrNoExif) {
+			t.Errorf("Expected ErrNoExif for %s, got %v", path, err)
+		}
+	}
+}
+
+func TestReadCorruptIFD(t *testing.T) {
+	// IFD0 offset points past the end of the data
+	tiff := []byte("MM\x00*\x00\x00\x10\x00")
+	path := filepath.Join(t.TempDir(), "corrupt.jpg")
+	writeJPEGWithExif(t, path, tiff)
+
+	if _, err := Read(path); err == nil {
+		t.Errorf("Expected an error for a corrupt IFD offset")
+	}
+}
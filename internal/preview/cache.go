@@ -0,0 +1,135 @@
+package preview
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Cache places previews in the thumbnail directory. A preview is named after
+// a hash of its source's path, size and modification time, so files with the
+// same name in different folders get previews of their own and a modified
+// file gets a new one; a moved file takes its previews along, see
+// MovePreview. The thumbnail size is part of the name as well, so every size
+// has renditions of its own.
+type Cache struct {
+	dir string
+	cfg ConfigProvider
+}
+
+// NewCache returns the cache in cfg's thumbnail directory. Previews are
+// rendered at the size cfg holds at the time they are requested.
+func NewCache(cfg ConfigProvider) *Cache {
+	return &Cache{dir: cfg.GetThumbnailDir(), cfg: cfg}
+}
+
+// Dir returns the directory holding the previews.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// ThumbnailSize returns the configured preview width in pixels, or
+// DefaultThumbnailSize if none is configured.
+func (c *Cache) ThumbnailSize() int {
+	if size := c.cfg.GetThumbnailSize(); size > 0 {
+		return size
+	}
+	return DefaultThumbnailSize
+}
+
+// CacheKey identifies a version of a source file.
+func CacheKey(srcPath string, size int64, modTime time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", filepath.Clean(srcPath), size, modTime.UnixNano())))
+	return hex.EncodeToString(sum[:16])
+}
+
+// PreviewPath returns where the preview of a source file goes at the current
+// thumbnail size, with the extension of the backend for its MIME type: an
+// animated GIF for videos and a JPEG thumbnail for images. detectedType is as
+// for MIMETypeOf.
+func (c *Cache) PreviewPath(srcPath, detectedType string, size int64, modTime time.Time) string {
+	ext := ".jpg"
+	if g, ok := GeneratorFor(MIMETypeOf(srcPath, detectedType)); ok {
+		ext = g.PreviewExt()
+	}
+	name := fmt.Sprintf("%s_%d%s", CacheKey(srcPath, size, modTime), c.ThumbnailSize(), ext)
+	return filepath.Join(c.dir, name)
+}
+
+// MovePreview renames the renditions of a preview after the cache key of
+// their source, which moved to srcPath, so that the moved file keeps its
+// previews. It returns the new path of previewPath, or "" if it is gone.
+// Previews not named by the cache stay where they are.
+func MovePreview(previewPath, srcPath string, size int64, modTime time.Time) (string, error) {
+	dir, name := filepath.Split(previewPath)
+	oldKey, newKey := cacheKeyOf(name), CacheKey(srcPath, size, modTime)
+	if oldKey == "" || oldKey == newKey {
+		return previewPath, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || cacheKeyOf(entry.Name()) != oldKey {
+			continue
+		}
+		renamed := newKey + strings.TrimPrefix(entry.Name(), oldKey)
+		if err := os.Rename(filepath.Join(dir, entry.Name()), filepath.Join(dir, renamed)); err != nil {
+			return "", err
+		}
+	}
+	newPath := filepath.Join(dir, newKey+strings.TrimPrefix(name, oldKey))
+	if _, err := os.Stat(newPath); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return newPath, nil
+}
+
+// GeneratePreview creates the preview of srcPath at previewPath, thumbSize
+// pixels wide, with the backend registered for the source's MIME type, see
+// MIMETypeOf for detectedType. It
+// returns the perceptual hash of the source. The backend writes to a
+// partial file next to previewPath that is renamed once it succeeded, so a
+// failed or cancelled run never leaves a truncated preview behind.
+func GeneratePreview(ctx context.Context, srcPath, detectedType, previewPath string, thumbSize int) (uint64, error) {
+	g, err := generatorForPath(srcPath, detectedType)
+	if err != nil {
+		return 0, err
+	}
+	if !g.Available() {
+		return 0, fmt.Errorf("the %s preview backend is not available", g.Name())
+	}
+	partial := partialPath(previewPath)
+	// A partial file left by a crash would pass for a finished preview
+	os.Remove(partial)
+	hash, err := g.Generate(ctx, srcPath, partial, thumbSize)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(partial, previewPath)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return 0, err
+	}
+	return hash, nil
+}
+
+// partialPath is where the preview at previewPath is written while it is
+// generated. It keeps the extension, which ffmpeg picks the format by, but
+// is not named like a preview, so pruning the cache removes it if left over.
+func partialPath(previewPath string) string {
+	dir, name := filepath.Split(previewPath)
+	ext := filepath.Ext(name)
+	return filepath.Join(dir, "."+strings.TrimSuffix(name, ext)+".partial"+ext)
+}
@@ -0,0 +1,252 @@
+package preview
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// CacheManager keeps the preview cache within a size limit. The modification
+// time of a preview doubles as its last access: Touch updates it whenever a
+// preview is shown, and eviction removes the least recently used previews
+// first.
+type CacheManager struct {
+	dir      string
+	maxBytes int64
+}
+
+// CacheEntry is a preview, or a partial preview, in the preview cache.
+type CacheEntry struct {
+	Path       string
+	Key        string // cache key of the source file, "" for partial previews
+	Size       int64
+	LastAccess time.Time
+}
+
+// CacheStats summarizes the preview cache.
+type CacheStats struct {
+	Files       int
+	Bytes       int64
+	MaxBytes    int64 // 0 means unlimited
+	Orphans     int   // previews of files no longer in the library
+	OrphanBytes int64
+	Oldest      time.Time // least recent access
+}
+
+// PruneResult counts the previews removed from the cache.
+type PruneResult struct {
+	Removed int
+	Freed   int64
+}
+
+// NewCacheManager manages the cache in dir, holding at most maxBytes of
+// previews. A maxBytes of 0 or less disables eviction by size.
+func NewCacheManager(dir string, maxBytes int64) *CacheManager {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	return &CacheManager{dir: dir, maxBytes: maxBytes}
+}
+
+// Touch marks a preview as just used so that eviction keeps it longest.
+func Touch(previewPath string) {
+	now := time.Now()
+	if err := os.Chtimes(previewPath, now, now); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("[DEBUG] Failed to touch preview %s: %v\n", previewPath, err)
+	}
+}
+
+// LiveCacheKeys returns the cache keys of the current versions of files.
+// Previews with any other key are orphans.
+func LiveCacheKeys(files []models.MediaFile) map[string]bool {
+	keys := make(map[string]bool, len(files))
+	for _, file := range files {
+		keys[CacheKey(file.Path, file.Size, file.ModTime)] = true
+	}
+	return keys
+}
+
+// cacheKeyOf returns the cache key a preview file is named after, or "" if
+// the name does not follow the cache's <key>_<size><ext> naming.
+func cacheKeyOf(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	key, size, ok := strings.Cut(name, "_")
+	if !ok || len(key) != 32 || strings.Trim(key, "0123456789abcdef") != "" ||
+		size == "" || strings.Trim(size, "0123456789") != "" {
+		return ""
+	}
+	return key
+}
+
+// isPartialName reports whether name is that of a preview being generated,
+// see partialPath.
+func isPartialName(name string) bool {
+	name, ok := strings.CutPrefix(name, ".")
+	if !ok {
+		return false
+	}
+	ext := filepath.Ext(name)
+	stem, ok := strings.CutSuffix(strings.TrimSuffix(name, ext), ".partial")
+	return ok && cacheKeyOf(stem+ext) != ""
+}
+
+// Entries lists the previews and partial previews in the cache, least
+// recently used first. Other files are left out, so that pruning never
+// touches files of the user's that happen to be in the thumbnail directory.
+func (m *CacheManager) Entries() ([]CacheEntry, error) {
+	dirEntries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preview cache: %w", err)
+	}
+	var entries []CacheEntry
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		key := cacheKeyOf(dirEntry.Name())
+		if key == "" && !isPartialName(dirEntry.Name()) {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Path:       filepath.Join(m.dir, dirEntry.Name()),
+			Key:        key,
+			Size:       info.Size(),
+			LastAccess: info.ModTime(),
+		})
+	}
+	slices.SortFunc(entries, func(a, b CacheEntry) int {
+		return a.LastAccess.Compare(b.LastAccess)
+	})
+	return entries, nil
+}
+
+// Stats summarizes the cache. Previews whose key is not in live count as
+// orphans.
+func (m *CacheManager) Stats(live map[string]bool) (CacheStats, error) {
+	entries, err := m.Entries()
+	if err != nil {
+		return CacheStats{}, err
+	}
+	stats := CacheStats{Files: len(entries), MaxBytes: m.maxBytes}
+	for _, entry := range entries {
+		stats.Bytes += entry.Size
+		if !live[entry.Key] {
+			stats.Orphans++
+			stats.OrphanBytes += entry.Size
+		}
+	}
+	if len(entries) > 0 {
+		stats.Oldest = entries[0].LastAccess
+	}
+	return stats, nil
+}
+
+// Prune removes orphans, then evicts previews until the cache fits its limit.
+func (m *CacheManager) Prune(live map[string]bool) (PruneResult, error) {
+	orphans, err := m.RemoveOrphans(live)
+	if err != nil {
+		return orphans, err
+	}
+	evicted, err := m.Evict()
+	return PruneResult{Removed: orphans.Removed + evicted.Removed, Freed: orphans.Freed + evicted.Freed}, err
+}
+
+// RemoveOrphans removes the previews whose key is not in live and partial
+// previews left over by an interrupted run.
+func (m *CacheManager) RemoveOrphans(live map[string]bool) (PruneResult, error) {
+	entries, err := m.Entries()
+	if err != nil {
+		return PruneResult{}, err
+	}
+	var result PruneResult
+	for _, entry := range entries {
+		if !live[entry.Key] {
+			m.remove(entry, &result)
+		}
+	}
+	return result, nil
+}
+
+// Evict removes the least recently used previews until the cache holds at
+// most its maximum size.
+func (m *CacheManager) Evict() (PruneResult, error) {
+	if m.maxBytes == 0 {
+		return PruneResult{}, nil
+	}
+	entries, err := m.Entries()
+	if err != nil {
+		return PruneResult{}, err
+	}
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	var result PruneResult
+	for _, entry := range entries {
+		if total <= m.maxBytes {
+			break
+		}
+		if m.remove(entry, &result) {
+			total -= entry.Size
+		}
+	}
+	return result, nil
+}
+
+// Verify returns the previews that cannot be decoded.
+func (m *CacheManager) Verify() ([]CacheEntry, error) {
+	entries, err := m.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var broken []CacheEntry
+	for _, entry := range entries {
+		if err := checkPreviewFile(entry.Path); err != nil {
+			fmt.Printf("[DEBUG] Unreadable preview %s: %v\n", entry.Path, err)
+			broken = append(broken, entry)
+		}
+	}
+	return broken, nil
+}
+
+// Remove deletes the given previews.
+func (m *CacheManager) Remove(entries []CacheEntry) PruneResult {
+	var result PruneResult
+	for _, entry := range entries {
+		m.remove(entry, &result)
+	}
+	return result
+}
+
+func (m *CacheManager) remove(entry CacheEntry, result *PruneResult) bool {
+	if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("[ERROR] Failed to remove preview %s: %v\n", entry.Path, err)
+		return false
+	}
+	result.Removed++
+	result.Freed += entry.Size
+	return true
+}
+
+func checkPreviewFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, _, err = image.DecodeConfig(file)
+	return err
+}
@@ -0,0 +1,90 @@
+package preview
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+func TestCacheManagerPrune(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(testConfig{dir: dir})
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	files := []models.MediaFile{
+		{Path: "/photos/a.jpg", Size: 1, ModTime: modTime},
+		{Path: "/photos/b.jpg", Size: 2, ModTime: modTime},
+		{Path: "/photos/c.jpg", Size: 3, ModTime: modTime},
+	}
+
+	// Three previews of 100 bytes each, used a.jpg first and c.jpg last,
+	// plus a preview of a deleted file, a partial preview left over by a
+	// crash and files of the user's that are not previews
+	now := time.Now()
+	writePreview := func(path string, lastAccess time.Time) {
+		if err := os.WriteFile(path, make([]byte, 100), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		os.Chtimes(path, lastAccess, lastAccess)
+	}
+	var previews []string
+	for i, file := range files {
+		previews = append(previews, cache.PreviewPath(file.Path, "", file.Size, file.ModTime))
+		writePreview(previews[i], now.Add(time.Duration(i-10)*time.Minute))
+	}
+	orphan := cache.PreviewPath("/photos/deleted.jpg", "", 4, modTime)
+	writePreview(orphan, now)
+	partial := partialPath(previews[0])
+	writePreview(partial, now)
+	var userFiles []string
+	for _, name := range []string{"IMG_0001.jpg", "0123456789abcdef0123456789abcdef.jpg", "0123456789abcdef0123456789abcdef_notes.txt", ".hidden.partial"} {
+		userFiles = append(userFiles, filepath.Join(dir, name))
+		writePreview(userFiles[len(userFiles)-1], now.Add(-time.Hour))
+	}
+
+	live := LiveCacheKeys(files)
+	manager := NewCacheManager(dir, 250)
+	stats, err := manager.Stats(live)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Files != 5 || stats.Bytes != 500 || stats.Orphans != 2 || stats.OrphanBytes != 200 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Using a.jpg makes b.jpg the least recently used preview
+	Touch(previews[0])
+	result, err := manager.Prune(live)
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if result.Removed != 3 || result.Freed != 300 {
+		t.Errorf("Expected 2 orphans and 1 preview to be removed, got %+v", result)
+	}
+	for _, removed := range []string{orphan, partial, previews[1]} {
+		if _, err := os.Stat(removed); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", removed)
+		}
+	}
+	for _, kept := range append([]string{previews[0], previews[2]}, userFiles...) {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("Expected %s to be kept: %v", kept, err)
+		}
+	}
+}
+
+func TestCacheManagerVerify(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "0123456789abcdef0123456789abcdef_180.jpg")
+	os.WriteFile(broken, []byte("not an image"), 0644)
+
+	entries, err := NewCacheManager(dir, 0).Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != broken {
+		t.Errorf("Expected %s to be reported as unreadable, got %+v", broken, entries)
+	}
+}
@@ -0,0 +1,147 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"mime"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+type testConfig struct {
+	dir  string
+	size int
+}
+
+func (c testConfig) GetThumbnailDir() string { return c.dir }
+func (c testConfig) GetThumbnailSize() int   { return c.size }
+
+func TestCachePreviewPath(t *testing.T) {
+	cache := NewCache(testConfig{dir: "/cache"})
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	a := cache.PreviewPath("/photos/2023/IMG_0001.jpg", "", 1000, modTime)
+	b := cache.PreviewPath("/photos/2024/IMG_0001.jpg", "", 1000, modTime)
+	if a == b {
+		t.Errorf("Expected files with the same name in different folders to get different previews, both got %s", a)
+	}
+	if filepath.Dir(a) != "/cache" || filepath.Ext(a) != ".jpg" {
+		t.Errorf("Expected a JPEG in the cache directory, got %s", a)
+	}
+	if again := cache.PreviewPath("/photos/2023/../2023/IMG_0001.jpg", "", 1000, modTime); again != a {
+		t.Errorf("Expected the same preview for the same file, got %s and %s", a, again)
+	}
+	if modified := cache.PreviewPath("/photos/2023/IMG_0001.jpg", "", 1000, modTime.Add(time.Second)); modified == a {
+		t.Errorf("Expected a modified file to get a new preview")
+	}
+	if video := cache.PreviewPath("/videos/clip.MP4", "", 1000, modTime); filepath.Ext(video) != ".gif" {
+		t.Errorf("Expected an animated GIF preview for a video, got %s", video)
+	}
+	if large := NewCache(testConfig{dir: "/cache", size: ThumbnailLarge}).PreviewPath("/photos/2023/IMG_0001.jpg", "", 1000, modTime); large == a {
+		t.Errorf("Expected every thumbnail size to get a rendition of its own, both got %s", a)
+	}
+}
+
+func TestGeneratePreviewImage(t *testing.T) {
+	tempDir := t.TempDir()
+	imagePath := filepath.Join(tempDir, "photo.png")
+	f, err := os.Create(imagePath)
+	if err != nil {
+		t.Fatalf("Failed to create test image: %v", err)
+	}
+	png.Encode(f, image.NewRGBA(image.Rect(0, 0, 32, 24)))
+	f.Close()
+
+	info, _ := os.Stat(imagePath)
+	cache := NewCache(testConfig{dir: filepath.Join(tempDir, "cache"), size: ThumbnailSmall})
+	previewPath := cache.PreviewPath(imagePath, "", info.Size(), info.ModTime())
+	if _, err := GeneratePreview(context.Background(), imagePath, "", previewPath, cache.ThumbnailSize()); err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	f, err = os.Open(previewPath)
+	if err != nil {
+		t.Fatalf("Expected the preview at %s: %v", previewPath, err)
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("Failed to decode preview: %v", err)
+	}
+	if cfg.Width != ThumbnailSmall || cfg.Height != ThumbnailSmall {
+		t.Errorf("Expected a %dx%d preview, got %dx%d", ThumbnailSmall, ThumbnailSmall, cfg.Width, cfg.Height)
+	}
+}
+
+func TestGeneratePreviewMismatchedFile(t *testing.T) {
+	// A PNG renamed to .mp4 is previewed as the image it is, without ffmpeg
+	tempDir := t.TempDir()
+	srcPath := filepath.Join(tempDir, "renamed.mp4")
+	f, err := os.Create(srcPath)
+	if err != nil {
+		t.Fatalf("Failed to create test image: %v", err)
+	}
+	png.Encode(f, image.NewRGBA(image.Rect(0, 0, 32, 24)))
+	f.Close()
+
+	detection, _ := models.MediaTypes.Detect(srcPath)
+	detectedType := detection.ByContent.MIMEType
+	if detectedType != "image/png" {
+		t.Fatalf("Expected the content to be detected as image/png, got %q", detectedType)
+	}
+	if !CanGenerate(srcPath, detectedType) {
+		t.Errorf("Expected the image backend to take the file")
+	}
+	info, _ := os.Stat(srcPath)
+	cache := NewCache(testConfig{dir: filepath.Join(tempDir, "cache"), size: ThumbnailSmall})
+	previewPath := cache.PreviewPath(srcPath, detectedType, info.Size(), info.ModTime())
+	if filepath.Ext(previewPath) != ".jpg" {
+		t.Errorf("Expected a JPEG thumbnail, got %s", previewPath)
+	}
+	if _, err := GeneratePreview(context.Background(), srcPath, detectedType, previewPath, cache.ThumbnailSize()); err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if err := checkPreviewFile(previewPath); err != nil {
+		t.Errorf("Expected a readable preview at %s: %v", previewPath, err)
+	}
+}
+
+func TestGeneratePreviewLeavesNoPartialFile(t *testing.T) {
+	mime.AddExtensionType(".fakepartial", "image/x-fake-partial")
+	fake := &fakeGenerator{fail: errors.New("backend crashed")}
+	Register("image/x-fake-partial", fake)
+	defer func() {
+		generatorsMu.Lock()
+		delete(generators, "image/x-fake-partial")
+		generatorsMu.Unlock()
+	}()
+
+	cacheDir := t.TempDir()
+	previewPath := NewCache(testConfig{dir: cacheDir}).PreviewPath("/photos/a.fakepartial", "", 1, time.Time{})
+	if _, err := GeneratePreview(context.Background(), "/photos/a.fakepartial", "", previewPath, ThumbnailSmall); err == nil {
+		t.Errorf("Expected the backend's error")
+	}
+
+	// A run cancelled after the backend returned is not kept either
+	fake.fail = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GeneratePreview(ctx, "/photos/a.fakepartial", "", previewPath, ThumbnailSmall); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 0 {
+		t.Errorf("Expected no files in the cache, got %v", entries)
+	}
+
+	if _, err := GeneratePreview(context.Background(), "/photos/a.fakepartial", "", previewPath, ThumbnailSmall); err != nil {
+		t.Fatalf("GeneratePreview failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(cacheDir); len(entries) != 1 || entries[0].Name() != filepath.Base(previewPath) {
+		t.Errorf("Expected only the finished preview in the cache, got %v", entries)
+	}
+}
@@ -0,0 +1,56 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// decodeImage decodes an image in pure Go; JPEG, PNG, GIF, WebP, BMP and TIFF
+// are supported. Formats Go cannot decode are handed to ffmpeg, if it is
+// installed.
+func decodeImage(ctx context.Context, r io.Reader, srcPath string) (image.Image, string, error) {
+	img, format, err := image.Decode(r)
+	if err == nil || !errors.Is(err, image.ErrFormat) {
+		return img, format, err
+	}
+	fmt.Printf("[DEBUG] No Go decoder for %s, trying ffmpeg\n", srcPath)
+	img, ffmpegErr := decodeWithFFmpeg(ctx, srcPath)
+	if ffmpegErr != nil {
+		return nil, "", fmt.Errorf("%w (%v)", err, ffmpegErr)
+	}
+	return img, "ffmpeg", nil
+}
+
+// decodeWithFFmpeg has ffmpeg convert the first frame of srcPath to PNG.
+func decodeWithFFmpeg(ctx context.Context, srcPath string) (image.Image, error) {
+	cmd, err := ffmpegCommand(ctx,
+		"-loglevel", "error",
+		"-i", srcPath,
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-vcodec", "png",
+		"-",
+	)
+	if err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return png.Decode(bytes.NewReader(output))
+}
@@ -0,0 +1,59 @@
+package preview
+
+import (
+	"image"
+
+	"github.com/user/media-manager/internal/exif"
+)
+
+// imageOrientation returns the EXIF orientation of the photo at path, or 1
+// (upright) if it has none.
+func imageOrientation(path string) int {
+	data, err := exif.Read(path)
+	if err != nil || data.Orientation < 1 || data.Orientation > 8 {
+		return 1
+	}
+	return data.Orientation
+}
+
+// applyOrientation returns img transformed so that it displays upright for
+// the given EXIF orientation. Phones store portrait photos in sensor order
+// with orientation 6 or 8, which would otherwise show up sideways.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	// Orientations 5-8 swap width and height
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // mirrored along the top-left diagonal
+				sx, sy = y, x
+			case 6: // needs a 90 degree clockwise rotation
+				sx, sy = y, h-1-x
+			case 7: // mirrored along the top-right diagonal
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs a 90 degree counter-clockwise rotation
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,134 @@
+package preview
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"math/bits"
+	"os"
+)
+
+// Perceptual hashes are 64-bit difference hashes (dHash): the image is
+// reduced to a 9x8 grayscale grid and each bit records whether a cell is
+// brighter than its right neighbour. Resized, recompressed or re-encoded
+// copies of the same picture end up a few bits apart, so similarity is the
+// Hamming distance between two hashes.
+
+const (
+	hashGridWidth  = 9
+	hashGridHeight = 8
+)
+
+// DifferenceHash returns the dHash of img.
+func DifferenceHash(img image.Image) uint64 {
+	return hashFromGrid(grayGrid(img))
+}
+
+// HammingDistance returns the number of differing bits between two hashes.
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ImagePerceptualHash decodes the image at path and returns its dHash.
+func ImagePerceptualHash(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return DifferenceHash(img), nil
+}
+
+// GifPerceptualHash hashes an animated preview GIF. The frames are composited
+// in order and their grayscale grids averaged, so the hash describes the
+// sampled scenes of the whole video rather than a single frame.
+func GifPerceptualHash(gifPath string) (uint64, error) {
+	file, err := os.Open(gifPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open GIF: %w", err)
+	}
+	defer file.Close()
+
+	anim, err := gif.DecodeAll(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+	if len(anim.Image) == 0 {
+		return 0, fmt.Errorf("GIF has no frames: %s", gifPath)
+	}
+
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	if bounds.Empty() {
+		bounds = anim.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+
+	var sum [hashGridHeight][hashGridWidth]float64
+	for _, frame := range anim.Image {
+		// Encoders such as ffmpeg only store the changed region of each frame
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		grid := grayGrid(canvas)
+		for y := range grid {
+			for x := range grid[y] {
+				sum[y][x] += grid[y][x]
+			}
+		}
+	}
+	return hashFromGrid(sum), nil
+}
+
+// grayGrid averages the luminance of img over a 9x8 grid of equal areas.
+func grayGrid(img image.Image) [hashGridHeight][hashGridWidth]float64 {
+	var sum [hashGridHeight][hashGridWidth]float64
+	var count [hashGridHeight][hashGridWidth]int
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return sum
+	}
+
+	// Sample at most ~256 pixels per axis; plenty for an 9x8 grid and keeps
+	// hashing full-size photos cheap
+	stepX := max(1, width/256)
+	stepY := max(1, height/256)
+	for y := 0; y < height; y += stepY {
+		cellY := y * hashGridHeight / height
+		for x := 0; x < width; x += stepX {
+			cellX := x * hashGridWidth / width
+			gray := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray)
+			sum[cellY][cellX] += float64(gray.Y)
+			count[cellY][cellX]++
+		}
+	}
+
+	for y := range sum {
+		for x := range sum[y] {
+			if count[y][x] > 0 {
+				sum[y][x] /= float64(count[y][x])
+			}
+		}
+	}
+	return sum
+}
+
+func hashFromGrid(grid [hashGridHeight][hashGridWidth]float64) uint64 {
+	var hash uint64
+	bit := 0
+	for y := 0; y < hashGridHeight; y++ {
+		for x := 0; x < hashGridWidth-1; x++ {
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1 << bit
+			}
+			bit++
+		}
+	}
+	return hash
+}
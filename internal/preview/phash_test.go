@@ -0,0 +1,174 @@
+package preview
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+// testPattern draws a picture with distinct structure: a diagonal gradient
+// with a bright square in one quadrant.
+func testPattern(width, height int, invert bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8((x*255/width + y*255/height) / 2)
+			if x > width/2 && y < height/3 {
+				v = 250
+			}
+			if invert {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func TestDifferenceHashNearDuplicates(t *testing.T) {
+	original := testPattern(640, 480, false)
+	originalHash := DifferenceHash(original)
+
+	// A downscaled, recompressed copy should stay within a few bits
+	smaller := resize.Resize(200, 150, original, resize.Bilinear)
+	path := filepath.Join(t.TempDir(), "copy.jpg")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create copy: %v", err)
+	}
+	if err := jpeg.Encode(f, smaller, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatalf("Failed to encode copy: %v", err)
+	}
+	f.Close()
+
+	copyHash, err := ImagePerceptualHash(path)
+	if err != nil {
+		t.Fatalf("ImagePerceptualHash failed: %v", err)
+	}
+	if d := HammingDistance(originalHash, copyHash); d > 6 {
+		t.Errorf("Expected resized copy to be near the original, distance %d", d)
+	}
+
+	different := DifferenceHash(testPattern(640, 480, true))
+	if d := HammingDistance(originalHash, different); d < 20 {
+		t.Errorf("Expected a different picture to be far from the original, distance %d", d)
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	if d := HammingDistance(0, 0); d != 0 {
+		t.Errorf("Expected 0, got %d", d)
+	}
+	if d := HammingDistance(0b1011, 0b0001); d != 2 {
+		t.Errorf("Expected 2, got %d", d)
+	}
+	if d := HammingDistance(0, ^uint64(0)); d != 64 {
+		t.Errorf("Expected 64, got %d", d)
+	}
+}
+
+func TestGenerateThumbnailWithHash(t *testing.T) {
+	tempDir := t.TempDir()
+	imagePath := filepath.Join(tempDir, "test.jpg")
+	f, err := os.Create(imagePath)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if err := jpeg.Encode(f, testPattern(400, 300, false), nil); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	f.Close()
+
+	hash, err := GenerateThumbnailWithHash(imagePath, filepath.Join(tempDir, "thumb.jpg"))
+	if err != nil {
+		t.Fatalf("GenerateThumbnailWithHash failed: %v", err)
+	}
+	direct, err := ImagePerceptualHash(imagePath)
+	if err != nil {
+		t.Fatalf("ImagePerceptualHash failed: %v", err)
+	}
+	if d := HammingDistance(hash, direct); d > 4 {
+		t.Errorf("Thumbnail hash too far from the image hash: %d bits", d)
+	}
+}
+
+func TestGifPerceptualHash(t *testing.T) {
+	// Two frames of the same scene, the second only storing a small changed region
+	first := image.NewPaletted(image.Rect(0, 0, 90, 50), palette.Plan9)
+	src := testPattern(90, 50, false)
+	for y := 0; y < 50; y++ {
+		for x := 0; x < 90; x++ {
+			first.Set(x, y, src.At(x, y))
+		}
+	}
+	second := image.NewPaletted(image.Rect(10, 10, 20, 20), palette.Plan9)
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 20; x++ {
+			second.Set(x, y, src.At(x, y))
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "preview.gif")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create GIF: %v", err)
+	}
+	anim := &gif.GIF{
+		Image:  []*image.Paletted{first, second},
+		Delay:  []int{10, 10},
+		Config: image.Config{Width: 90, Height: 50, ColorModel: first.Palette},
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+	f.Close()
+
+	hash, err := GifPerceptualHash(path)
+	if err != nil {
+		t.Fatalf("GifPerceptualHash failed: %v", err)
+	}
+	if d := HammingDistance(hash, DifferenceHash(src)); d > 6 {
+		t.Errorf("Expected GIF hash near the source scene, distance %d", d)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 3x2 image with a marked top-left pixel
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	marker := color.RGBA{255, 0, 0, 255}
+	img.Set(0, 0, marker)
+
+	tests := []struct {
+		orientation   int
+		width, height int
+		markerX       int
+		markerY       int
+	}{
+		{1, 3, 2, 0, 0},
+		{2, 3, 2, 2, 0},
+		{3, 3, 2, 2, 1},
+		{4, 3, 2, 0, 1},
+		{5, 2, 3, 0, 0},
+		{6, 2, 3, 1, 0},
+		{7, 2, 3, 1, 2},
+		{8, 2, 3, 0, 2},
+	}
+	for _, tt := range tests {
+		oriented := applyOrientation(img, tt.orientation)
+		bounds := oriented.Bounds()
+		if bounds.Dx() != tt.width || bounds.Dy() != tt.height {
+			t.Errorf("Orientation %d: expected %dx%d, got %dx%d", tt.orientation, tt.width, tt.height, bounds.Dx(), bounds.Dy())
+			continue
+		}
+		if got := color.RGBAModel.Convert(oriented.At(tt.markerX, tt.markerY)); got != marker {
+			t.Errorf("Orientation %d: expected marker at %d,%d", tt.orientation, tt.markerX, tt.markerY)
+		}
+	}
+}
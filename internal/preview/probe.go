@@ -0,0 +1,148 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaInfo is the technical metadata ffprobe reports for a video.
+type MediaInfo struct {
+	Width      int // display width, i.e. after applying Rotation
+	Height     int // display height, i.e. after applying Rotation
+	Duration   time.Duration
+	VideoCodec string
+	AudioCodec string
+	Bitrate    int64   // overall bits per second
+	FrameRate  float64 // frames per second
+	Rotation   int     // clockwise degrees: 0, 90, 180 or 270
+}
+
+// ffprobeOutput is the subset of `ffprobe -print_format json` we use.
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string            `json:"codec_type"`
+		CodecName    string            `json:"codec_name"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		AvgFrameRate string            `json:"avg_frame_rate"`
+		RFrameRate   string            `json:"r_frame_rate"`
+		Tags         map[string]string `json:"tags"`
+		Disposition  struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+		SideDataList []struct {
+			Rotation *float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// ProbeMedia runs ffprobe on filePath and returns its dimensions, duration,
+// codecs, bitrate, frame rate and rotation.
+func ProbeMedia(filePath string) (*MediaInfo, error) {
+	return probeMedia(context.Background(), filePath)
+}
+
+// probeMedia is ProbeMedia with ffprobe killed when ctx is done.
+func probeMedia(ctx context.Context, filePath string) (*MediaInfo, error) {
+	cmd, err := ffprobeCommand(ctx,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		filePath,
+	)
+	if err != nil {
+		return nil, err
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %s: %w", filePath, err)
+	}
+	info, err := parseProbeOutput(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output for %s: %w", filePath, err)
+	}
+	return info, nil
+}
+
+// parseProbeOutput extracts MediaInfo from ffprobe's JSON output. The first
+// video and audio streams win; cover art and other attachments are ignored.
+func parseProbeOutput(data []byte) (*MediaInfo, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	info := &MediaInfo{}
+	if seconds, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
+		info.Duration = time.Duration(seconds * float64(time.Second))
+	}
+	if bitrate, err := strconv.ParseInt(probe.Format.BitRate, 10, 64); err == nil {
+		info.Bitrate = bitrate
+	}
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Embedded cover art shows up as a single-frame video stream
+			if info.VideoCodec != "" || stream.Disposition.AttachedPic == 1 {
+				continue
+			}
+			info.VideoCodec = stream.CodecName
+			info.Width, info.Height = stream.Width, stream.Height
+			info.FrameRate = parseFrameRate(stream.AvgFrameRate)
+			if info.FrameRate == 0 {
+				info.FrameRate = parseFrameRate(stream.RFrameRate)
+			}
+
+			// Older ffmpeg reports a "rotate" tag (clockwise), newer ones a
+			// display matrix rotation (counter-clockwise)
+			if rotate, err := strconv.Atoi(stream.Tags["rotate"]); err == nil {
+				info.Rotation = normalizeRotation(rotate)
+			}
+			for _, sideData := range stream.SideDataList {
+				if sideData.Rotation != nil {
+					info.Rotation = normalizeRotation(-int(math.Round(*sideData.Rotation)))
+				}
+			}
+			if info.Rotation == 90 || info.Rotation == 270 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+		case "audio":
+			if info.AudioCodec == "" {
+				info.AudioCodec = stream.CodecName
+			}
+		}
+	}
+	return info, nil
+}
+
+// parseFrameRate parses ffprobe rationals such as "30000/1001".
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+func normalizeRotation(degrees int) int {
+	return ((degrees % 360) + 360) % 360
+}
@@ -0,0 +1,71 @@
+package preview
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseProbeOutput(t *testing.T) {
+	// Trimmed ffprobe output of a portrait phone video with cover art
+	output := `{
+		"streams": [
+			{
+				"codec_type": "video",
+				"codec_name": "hevc",
+				"width": 1920,
+				"height": 1080,
+				"avg_frame_rate": "30000/1001",
+				"r_frame_rate": "30/1",
+				"side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]
+			},
+			{"codec_type": "audio", "codec_name": "aac"},
+			{
+				"codec_type": "video",
+				"codec_name": "mjpeg",
+				"width": 300,
+				"height": 300,
+				"disposition": {"attached_pic": 1}
+			}
+		],
+		"format": {"duration": "12.512000", "bit_rate": "8123456"}
+	}`
+
+	info, err := parseProbeOutput([]byte(output))
+	if err != nil {
+		t.Fatalf("parseProbeOutput failed: %v", err)
+	}
+	if info.VideoCodec != "hevc" || info.AudioCodec != "aac" {
+		t.Errorf("Unexpected codecs: %q / %q", info.VideoCodec, info.AudioCodec)
+	}
+	if info.Rotation != 90 || info.Width != 1080 || info.Height != 1920 {
+		t.Errorf("Expected a 1080x1920 video rotated by 90, got %dx%d rotated by %d", info.Width, info.Height, info.Rotation)
+	}
+	if info.Duration != 12512*time.Millisecond {
+		t.Errorf("Unexpected duration: %v", info.Duration)
+	}
+	if info.Bitrate != 8123456 {
+		t.Errorf("Unexpected bitrate: %d", info.Bitrate)
+	}
+	if info.FrameRate < 29.97 || info.FrameRate > 29.98 {
+		t.Errorf("Unexpected frame rate: %f", info.FrameRate)
+	}
+}
+
+func TestParseProbeOutputRotateTag(t *testing.T) {
+	output := `{"streams": [{"codec_type": "video", "codec_name": "h264", "width": 640, "height": 480,
+		"avg_frame_rate": "0/0", "r_frame_rate": "25/1", "tags": {"rotate": "270"}}], "format": {}}`
+
+	info, err := parseProbeOutput([]byte(output))
+	if err != nil {
+		t.Fatalf("parseProbeOutput failed: %v", err)
+	}
+	if info.Rotation != 270 || info.Width != 480 || info.Height != 640 {
+		t.Errorf("Unexpected geometry: %dx%d rotated by %d", info.Width, info.Height, info.Rotation)
+	}
+	if info.FrameRate != 25 {
+		t.Errorf("Expected r_frame_rate fallback of 25, got %f", info.FrameRate)
+	}
+	if info.AudioCodec != "" || info.Duration != 0 {
+		t.Errorf("Expected no audio and no duration, got %+v", info)
+	}
+}
@@ -0,0 +1,314 @@
+package preview
+
+import (
+	"container/heap"
+	"context"
+	"runtime"
+	"sync"
+)
+
+// Priority orders the jobs of a Queue; higher priorities run first.
+type Priority int
+
+const (
+	// PriorityBackground is for previews nobody is looking at yet.
+	PriorityBackground Priority = iota
+	// PriorityVisible is for previews of cards on screen.
+	PriorityVisible
+)
+
+// Progress describes the work of a Queue since it was last idle.
+type Progress struct {
+	Queued  int // jobs waiting for a worker
+	Running int // jobs being worked on
+	Done    int // jobs finished since the queue was last idle
+	Failed  int // of Done, jobs that returned an error
+}
+
+// Idle reports whether the queue has nothing left to do.
+func (p Progress) Idle() bool {
+	return p.Queued == 0 && p.Running == 0
+}
+
+// Queue runs preview jobs on a fixed pool of workers. Requests for a key
+// that is already queued or running share one job, and a job is cancelled
+// once every request for it was cancelled.
+type Queue struct {
+	mu         sync.Mutex
+	wake       *sync.Cond
+	pending    jobHeap
+	jobs       map[string]*queuedJob // queued, waiting and running jobs by key
+	active     map[string]*queuedJob // running jobs by key, cancelled or not
+	seq        uint64
+	running    int
+	done       int
+	failed     int
+	closed     bool
+	onProgress func(Progress)
+	workers    sync.WaitGroup
+}
+
+type queuedJob struct {
+	key      string
+	priority Priority
+	seq      uint64 // submission order among jobs of the same priority
+	index    int    // position in the heap, -1 while waiting or running
+	run      func(ctx context.Context) error
+	next     *queuedJob // waits for this job to stop before it is queued
+	tickets  map[*Ticket]func(error)
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// Ticket is one request for a job. Cancelling it withdraws the request.
+type Ticket struct {
+	queue *Queue
+	job   *queuedJob
+}
+
+// NewQueue starts a queue with the given number of workers, or one per CPU
+// if workers is not positive.
+func NewQueue(workers int) *Queue {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	q := &Queue{jobs: make(map[string]*queuedJob), active: make(map[string]*queuedJob)}
+	q.wake = sync.NewCond(&q.mu)
+	for range workers {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// SetOnProgress sets a callback run after every change to the queue's
+// progress. It is called from worker goroutines with the queue locked, so it
+// must not call back into the queue.
+func (q *Queue) SetOnProgress(callback func(Progress)) {
+	q.mu.Lock()
+	q.onProgress = callback
+	q.mu.Unlock()
+}
+
+// Submit requests the job identified by key. If a job with that key is
+// already queued or running, run is dropped and the request joins that job,
+// raising its priority if needed. done, if not nil, is called with the job's
+// error once it finished, unless the returned ticket was cancelled first.
+// After Close, run is dropped and done is called with context.Canceled right
+// away.
+func (q *Queue) Submit(key string, priority Priority, run func(ctx context.Context) error, done func(error)) *Ticket {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		if done != nil {
+			done(context.Canceled)
+		}
+		return nil
+	}
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		q.seq++
+		job = &queuedJob{
+			key:      key,
+			priority: priority,
+			seq:      q.seq,
+			run:      run,
+			tickets:  make(map[*Ticket]func(error)),
+			ctx:      ctx,
+			cancel:   cancel,
+		}
+		q.jobs[key] = job
+		if previous, running := q.active[key]; running {
+			// A cancelled job still writing the same preview; this one is
+			// queued once it stopped
+			job.index = -1
+			previous.next = job
+		} else {
+			heap.Push(&q.pending, job)
+			q.wake.Signal()
+		}
+	} else if priority > job.priority && job.index >= 0 {
+		job.priority = priority
+		heap.Fix(&q.pending, job.index)
+	}
+
+	ticket := &Ticket{queue: q, job: job}
+	job.tickets[ticket] = done
+	q.notifyLocked()
+	return ticket
+}
+
+// Cancel withdraws the request. A queued job nobody else requested is
+// dropped; a running one sees its context cancelled, and a new request for
+// its key waits for it to stop.
+func (t *Ticket) Cancel() {
+	if t == nil {
+		return
+	}
+	q := t.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := t.job
+	if _, ok := job.tickets[t]; !ok {
+		return
+	}
+	delete(job.tickets, t)
+	if len(job.tickets) > 0 {
+		return
+	}
+	job.cancel()
+	// A new request for the key starts a fresh job instead of joining this
+	// one; if this one is running, the fresh job waits for it to stop
+	if q.jobs[job.key] == job {
+		delete(q.jobs, job.key)
+	}
+	if job.index >= 0 {
+		heap.Remove(&q.pending, job.index)
+		q.notifyLocked()
+	}
+}
+
+// Progress returns the current progress of the queue.
+func (q *Queue) Progress() Progress {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.progressLocked()
+}
+
+// Close cancels every job and waits for the workers to stop. Requests for
+// jobs that never ran get context.Canceled.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	q.closed = true
+	var callbacks []func(error)
+	for key, job := range q.jobs {
+		job.cancel()
+		if q.active[key] == job {
+			continue
+		}
+		// Queued or waiting for a cancelled run of its key
+		job.index = -1
+		for _, done := range job.tickets {
+			if done != nil {
+				callbacks = append(callbacks, done)
+			}
+		}
+		job.tickets = nil
+		delete(q.jobs, key)
+	}
+	q.pending = nil
+	q.wake.Broadcast()
+	q.mu.Unlock()
+
+	for _, done := range callbacks {
+		done(context.Canceled)
+	}
+	q.workers.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	q.mu.Lock()
+	for {
+		for len(q.pending) == 0 && !q.closed {
+			q.wake.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		job := heap.Pop(&q.pending).(*queuedJob)
+		q.active[job.key] = job
+		q.running++
+		q.notifyLocked()
+		q.mu.Unlock()
+
+		err := job.run(job.ctx)
+
+		q.mu.Lock()
+		cancelled := job.ctx.Err() != nil
+		delete(q.active, job.key)
+		if q.jobs[job.key] == job {
+			delete(q.jobs, job.key)
+		}
+		job.cancel()
+		if next := job.next; next != nil && q.jobs[next.key] == next && !q.closed {
+			heap.Push(&q.pending, next)
+			q.wake.Signal()
+		}
+		var callbacks []func(error)
+		for _, done := range job.tickets {
+			if done != nil {
+				callbacks = append(callbacks, done)
+			}
+		}
+		job.tickets = nil
+		q.mu.Unlock()
+
+		// Callbacks may submit new jobs, so they run unlocked
+		for _, done := range callbacks {
+			done(err)
+		}
+
+		q.mu.Lock()
+		q.running--
+		q.done++
+		if err != nil && !cancelled {
+			q.failed++
+		}
+		q.notifyLocked()
+	}
+}
+
+func (q *Queue) progressLocked() Progress {
+	return Progress{Queued: len(q.pending), Running: q.running, Done: q.done, Failed: q.failed}
+}
+
+// notifyLocked reports the progress and starts counting afresh once the
+// queue ran dry.
+func (q *Queue) notifyLocked() {
+	progress := q.progressLocked()
+	if progress.Idle() {
+		q.done, q.failed = 0, 0
+	}
+	if q.onProgress != nil {
+		q.onProgress(progress)
+	}
+}
+
+// jobHeap is a max-heap by priority, oldest first within a priority.
+type jobHeap []*queuedJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x any) {
+	job := x.(*queuedJob)
+	job.index = len(*h)
+	*h = append(*h, job)
+}
+
+func (h *jobHeap) Pop() any {
+	old := *h
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	job.index = -1
+	*h = old[:len(old)-1]
+	return job
+}
@@ -0,0 +1,267 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockQueue occupies the only worker of q until the returned func is called.
+func blockQueue(t *testing.T, q *Queue) func() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q.Submit("block", PriorityVisible, func(context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}, nil)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Blocking job never started")
+	}
+	return func() { close(release) }
+}
+
+func waitIdle(t *testing.T, q *Queue) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !q.Progress().Idle() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Queue did not become idle: %+v", q.Progress())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueuePriorityAndDeduplication(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+	release := blockQueue(t, q)
+
+	var mu sync.Mutex
+	var order []string
+	job := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+	var doneCalls int
+	done := func(error) {
+		mu.Lock()
+		doneCalls++
+		mu.Unlock()
+	}
+	q.Submit("a", PriorityBackground, job("a"), done)
+	q.Submit("b", PriorityBackground, job("b"), done)
+	q.Submit("c", PriorityVisible, job("c"), done)
+	// Requesting b again shares its job and moves it ahead of a
+	q.Submit("b", PriorityVisible, job("b again"), done)
+
+	if progress := q.Progress(); progress.Queued != 3 || progress.Running != 1 {
+		t.Errorf("Expected 3 queued and 1 running job, got %+v", progress)
+	}
+	release()
+	waitIdle(t, q)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 3 || order[0] != "b" || order[1] != "c" || order[2] != "a" {
+		t.Errorf("Expected the order [b c a], got %v", order)
+	}
+	if doneCalls != 4 {
+		t.Errorf("Expected every request to be told about completion, got %d calls", doneCalls)
+	}
+}
+
+func TestQueueCancellation(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+	release := blockQueue(t, q)
+
+	ran := make(chan string, 4)
+	job := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			ran <- name
+			return nil
+		}
+	}
+	dropped := q.Submit("dropped", PriorityVisible, job("dropped"), nil)
+	shared := q.Submit("shared", PriorityVisible, job("shared"), nil)
+	q.Submit("shared", PriorityBackground, nil, nil)
+	dropped.Cancel()
+	shared.Cancel() // the second request keeps the job alive
+	if progress := q.Progress(); progress.Queued != 1 {
+		t.Errorf("Expected only the shared job to stay queued, got %+v", progress)
+	}
+	release()
+	waitIdle(t, q)
+	close(ran)
+	var names []string
+	for name := range ran {
+		names = append(names, name)
+	}
+	if len(names) != 1 || names[0] != "shared" {
+		t.Errorf("Expected only the shared job to run, got %v", names)
+	}
+
+	// A running job sees its context cancelled once nobody wants it
+	started := make(chan struct{})
+	result := make(chan error, 1)
+	ticket := q.Submit("running", PriorityVisible, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, func(err error) { result <- err })
+	<-started
+	ticket.Cancel()
+	waitIdle(t, q)
+	select {
+	case err := <-result:
+		t.Errorf("Expected no completion callback for a cancelled request, got %v", err)
+	default:
+	}
+}
+
+func TestQueueProgress(t *testing.T) {
+	q := NewQueue(2)
+	defer q.Close()
+
+	var mu sync.Mutex
+	var last Progress
+	var sawFailure bool
+	q.SetOnProgress(func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Failed > 0 {
+			sawFailure = true
+		}
+		last = p
+	})
+	q.Submit("ok", PriorityBackground, func(context.Context) error { return nil }, nil)
+	q.Submit("bad", PriorityBackground, func(context.Context) error { return errors.New("broken") }, nil)
+	waitIdle(t, q)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !sawFailure {
+		t.Errorf("Expected the failed job to be reported")
+	}
+	if !last.Idle() || last.Done != 2 {
+		t.Errorf("Expected a final idle report with 2 jobs done, got %+v", last)
+	}
+	if q.Progress().Done != 0 {
+		t.Errorf("Expected the counters to start afresh once idle, got %+v", q.Progress())
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := NewQueue(1)
+	release := blockQueue(t, q)
+
+	var mu sync.Mutex
+	var errs []error
+	done := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+	ran := false
+	queued := q.Submit("queued", PriorityVisible, func(context.Context) error {
+		ran = true
+		return nil
+	}, done)
+
+	closed := make(chan struct{})
+	go func() {
+		q.Close()
+		close(closed)
+	}()
+	// The queued job is withdrawn before the running one ends
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(errs)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Queued request was not told the queue closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	release()
+	<-closed
+
+	// Neither cancelling a withdrawn request nor a late request may panic
+	// or leave its callback hanging
+	queued.Cancel()
+	late := q.Submit("late", PriorityVisible, func(context.Context) error {
+		ran = true
+		return nil
+	}, done)
+	late.Cancel()
+
+	if ran {
+		t.Errorf("Expected no job to run after Close")
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], context.Canceled) || !errors.Is(errs[1], context.Canceled) {
+		t.Errorf("Expected both requests to get context.Canceled, got %v", errs)
+	}
+}
+
+func TestQueueResubmitWhileCancelledJobRuns(t *testing.T) {
+	q := NewQueue(2)
+	defer q.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var running, overlapped bool
+	run := func(ctx context.Context) error {
+		mu.Lock()
+		overlapped = overlapped || running
+		running = true
+		mu.Unlock()
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		// Like ffmpeg writing the preview, the job takes a while to stop
+		<-release
+		mu.Lock()
+		running = false
+		mu.Unlock()
+		return ctx.Err()
+	}
+
+	first := q.Submit("a", PriorityVisible, run, nil)
+	<-started
+	first.Cancel()
+
+	finished := make(chan error, 1)
+	q.Submit("a", PriorityVisible, run, func(err error) { finished <- err })
+	// The second worker is free, but the new job must wait for the first
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Errorf("Expected the resubmitted job to succeed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Resubmitted job never ran")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if overlapped {
+		t.Errorf("Expected the resubmitted job not to run alongside the cancelled one")
+	}
+}
@@ -0,0 +1,130 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// Generator is a preview backend for some kinds of media.
+type Generator interface {
+	// Name identifies the backend in logs.
+	Name() string
+	// PreviewExt is the extension of the previews the backend writes, e.g.
+	// ".jpg" for still thumbnails or ".gif" for animated ones.
+	PreviewExt() string
+	// Available reports whether the backend can run, e.g. whether the tools
+	// it needs are installed.
+	Available() bool
+	// Generate writes a preview of srcPath, size pixels wide, to previewPath
+	// and returns the perceptual hash of the source. It gives up when ctx is
+	// done.
+	Generate(ctx context.Context, srcPath, previewPath string, size int) (uint64, error)
+}
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = make(map[string]Generator) // by MIME type or "type/*"
+)
+
+// Register makes g the backend for mimeType, which is either a full MIME type
+// such as "image/png" or a wildcard such as "video/*" covering every subtype
+// without a backend of its own. A later registration replaces an earlier one.
+func Register(mimeType string, g Generator) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	generators[strings.ToLower(mimeType)] = g
+}
+
+// GeneratorFor returns the backend for mimeType, if there is one.
+func GeneratorFor(mimeType string) (Generator, bool) {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	if g, ok := generators[mimeType]; ok {
+		return g, true
+	}
+	if major, _, ok := strings.Cut(mimeType, "/"); ok {
+		if g, ok := generators[major+"/*"]; ok {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
+// MIMETypeOf returns the MIME type a file is previewed as. detectedType is
+// the type its content was recognised as by a scan, see
+// models.MediaFile.DetectedType; it wins over the extension, so a JPEG named
+// clip.mp4 is previewed as an image. Without it the type follows the media
+// type registry, and files without a known extension are identified by
+// content.
+func MIMETypeOf(path, detectedType string) string {
+	if detectedType != "" {
+		return detectedType
+	}
+	if _, ok := models.MediaTypes.ByExtension(path); !ok {
+		if mediaType, ok := models.MediaTypes.ByFile(path); ok {
+			return mediaType.MIMEType
+		}
+	}
+	return models.MediaTypes.MIMEType(path)
+}
+
+// CanPreview reports whether a backend is registered for the file at path,
+// with detectedType as for MIMETypeOf.
+func CanPreview(path, detectedType string) bool {
+	_, ok := GeneratorFor(MIMETypeOf(path, detectedType))
+	return ok
+}
+
+// CanGenerate reports whether the backend for the file at path, with
+// detectedType as for MIMETypeOf, can run now.
+func CanGenerate(path, detectedType string) bool {
+	g, ok := GeneratorFor(MIMETypeOf(path, detectedType))
+	return ok && g.Available()
+}
+
+// generatorForPath returns the backend for the file at path, or an error
+// naming its MIME type.
+func generatorForPath(path, detectedType string) (Generator, error) {
+	mimeType := MIMETypeOf(path, detectedType)
+	g, ok := GeneratorFor(mimeType)
+	if !ok {
+		return nil, fmt.Errorf("no preview backend for %s (%s)", filepath.Base(path), mimeType)
+	}
+	return g, nil
+}
+
+// imageGenerator makes still thumbnails of images decoded in Go.
+type imageGenerator struct{}
+
+func (imageGenerator) Name() string       { return "image" }
+func (imageGenerator) PreviewExt() string { return ".jpg" }
+func (imageGenerator) Available() bool    { return true }
+
+func (imageGenerator) Generate(ctx context.Context, srcPath, previewPath string, size int) (uint64, error) {
+	return generateThumbnail(ctx, srcPath, previewPath, size)
+}
+
+// ffmpegGenerator makes animated scene-overview GIFs of videos with ffmpeg.
+type ffmpegGenerator struct{}
+
+func (ffmpegGenerator) Name() string       { return "ffmpeg" }
+func (ffmpegGenerator) PreviewExt() string { return ".gif" }
+func (ffmpegGenerator) Available() bool    { return Tools().CanGenerateVideoPreviews() }
+
+func (ffmpegGenerator) Generate(ctx context.Context, srcPath, previewPath string, size int) (uint64, error) {
+	return generateAnimatedPreviewWithHash(ctx, srcPath, previewPath, size)
+}
+
+func init() {
+	for _, mimeType := range []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/bmp", "image/tiff", "image/heic", "image/heif", "image/avif"} {
+		Register(mimeType, imageGenerator{})
+	}
+	Register("video/*", ffmpegGenerator{})
+}
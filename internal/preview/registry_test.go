@@ -0,0 +1,66 @@
+package preview
+
+import (
+	"context"
+	"mime"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeGenerator records the previews it was asked for. With fail set it
+// writes half a preview and fails.
+type fakeGenerator struct {
+	generated []string
+	fail      error
+}
+
+func (g *fakeGenerator) Name() string       { return "fake" }
+func (g *fakeGenerator) PreviewExt() string { return ".png" }
+func (g *fakeGenerator) Available() bool    { return true }
+
+func (g *fakeGenerator) Generate(ctx context.Context, srcPath, previewPath string, size int) (uint64, error) {
+	g.generated = append(g.generated, srcPath)
+	if err := os.WriteFile(previewPath, []byte("preview"), 0644); err != nil {
+		return 0, err
+	}
+	if g.fail != nil {
+		return 0, g.fail
+	}
+	return 42, nil
+}
+
+func TestGeneratorRegistry(t *testing.T) {
+	if g, ok := GeneratorFor("image/png"); !ok || g.Name() != "image" {
+		t.Errorf("Expected the image backend for PNGs, got %v", g)
+	}
+	if g, ok := GeneratorFor("video/x-matroska"); !ok || g.Name() != "ffmpeg" {
+		t.Errorf("Expected the ffmpeg backend to cover every video type, got %v", g)
+	}
+	if CanPreview("notes.txt", "") {
+		t.Errorf("Expected no backend for text files")
+	}
+
+	// A new backend extends what can be previewed without other changes
+	mime.AddExtensionType(".fakeraw", "image/x-fake-raw")
+	fake := &fakeGenerator{}
+	Register("image/x-fake-raw", fake)
+	defer func() {
+		generatorsMu.Lock()
+		delete(generators, "image/x-fake-raw")
+		generatorsMu.Unlock()
+	}()
+
+	if !CanPreview("/photos/IMG_0001.FAKERAW", "") {
+		t.Errorf("Expected the registered backend to be found by extension")
+	}
+	previewPath := NewCache(testConfig{dir: t.TempDir()}).PreviewPath("/photos/IMG_0001.fakeraw", "", 1, time.Time{})
+	if filepath.Ext(previewPath) != ".png" {
+		t.Errorf("Expected the backend's preview extension, got %s", previewPath)
+	}
+	hash, err := GeneratePreview(context.Background(), "/photos/IMG_0001.fakeraw", "", previewPath, ThumbnailMedium)
+	if err != nil || hash != 42 || len(fake.generated) != 1 {
+		t.Errorf("Expected the fake backend to generate the preview, got hash %d, err %v", hash, err)
+	}
+}
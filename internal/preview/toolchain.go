@@ -0,0 +1,224 @@
+package preview
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrFFmpegUnavailable is returned by operations that need ffmpeg when
+	// it is not installed.
+	ErrFFmpegUnavailable = errors.New("ffmpeg is not available")
+	// ErrFFprobeUnavailable is returned by operations that need ffprobe when
+	// it is not installed.
+	ErrFFprobeUnavailable = errors.New("ffprobe is not available")
+)
+
+// videoPreviewFilters are the ffmpeg filters animated previews are built with.
+var videoPreviewFilters = []string{"trim", "setpts", "concat", "scale", "crop", "fps"}
+
+// Toolchain describes the ffmpeg and ffprobe installation the previews are
+// generated with.
+type Toolchain struct {
+	FFmpegPath     string // resolved path, "" if ffmpeg was not found
+	FFprobePath    string // resolved path, "" if ffprobe was not found
+	FFmpegVersion  string
+	FFprobeVersion string
+	Decoders       map[string]bool
+	Encoders       map[string]bool
+	Filters        map[string]bool
+}
+
+// HasFFmpeg reports whether ffmpeg was found.
+func (t *Toolchain) HasFFmpeg() bool {
+	return t.FFmpegPath != ""
+}
+
+// HasFFprobe reports whether ffprobe was found.
+func (t *Toolchain) HasFFprobe() bool {
+	return t.FFprobePath != ""
+}
+
+// CanGenerateVideoPreviews reports whether animated video previews can be
+// made: they need ffprobe for the duration and ffmpeg with the GIF encoder
+// and the filters the scenes are cut with.
+func (t *Toolchain) CanGenerateVideoPreviews() bool {
+	return t.missingForVideoPreviews() == ""
+}
+
+func (t *Toolchain) missingForVideoPreviews() string {
+	switch {
+	case !t.HasFFmpeg():
+		return "ffmpeg was not found"
+	case !t.HasFFprobe():
+		return "ffprobe was not found"
+	case !t.Encoders["gif"]:
+		return "ffmpeg lacks the gif encoder"
+	}
+	var missing []string
+	for _, filter := range videoPreviewFilters {
+		if !t.Filters[filter] {
+			missing = append(missing, filter)
+		}
+	}
+	if len(missing) > 0 {
+		return "ffmpeg lacks the filters " + strings.Join(missing, ", ")
+	}
+	return ""
+}
+
+// Warning describes what the toolchain cannot do, or is "" if nothing is
+// missing.
+func (t *Toolchain) Warning() string {
+	if missing := t.missingForVideoPreviews(); missing != "" {
+		return missing + ", so videos are shown without previews. Install ffmpeg or set FFmpegPath and FFprobePath in config.json."
+	}
+	return ""
+}
+
+var (
+	toolchainMu sync.Mutex
+	toolchain   *Toolchain
+	ffmpegName  = "ffmpeg"
+	ffprobeName = "ffprobe"
+)
+
+// SetToolPaths sets the ffmpeg and ffprobe executables to use, as paths or
+// names looked up on the PATH. Empty strings select the default names. The
+// toolchain is detected again on next use.
+func SetToolPaths(ffmpegPath, ffprobePath string) {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	if ffprobePath == "" {
+		ffprobePath = "ffprobe"
+	}
+	toolchainMu.Lock()
+	defer toolchainMu.Unlock()
+	ffmpegName, ffprobeName = ffmpegPath, ffprobePath
+	toolchain = nil
+}
+
+// Tools returns the toolchain, detecting it on first use.
+func Tools() *Toolchain {
+	toolchainMu.Lock()
+	defer toolchainMu.Unlock()
+	if toolchain == nil {
+		toolchain = DetectToolchain(ffmpegName, ffprobeName)
+	}
+	return toolchain
+}
+
+// DetectToolchain locates ffmpeg and ffprobe and asks ffmpeg for its
+// decoders, encoders and filters.
+func DetectToolchain(ffmpegPath, ffprobePath string) *Toolchain {
+	t := &Toolchain{
+		Decoders: make(map[string]bool),
+		Encoders: make(map[string]bool),
+		Filters:  make(map[string]bool),
+	}
+	if path, err := exec.LookPath(ffmpegPath); err == nil {
+		t.FFmpegPath = path
+		t.FFmpegVersion = toolVersion(path)
+		if output, err := exec.Command(path, "-hide_banner", "-decoders").Output(); err == nil {
+			t.Decoders = parseCodecList(string(output))
+		}
+		if output, err := exec.Command(path, "-hide_banner", "-encoders").Output(); err == nil {
+			t.Encoders = parseCodecList(string(output))
+		}
+		if output, err := exec.Command(path, "-hide_banner", "-filters").Output(); err == nil {
+			t.Filters = parseFilterList(string(output))
+		}
+	} else {
+		fmt.Printf("[WARN] ffmpeg not found (%s): %v\n", ffmpegPath, err)
+	}
+	if path, err := exec.LookPath(ffprobePath); err == nil {
+		t.FFprobePath = path
+		t.FFprobeVersion = toolVersion(path)
+	} else {
+		fmt.Printf("[WARN] ffprobe not found (%s): %v\n", ffprobePath, err)
+	}
+	fmt.Printf("[DEBUG] Toolchain: ffmpeg %q %s, ffprobe %q %s, %d decoders, %d encoders, %d filters\n",
+		t.FFmpegPath, t.FFmpegVersion, t.FFprobePath, t.FFprobeVersion, len(t.Decoders), len(t.Encoders), len(t.Filters))
+	return t
+}
+
+// toolVersion runs "<tool> -version" and returns the version it reports.
+func toolVersion(path string) string {
+	output, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		return ""
+	}
+	return parseVersion(string(output))
+}
+
+// parseVersion extracts the version from the first line of "-version"
+// output, e.g. "6.1.1" from "ffmpeg version 6.1.1 Copyright ...".
+func parseVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "version" {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// parseCodecList parses "ffmpeg -decoders" or "-encoders": a legend, a
+// line of dashes, then one codec per line as flags, name and description.
+func parseCodecList(output string) map[string]bool {
+	codecs := make(map[string]bool)
+	listing := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if !listing {
+			listing = len(fields) == 1 && strings.HasPrefix(fields[0], "---")
+			continue
+		}
+		if len(fields) >= 2 {
+			codecs[fields[1]] = true
+		}
+	}
+	return codecs
+}
+
+// parseFilterList parses "ffmpeg -filters": after a legend, one filter per
+// line as flags, name, "input->output" and description.
+func parseFilterList(output string) map[string]bool {
+	filters := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			filters[fields[1]] = true
+		}
+	}
+	return filters
+}
+
+// ffmpegCommand prepares an ffmpeg run that is killed when ctx is done, or
+// returns ErrFFmpegUnavailable.
+func ffmpegCommand(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	tools := Tools()
+	if !tools.HasFFmpeg() {
+		return nil, ErrFFmpegUnavailable
+	}
+	return exec.CommandContext(ctx, tools.FFmpegPath, args...), nil
+}
+
+// ffprobeCommand prepares an ffprobe run that is killed when ctx is done, or
+// returns ErrFFprobeUnavailable.
+func ffprobeCommand(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	tools := Tools()
+	if !tools.HasFFprobe() {
+		return nil, ErrFFprobeUnavailable
+	}
+	return exec.CommandContext(ctx, tools.FFprobePath, args...), nil
+}
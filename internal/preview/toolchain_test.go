@@ -0,0 +1,73 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseToolchainOutput(t *testing.T) {
+	version := "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers\nbuilt with gcc 13\n"
+	if got := parseVersion(version); got != "6.1.1-3ubuntu5" {
+		t.Errorf("Expected version 6.1.1-3ubuntu5, got %q", got)
+	}
+
+	encoders := `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D gif                  GIF (Graphics Interchange Format)
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC / MPEG-4 part 10 (codec h264)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+	codecs := parseCodecList(encoders)
+	if len(codecs) != 3 || !codecs["gif"] || !codecs["libx264"] || !codecs["aac"] {
+		t.Errorf("Expected gif, libx264 and aac, got %v", codecs)
+	}
+
+	filterList := `Filters:
+  T.. = Timeline support
+  .S. = Slice threading
+  A = Audio input/output
+  | = Source or sink filter
+ ... concat            N->N       Concatenate audio and video streams.
+ ..C crop              V->V       Crop the input video.
+ TSC scale             V->V       Scale the input video size and/or convert the image format.
+`
+	filters := parseFilterList(filterList)
+	if len(filters) != 3 || !filters["concat"] || !filters["crop"] || !filters["scale"] {
+		t.Errorf("Expected concat, crop and scale, got %v", filters)
+	}
+}
+
+func TestToolchainDegrades(t *testing.T) {
+	tools := DetectToolchain("/nonexistent/ffmpeg", "/nonexistent/ffprobe")
+	if tools.HasFFmpeg() || tools.HasFFprobe() || tools.CanGenerateVideoPreviews() {
+		t.Errorf("Expected no usable toolchain, got %+v", tools)
+	}
+	if tools.Warning() == "" {
+		t.Errorf("Expected a warning without ffmpeg")
+	}
+
+	complete := &Toolchain{
+		FFmpegPath:  "/usr/bin/ffmpeg",
+		FFprobePath: "/usr/bin/ffprobe",
+		Encoders:    map[string]bool{"gif": true},
+		Filters:     map[string]bool{},
+	}
+	for _, filter := range videoPreviewFilters {
+		complete.Filters[filter] = true
+	}
+	if !complete.CanGenerateVideoPreviews() || complete.Warning() != "" {
+		t.Errorf("Expected a complete toolchain to make video previews, got warning %q", complete.Warning())
+	}
+
+	SetToolPaths("/nonexistent/ffmpeg", "/nonexistent/ffprobe")
+	defer SetToolPaths("", "")
+	if _, err := ProbeMedia("video.mp4"); !errors.Is(err, ErrFFprobeUnavailable) {
+		t.Errorf("Expected ErrFFprobeUnavailable, got %v", err)
+	}
+	if _, err := (ffmpegGenerator{}).Generate(context.Background(), "video.mp4", t.TempDir()+"/preview.gif", ThumbnailMedium); !errors.Is(err, ErrFFprobeUnavailable) {
+		t.Errorf("Expected the missing toolchain to be reported, got %v", err)
+	}
+}
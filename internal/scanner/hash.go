@@ -0,0 +1,74 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Files get two content hashes. The fingerprint is cheap and computed for
+// every file; it identifies moved files and narrows down duplicate
+// candidates. The full SHA-256 (ContentHash) only has to be computed for
+// files sharing size and fingerprint with another file, unless full hashing
+// is enabled for every scan.
+
+// fingerprintChunk is how much of the start and end of a file goes into its fingerprint.
+const fingerprintChunk = 64 * 1024
+
+// fingerprintFile returns a fast content fingerprint: a SHA-256 over the file
+// size and its first and last 64 KiB. It is cheap enough to compute for every
+// scanned file and good enough to recognise a file that was moved or renamed.
+func fingerprintFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	size := info.Size()
+
+	hasher := sha256.New()
+	var sizeBytes [8]byte
+	binary.LittleEndian.PutUint64(sizeBytes[:], uint64(size))
+	hasher.Write(sizeBytes[:])
+
+	if size <= 2*fingerprintChunk {
+		if _, err := io.Copy(hasher, file); err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return hex.EncodeToString(hasher.Sum(nil)), nil
+	}
+
+	if _, err := io.CopyN(hasher, file, fingerprintChunk); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if _, err := file.Seek(-fingerprintChunk, io.SeekEnd); err != nil {
+		return "", fmt.Errorf("failed to seek %s: %w", path, err)
+	}
+	if _, err := io.CopyN(hasher, file, fingerprintChunk); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// hashFile returns the SHA-256 of the whole file.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
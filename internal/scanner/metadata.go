@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/user/media-manager/internal/exif"
+	"github.com/user/media-manager/internal/preview"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// ffprobeAvailable reports whether videos can be probed for metadata.
+// Without ffprobe, videos are still scanned but their metadata stays empty.
+func ffprobeAvailable() bool {
+	return preview.Tools().HasFFprobe()
+}
+
+// hasMetadata reports whether metadata was already extracted for file.
+func hasMetadata(file *models.MediaFile) bool {
+	return file.Width > 0 || file.Height > 0 || file.Duration > 0
+}
+
+// needsProbe reports whether the metadata of an unchanged file still has to
+// be read. Files that were probed are skipped until they change, even if
+// nothing was found, such as corrupt files or audio-only videos. Videos wait
+// for ffprobe to be installed.
+func (s *MediaScanner) needsProbe(file *models.MediaFile) bool {
+	if file.ProbedAt != nil || hasMetadata(file) {
+		return false
+	}
+	return file.FileType != "video" || s.probeVideos
+}
+
+// extractMetadata fills in the metadata of files, probing several at a time
+// since ffprobe spends most of its time waiting on disk.
+func (s *MediaScanner) extractMetadata(files []*models.MediaFile) {
+	if len(files) == 0 {
+		return
+	}
+	jobs := make(chan *models.MediaFile)
+	var wg sync.WaitGroup
+	for range min(runtime.NumCPU(), len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				s.readMetadata(file)
+			}
+		}()
+	}
+	for _, file := range files {
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+	fmt.Printf("[DEBUG] Extracted metadata for %d files\n", len(files))
+}
+
+// readMetadata replaces the metadata of file with what is read from disk:
+// dimensions and EXIF for images, and dimensions, duration, codecs, bitrate,
+// frame rate and rotation for videos. EXIF ends up in file.Metadata, which is
+// stored separately with saveExifMetadata.
+func (s *MediaScanner) readMetadata(file *models.MediaFile) {
+	file.Width, file.Height, file.Duration = 0, 0, 0
+	file.VideoCodec, file.AudioCodec = "", ""
+	file.Bitrate, file.FrameRate, file.Rotation = 0, 0, 0
+	file.Metadata = nil
+	probedAt := time.Now()
+	file.ProbedAt = &probedAt
+
+	switch file.FileType {
+	case "image":
+		data, err := exif.Read(file.Path)
+		if err == nil {
+			file.Metadata = metadataFromExif(data)
+			file.Rotation = orientationRotation(data.Orientation)
+		} else if !errors.Is(err, exif.ErrNoExif) {
+			fmt.Printf("[DEBUG] Unreadable EXIF in %s: %v\n", file.Path, err)
+		}
+
+		f, err := os.Open(file.Path)
+		if err != nil {
+			fmt.Printf("[WARN] Failed to open %s for metadata: %v\n", file.Path, err)
+			return
+		}
+		defer f.Close()
+		config, _, err := image.DecodeConfig(f)
+		if err != nil {
+			fmt.Printf("[DEBUG] No image dimensions for %s: %v\n", file.Path, err)
+			return
+		}
+		file.Width, file.Height = config.Width, config.Height
+		// Report the dimensions the photo is displayed with
+		if file.Metadata != nil && file.Metadata.Orientation >= 5 {
+			file.Width, file.Height = file.Height, file.Width
+		}
+	case "video":
+		if !s.probeVideos {
+			// Probe it once ffprobe is installed
+			file.ProbedAt = nil
+			return
+		}
+		info, err := preview.ProbeMedia(file.Path)
+		if err != nil {
+			fmt.Printf("[WARN] %v\n", err)
+			return
+		}
+		file.Width, file.Height = info.Width, info.Height
+		file.Duration = int(math.Round(info.Duration.Seconds()))
+		file.VideoCodec, file.AudioCodec = info.VideoCodec, info.AudioCodec
+		file.Bitrate, file.FrameRate, file.Rotation = info.Bitrate, info.FrameRate, info.Rotation
+	}
+}
+
+// saveExifMetadata stores the EXIF metadata read for images. Images without
+// EXIF get any stale metadata row removed.
+func (s *MediaScanner) saveExifMetadata(files []*models.MediaFile) {
+	metadata := make(map[uint]*models.MediaMetadata)
+	for _, file := range files {
+		if file.FileType == "image" && file.ID != 0 {
+			metadata[file.ID] = file.Metadata
+		}
+	}
+	if err := s.database.ReplaceMediaMetadata(metadata); err != nil {
+		fmt.Printf("Error saving photo metadata: %v\n", err)
+	}
+}
+
+func metadataFromExif(data *exif.Data) *models.MediaMetadata {
+	meta := &models.MediaMetadata{
+		CameraMake:   data.Make,
+		CameraModel:  data.Model,
+		LensModel:    data.LensModel,
+		ExposureTime: data.ExposureTime,
+		FNumber:      data.FNumber,
+		ISO:          data.ISO,
+		FocalLength:  data.FocalLength,
+		Orientation:  data.Orientation,
+	}
+	if !data.CapturedAt.IsZero() {
+		capturedAt := data.CapturedAt
+		meta.CapturedAt = &capturedAt
+	}
+	if data.HasGPS {
+		latitude, longitude := data.Latitude, data.Longitude
+		meta.Latitude, meta.Longitude = &latitude, &longitude
+	}
+	return meta
+}
+
+// orientationRotation converts an EXIF orientation to clockwise degrees,
+// ignoring mirroring.
+func orientationRotation(orientation int) int {
+	switch orientation {
+	case 3, 4:
+		return 180
+	case 6, 7:
+		return 90
+	case 5, 8:
+		return 270
+	}
+	return 0
+}
@@ -2,21 +2,27 @@ package scanner
 
 import (
 	"fmt"
-	"mime"
 	"os"
 	"path/filepath"
-	"slices"
 	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 
 	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/internal/preview"
 	"github.com/user/media-manager/pkg/models"
 )
 
 type MediaScanner struct {
-	database *db.Database
-	watcher  *fsnotify.Watcher
+	database    *db.Database
+	watcher     *fsnotify.Watcher
+	debounce    time.Duration
+	fullHash    bool
+	probeVideos bool // ffprobe is installed
+	onChange    func()
+	pending     map[string]*pendingEvent // only touched by watchLoop
+	removed     []removedFile            // only touched by watchLoop
 }
 
 func NewMediaScanner(database *db.Database) (*MediaScanner, error) {
@@ -26,21 +32,72 @@ func NewMediaScanner(database *db.Database) (*MediaScanner, error) {
 	}
 
 	return &MediaScanner{
-		database: database,
-		watcher:  watcher,
+		database:    database,
+		watcher:     watcher,
+		debounce:    defaultDebounce,
+		probeVideos: ffprobeAvailable(),
+		pending:     make(map[string]*pendingEvent),
 	}, nil
 }
 
-func (s *MediaScanner) ScanDirectory(dirPath string) error {
+// SetFullHashing makes scans compute the full SHA-256 of every new or changed
+// file instead of only for duplicate candidates.
+func (s *MediaScanner) SetFullHashing(enabled bool) {
+	s.fullHash = enabled
+}
+
+// ScanSummary reports what a ScanDirectory pass changed in the database.
+type ScanSummary struct {
+	Added     int
+	Changed   int
+	Removed   int
+	Unchanged int
+}
+
+func (s ScanSummary) String() string {
+	return fmt.Sprintf("added=%d changed=%d removed=%d unchanged=%d", s.Added, s.Changed, s.Removed, s.Unchanged)
+}
+
+// ScanDirectory walks dirPath and reconciles it with the media files already
+// stored for that directory. Files are matched by path; a file whose size or
+// modification time differs is updated in place so its tags survive, new
+// files are inserted and files no longer on disk are marked as missing.
+// Metadata (dimensions, duration, codecs) is extracted for new and changed
+// files, and for unchanged files that have none yet.
+func (s *MediaScanner) ScanDirectory(dirPath string) (ScanSummary, error) {
+	var summary ScanSummary
 	fmt.Printf("[DEBUG] Scanning directory: %s\n", dirPath)
-	return filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+
+	existingFiles, err := s.database.GetMediaFilesByDirectory(dirPath)
+	if err != nil {
+		return summary, fmt.Errorf("failed to load existing media files: %w", err)
+	}
+	existing := make(map[string]*models.MediaFile, len(existingFiles))
+	for i := range existingFiles {
+		existing[existingFiles[i].Path] = &existingFiles[i]
+	}
+
+	var added, changed, backfill []models.MediaFile
+	reprobe := make(map[string]bool)
+	seen := make(map[string]bool, len(existingFiles))
+	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
+			if path == dirPath {
+				return err
+			}
+			// Keep walking so one unreadable entry doesn't abort the whole scan
 			fmt.Printf("[DEBUG] Error walking path %s: %v\n", path, err)
-			return err
+			return nil
 		}
 
-		// Skip directories and hidden files
-		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+		// Skip hidden directories (they are not watched either), other directories and hidden files
+		if info.IsDir() {
+			if path != dirPath && isHidden(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isHidden(path) {
 			return nil
 		}
 
@@ -49,126 +106,240 @@ func (s *MediaScanner) ScanDirectory(dirPath string) error {
 			fmt.Printf("[DEBUG] Skipping non-media file: %s\n", path)
 			return nil
 		}
+		seen[path] = true
 
-		mediaFile := &models.MediaFile{
-			Path:     path,
-			Filename: info.Name(),
-			Size:     info.Size(),
-			ModTime:  info.ModTime(),
-			FileType: s.getFileType(path),
-			MimeType: s.getMimeType(path),
-		}
-		fmt.Printf("[DEBUG] Saving media file to DB: %s\n", path)
-		err = s.database.CreateMediaFile(mediaFile)
-		if err != nil {
-			fmt.Printf("Error saving file %s: %v\n", path, err)
+		record, ok := existing[path]
+		if !ok {
+			added = append(added, models.MediaFile{
+				Path:        path,
+				Filename:    info.Name(),
+				Size:        info.Size(),
+				ModTime:     info.ModTime(),
+				Fingerprint: s.fingerprint(path),
+				ContentHash: s.contentHash(path),
+			})
+			s.detectType(&added[len(added)-1])
+			return nil
 		}
 
-		// (Removed) Save to database
-		// fmt.Printf("[DEBUG] Saving media file to DB: %s\n", path)
-		// err = s.database.CreateMediaFile(mediaFile)
-		// if err != nil {
-		// 	fmt.Printf("Error saving file %s: %v\n", path, err)
-		// }
+		contentChanged := record.Size != info.Size() || !record.ModTime.Equal(info.ModTime())
+		needsHashes := record.Fingerprint == "" || (s.fullHash && record.ContentHash == "")
+		if !record.Missing && !contentChanged {
+			summary.Unchanged++
+			// Backfill records stored before hashes and metadata were extracted
+			if needsHashes {
+				record.Fingerprint = s.fingerprint(path)
+				record.ContentHash = s.contentHash(path)
+				reprobe[path] = s.needsProbe(record)
+				changed = append(changed, *record)
+			} else if record.DetectedType == "" && s.detectType(record) {
+				// Records stored before content detection get their type
+				changed = append(changed, *record)
+			} else if s.needsProbe(record) {
+				backfill = append(backfill, *record)
+			}
+			return nil
+		}
 
+		if contentChanged || needsHashes {
+			if contentChanged {
+				// Content changed, so any generated preview is stale
+				s.removePreview(record)
+			}
+			record.Fingerprint = s.fingerprint(path)
+			record.ContentHash = s.contentHash(path)
+		}
+		record.Filename = info.Name()
+		record.Size = info.Size()
+		record.ModTime = info.ModTime()
+		s.detectType(record)
+		record.Missing = false
+		reprobe[path] = contentChanged || s.needsProbe(record)
+		changed = append(changed, *record)
+		summary.Changed++
 		return nil
 	})
-}
+	if err != nil {
+		return summary, fmt.Errorf("failed to scan %s: %w", dirPath, err)
+	}
 
-func (s *MediaScanner) isMediaFile(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
+	var probe []*models.MediaFile
+	for i := range added {
+		probe = append(probe, &added[i])
+	}
+	for i := range changed {
+		if reprobe[changed[i].Path] {
+			probe = append(probe, &changed[i])
+		}
+	}
+	for i := range backfill {
+		probe = append(probe, &backfill[i])
+	}
+	s.extractMetadata(probe)
+	// Save backfilled records that were probed, even without a result, so
+	// they are skipped from now on
+	for _, record := range backfill {
+		if record.ProbedAt != nil {
+			changed = append(changed, record)
+		}
+	}
+
+	var missingIDs []uint
+	for _, record := range existingFiles {
+		if !seen[record.Path] && !record.Missing {
+			missingIDs = append(missingIDs, record.ID)
+		}
+	}
 
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".tiff", ".bmp"}
-	videoExts := []string{".mp4", ".avi", ".mov", ".mkv", ".webm", ".m4v", ".3gp"}
+	if err := s.database.SaveScanResults(added, changed, missingIDs); err != nil {
+		return summary, err
+	}
+	// New records only have an ID once saved
+	s.saveExifMetadata(probe)
 
-	return slices.Contains(imageExts, ext) || slices.Contains(videoExts, ext)
+	summary.Added = len(added)
+	summary.Removed = len(missingIDs)
+	s.hashDuplicateCandidates()
+	fmt.Printf("[DEBUG] Scan of %s finished: %s\n", dirPath, summary)
+	return summary, nil
 }
 
-func (s *MediaScanner) getFileType(filePath string) string {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	imageExts := []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".tiff", ".bmp"}
-	videoExts := []string{".mp4", ".avi", ".mov", ".mkv", ".webm", ".m4v", ".3gp"}
-	if slices.Contains(imageExts, ext) {
-		return "image"
-	}
-	if slices.Contains(videoExts, ext) {
-		return "video"
+// fingerprint returns the content fingerprint of path, or "" if the file can't be read.
+func (s *MediaScanner) fingerprint(path string) string {
+	fingerprint, err := fingerprintFile(path)
+	if err != nil {
+		fmt.Printf("[WARN] Failed to fingerprint %s: %v\n", path, err)
+		return ""
 	}
-	return "unknown"
+	return fingerprint
 }
 
-func (s *MediaScanner) getMimeType(filePath string) string {
-	ext := filepath.Ext(filePath)
-	mimeType := mime.TypeByExtension(ext)
-	if mimeType == "" {
-		return "application/octet-stream"
+// contentHash returns the full SHA-256 of path if full hashing is enabled.
+// Otherwise it returns "" and the hash is computed later, only if the file
+// turns out to be a duplicate candidate.
+func (s *MediaScanner) contentHash(path string) string {
+	if !s.fullHash {
+		return ""
 	}
-	return mimeType
+	hash, err := hashFile(path)
+	if err != nil {
+		fmt.Printf("[WARN] Failed to hash %s: %v\n", path, err)
+		return ""
+	}
+	return hash
 }
 
-func (s *MediaScanner) StartWatching(directories []string) error {
-	for _, dir := range directories {
-		err := s.watcher.Add(dir)
+// hashDuplicateCandidates computes the full content hash of every file that
+// shares size and fingerprint with another file, so duplicate groups only
+// ever contain byte-identical files.
+func (s *MediaScanner) hashDuplicateCandidates() {
+	candidates, err := s.database.GetDuplicateCandidates()
+	if err != nil {
+		fmt.Printf("Error loading duplicate candidates: %v\n", err)
+		return
+	}
+	for _, candidate := range candidates {
+		hash, err := hashFile(candidate.Path)
 		if err != nil {
-			return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+			fmt.Printf("[WARN] Failed to hash duplicate candidate %s: %v\n", candidate.Path, err)
+			continue
+		}
+		if err := s.database.SetContentHash(candidate.ID, hash); err != nil {
+			fmt.Printf("Error saving content hash for %s: %v\n", candidate.Path, err)
 		}
 	}
-
-	go s.watchLoop()
-	return nil
+	if len(candidates) > 0 {
+		fmt.Printf("[DEBUG] Hashed %d duplicate candidates\n", len(candidates))
+	}
 }
 
-func (s *MediaScanner) watchLoop() {
-	for {
-		select {
-		case event, ok := <-s.watcher.Events:
-			if !ok {
-				return
-			}
-
-			if event.Op&fsnotify.Create == fsnotify.Create {
-				// Handle new file creation
-				if s.isMediaFile(event.Name) {
-					s.handleNewFile(event.Name)
-				}
-			} else if event.Op&fsnotify.Remove == fsnotify.Remove {
-				// Handle file deletion
-				s.handleFileRemoval(event.Name)
-			}
-
-		case err, ok := <-s.watcher.Errors:
-			if !ok {
-				return
-			}
-			fmt.Printf("File watcher error: %v\n", err)
-		}
+// removePreview deletes the generated preview of a media file and clears its
+// PreviewPath so it will be regenerated. The perceptual hash is computed
+// alongside the preview and is cleared with it.
+func (s *MediaScanner) removePreview(file *models.MediaFile) {
+	file.PerceptualHash = nil
+	if file.PreviewPath == "" {
+		return
+	}
+	if err := os.Remove(file.PreviewPath); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("[WARN] Failed to remove stale preview %s: %v\n", file.PreviewPath, err)
 	}
+	file.PreviewPath = ""
 }
 
-func (s *MediaScanner) handleNewFile(filePath string) {
-	info, err := os.Stat(filePath)
-	if err != nil {
+// movePreview renames the preview of a media file whose path changed after
+// the new path, so the preview cache still finds it. A preview that cannot
+// be moved is forgotten and regenerated.
+func movePreview(file *models.MediaFile) {
+	if file.PreviewPath == "" {
 		return
 	}
+	previewPath, err := preview.MovePreview(file.PreviewPath, file.Path, file.Size, file.ModTime)
+	if err != nil {
+		fmt.Printf("[WARN] Failed to move preview %s: %v\n", file.PreviewPath, err)
+	}
+	file.PreviewPath = previewPath
+}
+
+func isHidden(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
 
-	mediaFile := &models.MediaFile{
-		Path:     filePath,
-		Filename: info.Name(),
-		Size:     info.Size(),
-		ModTime:  info.ModTime(),
-		FileType: s.getFileType(filePath),
-		MimeType: s.getMimeType(filePath),
+// isMediaFile reports whether the media type registry knows the file's type,
+// by extension or, for extensionless and unknown files, by content.
+func (s *MediaScanner) isMediaFile(filePath string) bool {
+	if models.MediaTypes.IsMedia(filePath) {
+		return true
 	}
+	_, ok := models.MediaTypes.Detect(filePath)
+	return ok
+}
 
-	err = s.database.CreateMediaFile(mediaFile)
-	if err != nil {
-		fmt.Printf("Error saving new file %s: %v\n", filePath, err)
+// detectType sets the type fields of record from its file's extension and
+// content. The kind follows the content, so a renamed file is still shown
+// and previewed as what it is, and a contradiction between the two is
+// flagged for the user to fix. It reports whether the content was
+// recognised.
+func (s *MediaScanner) detectType(record *models.MediaFile) bool {
+	detection, _ := models.MediaTypes.Detect(record.Path)
+	mediaType, ok := detection.Type()
+	if !ok {
+		mediaType.Kind = models.KindUnknown
+	}
+	record.FileType = string(mediaType.Kind)
+	record.MimeType = models.MediaTypes.MIMEType(record.Path)
+	record.DetectedType = detection.ByContent.MIMEType
+	record.TypeMismatch = detection.Mismatch()
+	if record.TypeMismatch {
+		fmt.Printf("[WARN] %s looks like %s, not %s as its extension says\n", record.Path, record.DetectedType, record.MimeType)
 	}
+	return record.DetectedType != ""
 }
 
-func (s *MediaScanner) handleFileRemoval(filePath string) {
-	// TODO: Implement file removal from database
-	fmt.Printf("File removed: %s\n", filePath)
+// FixExtension renames a file whose content contradicts its extension to the
+// first extension of its detected type, e.g. clip.mp4 holding a JPEG to
+// clip.jpg, and updates its record. An existing file is never overwritten.
+func FixExtension(database *db.Database, file models.MediaFile) (models.MediaFile, error) {
+	mediaType, ok := models.MediaTypes.ByMIMEType(file.DetectedType)
+	if !file.TypeMismatch || !ok || len(mediaType.Extensions) == 0 {
+		return file, fmt.Errorf("%s has no known type to fix its extension to", file.Filename)
+	}
+	newPath := strings.TrimSuffix(file.Path, filepath.Ext(file.Path)) + mediaType.Extensions[0]
+	if _, err := os.Lstat(newPath); err == nil {
+		return file, fmt.Errorf("cannot rename %s: %s already exists", file.Filename, filepath.Base(newPath))
+	}
+	if err := os.Rename(file.Path, newPath); err != nil {
+		return file, fmt.Errorf("failed to rename %s: %w", file.Filename, err)
+	}
+	file.Path = newPath
+	file.Filename = filepath.Base(newPath)
+	file.MimeType = mediaType.MIMEType
+	file.TypeMismatch = false
+	movePreview(&file)
+	if err := database.UpdateMediaFile(&file); err != nil {
+		return file, fmt.Errorf("failed to update record of %s: %w", newPath, err)
+	}
+	return file, nil
 }
 
 func (s *MediaScanner) Close() error {
@@ -0,0 +1,297 @@
+package scanner
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/pkg/models"
+)
+
+func newTestScanner(t *testing.T) (*MediaScanner, *db.Database) {
+	t.Helper()
+	database, err := db.NewDatabase(filepath.Join(t.TempDir(), "scanner_test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	mediaScanner, err := NewMediaScanner(database)
+	if err != nil {
+		t.Fatalf("Failed to create media scanner: %v", err)
+	}
+	t.Cleanup(func() { mediaScanner.Close() })
+	return mediaScanner, database
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestScanDirectoryIncremental(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaDir := t.TempDir()
+
+	photo := filepath.Join(mediaDir, "photo.jpg")
+	clip := filepath.Join(mediaDir, "nested", "clip.mp4")
+	writeFile(t, photo, "photo")
+	writeFile(t, clip, "clip")
+	writeFile(t, filepath.Join(mediaDir, "notes.txt"), "not media")
+
+	summary, err := mediaScanner.ScanDirectory(mediaDir)
+	if err != nil {
+		t.Fatalf("Initial scan failed: %v", err)
+	}
+	if summary != (ScanSummary{Added: 2}) {
+		t.Fatalf("Unexpected initial summary: %+v", summary)
+	}
+
+	// Tag the photo so we can check the association survives rescans
+	var photoRecord models.MediaFile
+	if err := database.GetDB().Where("path = ?", photo).First(&photoRecord).Error; err != nil {
+		t.Fatalf("Photo not stored: %v", err)
+	}
+	tag := models.Tag{Name: "beach"}
+	if err := database.CreateTag(&tag); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := database.GetDB().Model(&photoRecord).Association("Tags").Append(&tag); err != nil {
+		t.Fatalf("Failed to tag photo: %v", err)
+	}
+
+	// Change the clip, add a new file and leave the photo untouched
+	writeFile(t, clip, "clip with more data")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(clip, later, later); err != nil {
+		t.Fatalf("Failed to touch clip: %v", err)
+	}
+	writeFile(t, filepath.Join(mediaDir, "new.png"), "png")
+
+	summary, err = mediaScanner.ScanDirectory(mediaDir)
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if summary != (ScanSummary{Added: 1, Changed: 1, Unchanged: 1}) {
+		t.Fatalf("Unexpected rescan summary: %+v", summary)
+	}
+
+	var clipRecord models.MediaFile
+	if err := database.GetDB().Where("path = ?", clip).First(&clipRecord).Error; err != nil {
+		t.Fatalf("Clip not stored: %v", err)
+	}
+	if clipRecord.Size != int64(len("clip with more data")) {
+		t.Errorf("Clip size not updated: got %d", clipRecord.Size)
+	}
+
+	// Removing the photo marks it missing but keeps its record and tags
+	if err := os.Remove(photo); err != nil {
+		t.Fatalf("Failed to remove photo: %v", err)
+	}
+	summary, err = mediaScanner.ScanDirectory(mediaDir)
+	if err != nil {
+		t.Fatalf("Rescan after removal failed: %v", err)
+	}
+	if summary != (ScanSummary{Removed: 1, Unchanged: 2}) {
+		t.Fatalf("Unexpected summary after removal: %+v", summary)
+	}
+
+	var reloaded models.MediaFile
+	if err := database.GetDB().Preload("Tags").First(&reloaded, photoRecord.ID).Error; err != nil {
+		t.Fatalf("Photo record was deleted: %v", err)
+	}
+	if !reloaded.Missing {
+		t.Errorf("Expected photo to be marked missing")
+	}
+	if len(reloaded.Tags) != 1 || reloaded.Tags[0].Name != "beach" {
+		t.Errorf("Expected photo to keep its tag, got %+v", reloaded.Tags)
+	}
+
+	var count int64
+	database.GetDB().Model(&models.MediaFile{}).Count(&count)
+	if count != 3 {
+		t.Errorf("Expected 3 media file records, got %d", count)
+	}
+}
+
+func TestScanDirectoryIgnoresSiblingPrefix(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	root := t.TempDir()
+	album := filepath.Join(root, "album")
+	sibling := filepath.Join(root, "album2")
+	writeFile(t, filepath.Join(album, "a.jpg"), "a")
+	writeFile(t, filepath.Join(sibling, "b.jpg"), "b")
+
+	if _, err := mediaScanner.ScanDirectory(sibling); err != nil {
+		t.Fatalf("Scan of sibling failed: %v", err)
+	}
+	summary, err := mediaScanner.ScanDirectory(album)
+	if err != nil {
+		t.Fatalf("Scan of album failed: %v", err)
+	}
+	if summary.Removed != 0 {
+		t.Errorf("Scanning %s must not touch files in %s: %+v", album, sibling, summary)
+	}
+
+	var missing int64
+	database.GetDB().Model(&models.MediaFile{}).Where("missing = ?", true).Count(&missing)
+	if missing != 0 {
+		t.Errorf("Expected no missing records, got %d", missing)
+	}
+}
+
+func TestScanDirectoryHashesDuplicates(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaDir := t.TempDir()
+	writeFile(t, filepath.Join(mediaDir, "phone1", "IMG_0001.jpg"), "same picture")
+	writeFile(t, filepath.Join(mediaDir, "phone2", "IMG_0001.jpg"), "same picture")
+	writeFile(t, filepath.Join(mediaDir, "phone2", "IMG_0002.jpg"), "other picture")
+
+	if _, err := mediaScanner.ScanDirectory(mediaDir); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	groups, err := database.GetDuplicateGroups()
+	if err != nil {
+		t.Fatalf("GetDuplicateGroups failed: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].Files) != 2 {
+		t.Fatalf("Expected one group of two files, got %+v", groups)
+	}
+
+	// Only duplicate candidates get a full hash unless full hashing is enabled
+	unique, _ := database.GetMediaFileByPath(filepath.Join(mediaDir, "phone2", "IMG_0002.jpg"))
+	if unique.ContentHash != "" {
+		t.Errorf("Expected no content hash for a unique file, got %s", unique.ContentHash)
+	}
+	if unique.Fingerprint == "" {
+		t.Errorf("Expected every file to get a fingerprint")
+	}
+
+	mediaScanner.SetFullHashing(true)
+	if _, err := mediaScanner.ScanDirectory(mediaDir); err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	unique, _ = database.GetMediaFileByPath(unique.Path)
+	if unique.ContentHash == "" {
+		t.Errorf("Expected full hashing to backfill the content hash")
+	}
+}
+
+func TestScanDirectoryReadsImageDimensions(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaDir := t.TempDir()
+	photo := filepath.Join(mediaDir, "photo.png")
+
+	f, err := os.Create(photo)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	f.Close()
+	writeFile(t, filepath.Join(mediaDir, "broken.jpg"), "not really a jpeg")
+
+	if _, err := mediaScanner.ScanDirectory(mediaDir); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	record, _ := database.GetMediaFileByPath(photo)
+	if record == nil || record.Width != 64 || record.Height != 48 {
+		t.Fatalf("Expected 64x48 dimensions, got %+v", record)
+	}
+
+	// Files without readable metadata are not rewritten on every rescan
+	summary, err := mediaScanner.ScanDirectory(mediaDir)
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if summary != (ScanSummary{Unchanged: 2}) {
+		t.Errorf("Unexpected rescan summary: %+v", summary)
+	}
+
+	// nor probed again until they change
+	broken, _ := database.GetMediaFileByPath(filepath.Join(mediaDir, "broken.jpg"))
+	if broken == nil || broken.ProbedAt == nil {
+		t.Fatalf("Expected the probe of the broken file to be recorded, got %+v", broken)
+	}
+	if mediaScanner.needsProbe(broken) {
+		t.Errorf("Expected a probed file without metadata to be skipped")
+	}
+	if _, err := mediaScanner.ScanDirectory(mediaDir); err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if again, _ := database.GetMediaFileByPath(broken.Path); !again.ProbedAt.Equal(*broken.ProbedAt) {
+		t.Errorf("Expected the broken file not to be probed again, probed at %v and %v", broken.ProbedAt, again.ProbedAt)
+	}
+}
+
+func TestScanDirectoryDetectsContent(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaDir := t.TempDir()
+
+	jpeg := "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+	writeFile(t, filepath.Join(mediaDir, "renamed.mp4"), jpeg)
+	writeFile(t, filepath.Join(mediaDir, "download"), jpeg)
+	writeFile(t, filepath.Join(mediaDir, "photo.jpg"), jpeg)
+	writeFile(t, filepath.Join(mediaDir, "README"), "not media")
+	writeFile(t, filepath.Join(mediaDir, "notes.txt"), jpeg)
+
+	summary, err := mediaScanner.ScanDirectory(mediaDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if summary.Added != 3 {
+		t.Fatalf("Expected the renamed, extensionless and plain photos to be added, got %+v", summary)
+	}
+
+	record := func(name string) *models.MediaFile {
+		t.Helper()
+		file, err := database.GetMediaFileByPath(filepath.Join(mediaDir, name))
+		if err != nil || file == nil {
+			t.Fatalf("Expected %s to be stored: %v", name, err)
+		}
+		return file
+	}
+	renamed := record("renamed.mp4")
+	if renamed.FileType != "image" || renamed.MimeType != "video/mp4" || renamed.DetectedType != "image/jpeg" || !renamed.TypeMismatch {
+		t.Errorf("Expected a flagged JPEG named as MP4, got %+v", renamed)
+	}
+	if download := record("download"); download.FileType != "image" || download.DetectedType != "image/jpeg" || download.TypeMismatch {
+		t.Errorf("Expected an extensionless JPEG without a mismatch, got %+v", download)
+	}
+	if photo := record("photo.jpg"); photo.TypeMismatch {
+		t.Errorf("Expected a JPEG named .jpg not to be flagged")
+	}
+
+	fixed, err := FixExtension(database, *renamed)
+	if err != nil {
+		t.Fatalf("Failed to fix extension: %v", err)
+	}
+	if fixed.Filename != "renamed.jpg" || fixed.TypeMismatch || fixed.MimeType != "image/jpeg" {
+		t.Errorf("Expected the file renamed to .jpg, got %+v", fixed)
+	}
+	if _, err := os.Stat(fixed.Path); err != nil {
+		t.Errorf("Expected the renamed file on disk: %v", err)
+	}
+	if stored := record("renamed.jpg"); stored.ID != renamed.ID {
+		t.Errorf("Expected the record to follow the rename, got ID %d", stored.ID)
+	}
+
+	// A fix never overwrites another file
+	writeFile(t, filepath.Join(mediaDir, "clash.mp4"), jpeg)
+	writeFile(t, filepath.Join(mediaDir, "clash.jpg"), "other")
+	clash := &models.MediaFile{Path: filepath.Join(mediaDir, "clash.mp4"), Filename: "clash.mp4", DetectedType: "image/jpeg", TypeMismatch: true}
+	if _, err := FixExtension(database, *clash); err == nil {
+		t.Errorf("Expected renaming onto an existing file to fail")
+	}
+}
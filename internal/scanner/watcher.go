@@ -0,0 +1,381 @@
+package scanner
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// defaultDebounce is how long a path has to stay quiet before its queued
+// filesystem events are applied to the database.
+const defaultDebounce = 500 * time.Millisecond
+
+// pendingEvent accumulates the fsnotify operations seen for one path until
+// the debounce window for that path expires.
+type pendingEvent struct {
+	op       fsnotify.Op
+	deadline time.Time
+}
+
+// SetDebounce sets the window used to coalesce bursts of events per path.
+// It must be called before StartWatching.
+func (s *MediaScanner) SetDebounce(window time.Duration) {
+	if window <= 0 {
+		window = defaultDebounce
+	}
+	s.debounce = window
+}
+
+// SetOnChange registers a callback that runs after watched changes have been
+// written to the database. It is called from the watcher goroutine, so UI
+// updates must be wrapped in fyne.Do.
+func (s *MediaScanner) SetOnChange(callback func()) {
+	s.onChange = callback
+}
+
+// StartWatching watches the given directories and all of their
+// subdirectories. Directories created later are picked up automatically.
+func (s *MediaScanner) StartWatching(directories []string) error {
+	for _, dir := range directories {
+		if err := s.addWatchRecursive(dir); err != nil {
+			return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+		}
+	}
+
+	go s.watchLoop()
+	return nil
+}
+
+// addWatchRecursive registers dir and every non-hidden directory below it.
+func (s *MediaScanner) addWatchRecursive(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			fmt.Printf("[DEBUG] Error walking path %s: %v\n", path, err)
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && isHidden(path) {
+			return filepath.SkipDir
+		}
+		if err := s.watcher.Add(path); err != nil {
+			if path == dir {
+				return err
+			}
+			fmt.Printf("[WARN] Failed to watch directory %s: %v\n", path, err)
+		}
+		return nil
+	})
+}
+
+func (s *MediaScanner) watchLoop() {
+	// Check for expired debounce windows a few times per window
+	tick := s.debounce / 4
+	if tick < 10*time.Millisecond {
+		tick = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			s.queueEvent(event)
+
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("File watcher error: %v\n", err)
+
+		case now := <-ticker.C:
+			s.flushPending(now)
+		}
+	}
+}
+
+// queueEvent records event for its path and restarts that path's debounce window.
+func (s *MediaScanner) queueEvent(event fsnotify.Event) {
+	// Permission changes and editor temp files never affect the library
+	if event.Op == fsnotify.Chmod || isHidden(event.Name) {
+		return
+	}
+	pending, ok := s.pending[event.Name]
+	if !ok {
+		pending = &pendingEvent{}
+		s.pending[event.Name] = pending
+	}
+	pending.op |= event.Op
+	pending.deadline = time.Now().Add(s.debounce)
+}
+
+// flushPending applies every path whose debounce window has expired and
+// deletes removed files that were not claimed by a move in time.
+func (s *MediaScanner) flushPending(now time.Time) {
+	changed := s.expireRemovals(now)
+
+	var due []string
+	for path, pending := range s.pending {
+		if !now.Before(pending.deadline) {
+			due = append(due, path)
+		}
+	}
+	// Parents sort before their children, so a new directory is watched
+	// and scanned before events for files inside it are applied
+	sort.Strings(due)
+
+	// Removals go first so a rename arriving in the same batch as its
+	// Create can be recognised as a move
+	var present []string
+	ops := make(map[string]fsnotify.Op, len(due))
+	for _, path := range due {
+		ops[path] = s.pending[path].op
+		delete(s.pending, path)
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			s.handleFileRemoval(path)
+			changed = true
+			continue
+		}
+		present = append(present, path)
+	}
+	for _, path := range present {
+		s.applyEvent(path, ops[path])
+		changed = true
+	}
+	if len(present) > 0 {
+		s.hashDuplicateCandidates()
+	}
+
+	if changed && s.onChange != nil {
+		s.onChange()
+	}
+}
+
+// applyEvent translates the coalesced events for a path that still exists
+// into database changes: new directories are watched and scanned, media
+// files are inserted, updated or matched against a recent removal.
+func (s *MediaScanner) applyEvent(path string, op fsnotify.Op) {
+	fmt.Printf("[DEBUG] Applying watched change %s for %s\n", op, path)
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Printf("[WARN] Failed to stat %s: %v\n", path, err)
+		return
+	}
+
+	if info.IsDir() {
+		// Watching an already watched directory is a no-op
+		if err := s.addWatchRecursive(path); err != nil {
+			fmt.Printf("[WARN] Failed to watch new directory %s: %v\n", path, err)
+		}
+		// Files copied in before the watch was registered produced no events
+		s.scanNewDirectory(path)
+		return
+	}
+
+	if !s.isMediaFile(path) {
+		return
+	}
+	s.handleFileChange(path, info)
+}
+
+// scanNewDirectory feeds every media file below dir through handleFileChange,
+// so files of a moved directory keep their records.
+func (s *MediaScanner) scanNewDirectory(dir string) {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != dir && isHidden(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isHidden(path) || !s.isMediaFile(path) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		s.handleFileChange(path, info)
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error scanning new directory %s: %v\n", dir, err)
+	}
+}
+
+// handleFileChange inserts a new media file or updates the stored record if
+// the file's size or modification time changed. A new file matching a
+// recently removed one by size and fingerprint is treated as a move.
+func (s *MediaScanner) handleFileChange(filePath string, info os.FileInfo) {
+	record, err := s.database.GetMediaFileByPath(filePath)
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", filePath, err)
+		return
+	}
+
+	if record == nil {
+		fingerprint := s.fingerprint(filePath)
+		if moved := s.claimMove(info.Size(), fingerprint); moved != nil {
+			fmt.Printf("[DEBUG] Detected move: %s -> %s\n", moved.Path, filePath)
+			moved.Path = filePath
+			moved.Filename = info.Name()
+			moved.ModTime = info.ModTime()
+			s.detectType(moved)
+			moved.Missing = false
+			movePreview(moved)
+			if err := s.database.UpdateMediaFile(moved); err != nil {
+				fmt.Printf("Error moving file record to %s: %v\n", filePath, err)
+			}
+			return
+		}
+
+		mediaFile := &models.MediaFile{
+			Path:        filePath,
+			Filename:    info.Name(),
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			Fingerprint: fingerprint,
+			ContentHash: s.contentHash(filePath),
+		}
+		s.detectType(mediaFile)
+		s.readMetadata(mediaFile)
+		if err := s.database.CreateMediaFile(mediaFile); err != nil {
+			fmt.Printf("Error saving new file %s: %v\n", filePath, err)
+			return
+		}
+		s.saveExifMetadata([]*models.MediaFile{mediaFile})
+		return
+	}
+
+	contentChanged := record.Size != info.Size() || !record.ModTime.Equal(info.ModTime())
+	if !record.Missing && !contentChanged {
+		return
+	}
+	record.Size = info.Size()
+	record.ModTime = info.ModTime()
+	record.Missing = false
+	if contentChanged {
+		s.removePreview(record)
+		s.detectType(record)
+		record.Fingerprint = s.fingerprint(filePath)
+		record.ContentHash = s.contentHash(filePath)
+		s.readMetadata(record)
+	}
+	if err := s.database.UpdateMediaFile(record); err != nil {
+		fmt.Printf("Error updating file %s: %v\n", filePath, err)
+		return
+	}
+	if contentChanged {
+		s.saveExifMetadata([]*models.MediaFile{record})
+	}
+}
+
+// removedFile is a media file whose path disappeared. It is kept around for
+// a short while so a Create with the same content can claim it as a move.
+type removedFile struct {
+	record   models.MediaFile
+	deadline time.Time
+}
+
+// moveWindow is how long a removed file waits for a matching Create before
+// its record is deleted.
+func (s *MediaScanner) moveWindow() time.Duration {
+	window := 2 * s.debounce
+	if window < time.Second {
+		window = time.Second
+	}
+	return window
+}
+
+// handleFileRemoval queues the records stored for filePath for deletion. If
+// filePath was a directory, every record below it is queued.
+func (s *MediaScanner) handleFileRemoval(filePath string) {
+	fmt.Printf("File removed: %s\n", filePath)
+	record, err := s.database.GetMediaFileByPath(filePath)
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", filePath, err)
+		return
+	}
+
+	var records []models.MediaFile
+	if record != nil {
+		records = append(records, *record)
+	} else {
+		records, err = s.database.GetMediaFilesByDirectory(filePath)
+		if err != nil {
+			fmt.Printf("Error loading files below %s: %v\n", filePath, err)
+			return
+		}
+	}
+
+	deadline := time.Now().Add(s.moveWindow())
+	for _, record := range records {
+		if !s.isPendingRemoval(record.ID) {
+			s.removed = append(s.removed, removedFile{record: record, deadline: deadline})
+		}
+	}
+}
+
+func (s *MediaScanner) isPendingRemoval(id uint) bool {
+	for _, removed := range s.removed {
+		if removed.record.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// claimMove returns a pending removal with the same size and fingerprint,
+// taking it off the removal queue, or nil if there is none.
+func (s *MediaScanner) claimMove(size int64, fingerprint string) *models.MediaFile {
+	if fingerprint == "" {
+		return nil
+	}
+	for i, removed := range s.removed {
+		if removed.record.Size == size && removed.record.Fingerprint == fingerprint {
+			s.removed = append(s.removed[:i], s.removed[i+1:]...)
+			return &removed.record
+		}
+	}
+	return nil
+}
+
+// expireRemovals deletes the records (with their tags and previews) of
+// removed files whose move window passed without a matching Create.
+func (s *MediaScanner) expireRemovals(now time.Time) bool {
+	kept := s.removed[:0]
+	expired := false
+	for _, removed := range s.removed {
+		if now.Before(removed.deadline) {
+			kept = append(kept, removed)
+			continue
+		}
+		expired = true
+		record := removed.record
+		if err := s.database.DeleteMediaFile(&record); err != nil {
+			fmt.Printf("Error deleting record for %s: %v\n", record.Path, err)
+			continue
+		}
+		s.removePreview(&record)
+		fmt.Printf("[DEBUG] Deleted record for removed file: %s\n", record.Path)
+	}
+	s.removed = kept
+	return expired
+}
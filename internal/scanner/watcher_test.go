@@ -0,0 +1,255 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/internal/preview"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// waitForRecord polls until check returns true for the record stored at path.
+func waitForRecord(t *testing.T, database *db.Database, path string, check func(*models.MediaFile) bool) *models.MediaFile {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		record, err := database.GetMediaFileByPath(path)
+		if err != nil {
+			t.Fatalf("Failed to query %s: %v", path, err)
+		}
+		if check(record) {
+			return record
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for watched change to %s", path)
+	return nil
+}
+
+func exists(record *models.MediaFile) bool {
+	return record != nil
+}
+
+func TestWatchNestedDirectories(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaScanner.SetDebounce(50 * time.Millisecond)
+	mediaDir := t.TempDir()
+	existingDir := filepath.Join(mediaDir, "2023", "june")
+	if err := os.MkdirAll(existingDir, 0755); err != nil {
+		t.Fatalf("Failed to create nested directory: %v", err)
+	}
+
+	changes := make(chan struct{}, 100)
+	mediaScanner.SetOnChange(func() { changes <- struct{}{} })
+	if err := mediaScanner.StartWatching([]string{mediaDir}); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+
+	// A file in a subdirectory that existed when watching started
+	nested := filepath.Join(existingDir, "beach.jpg")
+	writeFile(t, nested, "beach")
+	waitForRecord(t, database, nested, exists)
+
+	// A directory created after watching started, with a file written into it
+	newDir := filepath.Join(mediaDir, "2024", "album")
+	albumFile := filepath.Join(newDir, "party.mp4")
+	writeFile(t, albumFile, "party")
+	waitForRecord(t, database, albumFile, exists)
+
+	// Files added later to the new directory are seen as well
+	later := filepath.Join(newDir, "later.png")
+	writeFile(t, later, "later")
+	waitForRecord(t, database, later, exists)
+
+	// Writes update the stored size
+	writeFile(t, nested, "beach, but longer")
+	waitForRecord(t, database, nested, func(record *models.MediaFile) bool {
+		return record != nil && record.Size == int64(len("beach, but longer"))
+	})
+
+	select {
+	case <-changes:
+	default:
+		t.Errorf("Expected OnChange to be called")
+	}
+}
+
+func TestQueueEventCoalescesPerPath(t *testing.T) {
+	mediaScanner, _ := newTestScanner(t)
+	mediaScanner.SetDebounce(time.Hour)
+
+	path := filepath.Join(t.TempDir(), "clip.mp4")
+	mediaScanner.queueEvent(fsnotify.Event{Name: path, Op: fsnotify.Create})
+	mediaScanner.queueEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+	mediaScanner.queueEvent(fsnotify.Event{Name: path, Op: fsnotify.Write})
+	mediaScanner.queueEvent(fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+	mediaScanner.queueEvent(fsnotify.Event{Name: filepath.Join(filepath.Dir(path), ".clip.mp4.swp"), Op: fsnotify.Create})
+
+	if len(mediaScanner.pending) != 1 {
+		t.Fatalf("Expected 1 pending path, got %d", len(mediaScanner.pending))
+	}
+	if op := mediaScanner.pending[path].op; op != fsnotify.Create|fsnotify.Write {
+		t.Errorf("Expected Create|Write, got %s", op)
+	}
+
+	// Nothing is applied before the window expires
+	mediaScanner.flushPending(time.Now())
+	if len(mediaScanner.pending) != 1 {
+		t.Errorf("Pending event flushed before its debounce window expired")
+	}
+	mediaScanner.flushPending(time.Now().Add(2 * time.Hour))
+	if len(mediaScanner.pending) != 0 {
+		t.Errorf("Expected pending events to be flushed, %d left", len(mediaScanner.pending))
+	}
+}
+
+// watchTaggedFile scans mediaDir, tags the record stored at path and starts watching.
+func watchTaggedFile(t *testing.T, mediaScanner *MediaScanner, database *db.Database, mediaDir, path string) models.MediaFile {
+	t.Helper()
+	if _, err := mediaScanner.ScanDirectory(mediaDir); err != nil {
+		t.Fatalf("Initial scan failed: %v", err)
+	}
+	record, err := database.GetMediaFileByPath(path)
+	if err != nil || record == nil {
+		t.Fatalf("Record for %s not stored: %v", path, err)
+	}
+	tag := models.Tag{Name: "keep"}
+	if err := database.CreateTag(&tag); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := database.GetDB().Model(record).Association("Tags").Append(&tag); err != nil {
+		t.Fatalf("Failed to tag %s: %v", path, err)
+	}
+	if err := mediaScanner.StartWatching([]string{mediaDir}); err != nil {
+		t.Fatalf("StartWatching failed: %v", err)
+	}
+	return *record
+}
+
+func TestWatchRemovesDeletedFiles(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaScanner.SetDebounce(20 * time.Millisecond)
+	mediaDir := t.TempDir()
+	photo := filepath.Join(mediaDir, "photo.jpg")
+	writeFile(t, photo, "photo")
+	record := watchTaggedFile(t, mediaScanner, database, mediaDir, photo)
+
+	preview := filepath.Join(t.TempDir(), "photo.gif")
+	writeFile(t, preview, "gif")
+	database.GetDB().Model(&record).Update("preview_path", preview)
+
+	if err := os.Remove(photo); err != nil {
+		t.Fatalf("Failed to remove photo: %v", err)
+	}
+	waitForRecord(t, database, photo, func(record *models.MediaFile) bool { return record == nil })
+
+	var tagLinks int64
+	database.GetDB().Table("file_tags").Where("media_file_id = ?", record.ID).Count(&tagLinks)
+	if tagLinks != 0 {
+		t.Errorf("Expected file_tags rows to be removed, got %d", tagLinks)
+	}
+	if _, err := os.Stat(preview); !os.IsNotExist(err) {
+		t.Errorf("Expected preview %s to be removed", preview)
+	}
+}
+
+func TestWatchMovesPreviews(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaScanner.SetDebounce(20 * time.Millisecond)
+	mediaDir, previewDir := t.TempDir(), t.TempDir()
+	photo := filepath.Join(mediaDir, "photo.jpg")
+	writeFile(t, photo, "photo")
+	record := watchTaggedFile(t, mediaScanner, database, mediaDir, photo)
+
+	key := preview.CacheKey(record.Path, record.Size, record.ModTime)
+	previewPath := filepath.Join(previewDir, key+"_180.jpg")
+	writeFile(t, previewPath, "thumbnail")
+	writeFile(t, filepath.Join(previewDir, key+"_360.jpg"), "large thumbnail")
+	if err := database.SetPreview(record.ID, previewPath, 0); err != nil {
+		t.Fatalf("Failed to set preview: %v", err)
+	}
+
+	movedPhoto := filepath.Join(mediaDir, "sorted", "photo.jpg")
+	if err := os.MkdirAll(filepath.Dir(movedPhoto), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.Rename(photo, movedPhoto); err != nil {
+		t.Fatalf("Failed to move photo: %v", err)
+	}
+	moved := waitForRecord(t, database, movedPhoto, exists)
+
+	// The previews follow the file to its new cache key and survive a prune
+	sources, err := database.GetPreviewSources()
+	if err != nil {
+		t.Fatalf("Failed to load preview sources: %v", err)
+	}
+	if _, err := preview.NewCacheManager(previewDir, 0).Prune(preview.LiveCacheKeys(sources)); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	newKey := preview.CacheKey(moved.Path, moved.Size, moved.ModTime)
+	if want := filepath.Join(previewDir, newKey+"_180.jpg"); moved.PreviewPath != want {
+		t.Errorf("Expected preview path %s, got %s", want, moved.PreviewPath)
+	}
+	for _, name := range []string{newKey + "_180.jpg", newKey + "_360.jpg"} {
+		if _, err := os.Stat(filepath.Join(previewDir, name)); err != nil {
+			t.Errorf("Expected preview %s to survive the prune: %v", name, err)
+		}
+	}
+}
+
+func TestWatchTracksMoves(t *testing.T) {
+	mediaScanner, database := newTestScanner(t)
+	mediaScanner.SetDebounce(20 * time.Millisecond)
+	mediaDir := t.TempDir()
+	photo := filepath.Join(mediaDir, "inbox", "photo.jpg")
+	writeFile(t, photo, "photo")
+	writeFile(t, filepath.Join(mediaDir, "inbox", "other.jpg"), "other")
+	if err := os.MkdirAll(filepath.Join(mediaDir, "sorted"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	record := watchTaggedFile(t, mediaScanner, database, mediaDir, photo)
+
+	// Move a single file into another subdirectory
+	movedPhoto := filepath.Join(mediaDir, "sorted", "beach.jpg")
+	if err := os.Rename(photo, movedPhoto); err != nil {
+		t.Fatalf("Failed to move photo: %v", err)
+	}
+	moved := waitForRecord(t, database, movedPhoto, exists)
+	if moved.ID != record.ID {
+		t.Errorf("Expected moved file to keep ID %d, got %d", record.ID, moved.ID)
+	}
+
+	// Move a whole directory
+	archive := filepath.Join(mediaDir, "archive")
+	if err := os.Rename(filepath.Join(mediaDir, "sorted"), archive); err != nil {
+		t.Fatalf("Failed to move directory: %v", err)
+	}
+	archived := waitForRecord(t, database, filepath.Join(archive, "beach.jpg"), exists)
+	if archived.ID != record.ID {
+		t.Errorf("Expected file in moved directory to keep ID %d, got %d", record.ID, archived.ID)
+	}
+
+	// Let the move window of the old paths expire
+	time.Sleep(2 * mediaScanner.moveWindow())
+	var reloaded models.MediaFile
+	if err := database.GetDB().Preload("Tags").First(&reloaded, record.ID).Error; err != nil {
+		t.Fatalf("Moved record was deleted: %v", err)
+	}
+	if len(reloaded.Tags) != 1 || reloaded.Tags[0].Name != "keep" {
+		t.Errorf("Expected moved file to keep its tag, got %+v", reloaded.Tags)
+	}
+	if reloaded.Filename != "beach.jpg" {
+		t.Errorf("Expected filename to follow the move, got %s", reloaded.Filename)
+	}
+
+	var count int64
+	database.GetDB().Model(&models.MediaFile{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 media file records after moves, got %d", count)
+	}
+}
@@ -0,0 +1,121 @@
+package components
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// The bundled fonts have no star or heart glyphs, so the marks on a card
+// are drawn from these icons.
+var (
+	starIcon = fyne.NewStaticResource("star.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">`+
+		`<path fill="#ffc107" d="M12 17.27 18.18 21l-1.64-7.03L22 9.24l-7.19-.61L12 2 9.19 8.63 2 9.24l5.46 4.73L5.82 21z"/></svg>`))
+	heartIcon = fyne.NewStaticResource("heart.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">`+
+		`<path fill="#e53935" d="m12 21.35-1.45-1.32C5.4 15.36 2 12.28 2 8.5 2 5.42 4.42 3 7.5 3c1.74 0 3.41.81 4.5 2.09`+
+		`C13.09 3.81 14.76 3 16.5 3 19.58 3 22 5.42 22 8.5c0 3.78-3.4 6.86-8.55 11.54z"/></svg>`))
+	pickIcon = fyne.NewStaticResource("pick.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">`+
+		`<path fill="#43a047" d="M14.4 6 14 4H5v17h2v-7h5.6l.4 2h7V6z"/></svg>`))
+	rejectIcon = fyne.NewStaticResource("reject.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">`+
+		`<path fill="#e53935" d="M19 6.41 17.59 5 12 10.59 6.41 5 5 6.41 10.59 12 5 17.59 6.41 19 12 13.41 17.59 19 19 17.59 13.41 12z"/></svg>`))
+)
+
+// markIconSize is the size of a star or badge on a card.
+const markIconSize = 14
+
+// labelColors are how color labels are drawn.
+var labelColors = map[models.ColorLabel]color.NRGBA{
+	models.LabelRed:    {0xe5, 0x39, 0x35, 0xff},
+	models.LabelYellow: {0xfd, 0xd8, 0x35, 0xff},
+	models.LabelGreen:  {0x43, 0xa0, 0x47, 0xff},
+	models.LabelBlue:   {0x1e, 0x88, 0xe5, 0xff},
+	models.LabelPurple: {0x8e, 0x24, 0xaa, 0xff},
+}
+
+// LabelColor returns the color a color label is drawn in; nil for none.
+func LabelColor(label models.ColorLabel) color.Color {
+	if c, ok := labelColors[label]; ok {
+		return c
+	}
+	return nil
+}
+
+func newMarkIcon(icon fyne.Resource) fyne.CanvasObject {
+	img := canvas.NewImageFromResource(icon)
+	img.FillMode = canvas.ImageFillContain
+	img.SetMinSize(fyne.NewSize(markIconSize, markIconSize))
+	return img
+}
+
+// newMarkChip lays out icons on a dark rounded background that keeps them
+// readable on any preview.
+func newMarkChip(icons ...fyne.Resource) *fyne.Container {
+	row := container.New(layout.NewCustomPaddedHBoxLayout(1))
+	for _, icon := range icons {
+		row.Add(newMarkIcon(icon))
+	}
+	background := canvas.NewRectangle(color.NRGBA{0, 0, 0, 160})
+	background.CornerRadius = 4
+	return container.NewStack(background, container.New(layout.NewCustomPaddedLayout(2, 2, 3, 3), row))
+}
+
+// newRatingChip shows a rating as that many stars; nil when unrated.
+func newRatingChip(rating int) *fyne.Container {
+	stars := ratingIcons(rating)
+	if len(stars) == 0 {
+		return nil
+	}
+	return newMarkChip(stars...)
+}
+
+// ratingIcons returns the stars drawn for a rating.
+func ratingIcons(rating int) []fyne.Resource {
+	stars := make([]fyne.Resource, max(0, min(rating, models.MaxRating)))
+	for i := range stars {
+		stars[i] = starIcon
+	}
+	return stars
+}
+
+// newBadgeChip shows the favorite and pick/reject flags; nil when neither
+// is set.
+func newBadgeChip(favorite bool, flag models.Flag) *fyne.Container {
+	icons := badgeIcons(favorite, flag)
+	if len(icons) == 0 {
+		return nil
+	}
+	return newMarkChip(icons...)
+}
+
+// badgeIcons returns the badges drawn for the favorite and pick/reject flags.
+func badgeIcons(favorite bool, flag models.Flag) []fyne.Resource {
+	var icons []fyne.Resource
+	if favorite {
+		icons = append(icons, heartIcon)
+	}
+	switch flag {
+	case models.FlagPick:
+		icons = append(icons, pickIcon)
+	case models.FlagReject:
+		icons = append(icons, rejectIcon)
+	}
+	return icons
+}
+
+// ratingLabel names a rating in menus.
+func ratingLabel(rating int) string {
+	switch rating {
+	case 0:
+		return "No Rating"
+	case 1:
+		return "1 Star"
+	default:
+		return fmt.Sprintf("%d Stars", rating)
+	}
+}
@@ -1,7 +1,6 @@
 package components
 
 import (
-	"bytes"
 	"fmt"
 	"image"
 	"image/color"
@@ -19,6 +18,9 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	xwidget "fyne.io/x/fyne/widget"
+
+	"github.com/user/media-manager/internal/preview"
+	"github.com/user/media-manager/pkg/models"
 )
 
 type MediaType int
@@ -45,164 +47,223 @@ type MediaCard struct {
 	isHovered       bool
 	hasAnimation    bool
 	onDelete        func()
+	onFindSimilar   func()
+	onEditTags      func()
+	onEditNotes     func()
+	onAddToAlbum    func()
+	onRemoveAlbum   func()
+	tags            []models.Tag
+	tagChips        *fyne.Container
+	typeWarning     fyne.CanvasObject // shown when the content contradicts the extension
+	onFixExtension  func()
+	fixExtensionTo  string
 	previewWidth    int
 	previewHeight   int
+	thumbnailSize   int // width of the preview area in pixels
+	// onDrop moves the card's file to where it was dragged to, given as an
+	// absolute position; cards are only draggable while it is set
+	onDrop   func(fyne.Position)
+	dragging bool
+	dragPos  fyne.Position
+	// the user's marks of the file, see SetMarks, and the callbacks that
+	// change them from the context menu
+	rating       int
+	favorite     bool
+	flag         models.Flag
+	colorLabel   models.ColorLabel
+	ratingChip   *fyne.Container   // stars in the bottom left corner of the preview
+	badgeChip    *fyne.Container   // favorite and flag in the top right corner
+	labelBar     *canvas.Rectangle // color label under the name
+	rejectShade  *canvas.Rectangle // dims the preview of rejected files
+	onRate       func(int)
+	onFavorite   func(bool)
+	onFlag       func(models.Flag)
+	onColorLabel func(models.ColorLabel)
+	onHover      func(bool)
+	// loadID identifies the file the card is bound to; previews that finish
+	// loading after the card was rebound are dropped
+	loadID uint64
+	// previewSource generates missing previews; previewTicket is the
+	// request for the bound file
+	previewSource PreviewSource
+	previewTicket *preview.Ticket
+}
+
+// previewLoad is a snapshot of the file a card was bound to when a
+// background preview load started.
+type previewLoad struct {
+	id            uint64
+	filePath      string
+	fileName      string
+	thumbnailPath string
 }
 
 func NewMediaCard(filePath, fileName string, mediaType MediaType, thumbPath string) *MediaCard {
 	fmt.Printf("[DEBUG] NewMediaCard: Creating card for %s (Type: %v)\n", fileName, mediaType)
-	displayName := fileName
-	if len(displayName) > 22 {
-		displayName = displayName[:19] + "..."
-	}
-
 	card := &MediaCard{
-		mediaType:     mediaType,
-		filePath:      filePath,
-		fileName:      fileName,
-		thumbnailPath: thumbPath,
 		isHovered:     false,
 		hasAnimation:  false,
+		thumbnailSize: preview.DefaultThumbnailSize,
 	}
 
-	card.setupContent()
-	card.label = widget.NewLabelWithStyle(displayName, fyne.TextAlignCenter, fyne.TextStyle{})
+	card.label = widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{})
 	card.label.Wrapping = fyne.TextWrapWord
 	card.background = canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground))
 	card.background.StrokeColor = color.NRGBA{100, 100, 100, 255}
 	card.background.StrokeWidth = 1
 
+	card.labelBar = canvas.NewRectangle(color.Transparent)
+	card.rejectShade = canvas.NewRectangle(color.NRGBA{0, 0, 0, 150})
+
 	card.labelBackground = canvas.NewLinearGradient(
 		color.NRGBA{0, 0, 0, 0},
 		color.NRGBA{0, 0, 0, 180},
 		90,
 	)
 	card.ExtendBaseWidget(card)
+	card.Bind(filePath, fileName, mediaType, thumbPath)
 
 	// [DEBUG] NewMediaCard: Card created for %s. hasAnimation: %v, animatedGif: %v\n", fileName, card.hasAnimation, card.animatedGif != nil)
 	return card
 }
 
+// Bind points the card at another file so that a virtualized grid can reuse
+// it while scrolling. Rebinding to the file already shown keeps its preview.
+// Tags and callbacks are not reset; the caller sets them for the new file.
+func (mc *MediaCard) Bind(filePath, fileName string, mediaType MediaType, thumbPath string) {
+	if mc.content != nil && mc.filePath == filePath && mc.thumbnailPath == thumbPath && mc.mediaType == mediaType {
+		return
+	}
+	if mc.animatedGif != nil {
+		mc.animatedGif.Stop()
+	}
+	mc.previewTicket.Cancel()
+	mc.previewTicket = nil
+	mc.loadID++
+	mc.mediaType = mediaType
+	mc.filePath = filePath
+	mc.fileName = fileName
+	mc.thumbnailPath = thumbPath
+	mc.animatedGif = nil
+	mc.hasAnimation = false
+	mc.previewWidth, mc.previewHeight = 0, 0
+
+	displayName := fileName
+	if len(displayName) > 22 {
+		displayName = displayName[:19] + "..."
+	}
+	mc.label.SetText(displayName)
+	mc.setupContent()
+	mc.Refresh()
+}
+
 func (mc *MediaCard) setupContent() {
 	fmt.Printf("[DEBUG] setupContent: Setting up content for %s (Type: %v)\n", mc.fileName, mc.mediaType)
 
+	load := previewLoad{id: mc.loadID, filePath: mc.filePath, fileName: mc.fileName, thumbnailPath: mc.thumbnailPath}
 	switch mc.mediaType {
 	case MediaTypeImage:
-		go mc.generateImageThumbnail()
+		mc.content = widget.NewIcon(theme.FileImageIcon())
+		mc.loadPreview(load)
 	case MediaTypeVideo:
-		go mc.generateGifPreview()
+		mc.content = widget.NewIcon(theme.FileVideoIcon())
+		mc.loadPreview(load)
 	case MediaTypeFile:
 		mc.content = widget.NewIcon(theme.FileIcon())
 	}
 }
 
-// generateImageThumbnail generates a still thumbnail for images (not GIFs), or uses the original for GIFs
-func (mc *MediaCard) generateImageThumbnail() {
-	if _, err := os.Stat(mc.filePath); os.IsNotExist(err) {
-		fmt.Printf("[WARN] File does not exist, skipping image thumbnail: %s\n", mc.filePath)
+// PreviewSource asks for the preview of the file at filePath to be
+// generated and calls done with its path once it exists. Cancelling the
+// returned ticket withdraws the request.
+type PreviewSource func(filePath string, done func(previewPath string, err error)) *preview.Ticket
+
+// SetPreviewSource sets where the card gets previews that do not exist yet.
+// Rebinding the card withdraws the request for the previous file.
+func (mc *MediaCard) SetPreviewSource(source PreviewSource) {
+	mc.previewSource = source
+}
+
+// loadPreview shows the existing preview of the bound file or requests one.
+// Without a preview source the placeholder stays.
+func (mc *MediaCard) loadPreview(load previewLoad) {
+	if hasPreview(load.thumbnailPath) {
+		go mc.showPreviewFile(load, load.thumbnailPath)
 		return
 	}
-	fmt.Printf("[DEBUG] Generating image thumbnail for: %s\n", mc.filePath)
-	fmt.Printf("[DEBUG] generateImageThumbnail called for %s\n", mc.fileName)
-	ext := strings.ToLower(filepath.Ext(mc.filePath))
-	if ext == ".gif" {
-		// For GIFs, just use the original file as a still image
-		img := canvas.NewImageFromFile(mc.filePath)
-		img.FillMode = canvas.ImageFillContain
-		mc.content = img
-		mc.Refresh()
+	if mc.previewSource == nil {
 		return
 	}
-	// For other images, generate a thumbnail (jpg)
-	homeDir, _ := os.UserHomeDir()
-	thumbDir := filepath.Join(homeDir, ".media-manager", "thumbnails")
-	os.MkdirAll(thumbDir, 0755)
-	thumbFileName := strings.ReplaceAll(strings.TrimSuffix(filepath.Base(mc.filePath), filepath.Ext(mc.filePath)), " ", "_") + "_thumb.jpg"
-	thumbPath := filepath.Join(thumbDir, thumbFileName)
-	// Only generate if not exists
-	if _, err := os.Stat(thumbPath); os.IsNotExist(err) {
-		// Use ffmpeg to generate a thumbnail for any image type
-		var stderr bytes.Buffer
-		cmd := exec.Command("ffmpeg", "-i", mc.filePath, "-vf", "scale=180:180:force_original_aspect_ratio=increase,crop=180:180", "-frames:v", "1", thumbPath)
-		cmd.Stderr = &stderr
-		err := cmd.Run()
+	mc.previewTicket = mc.previewSource(load.filePath, func(previewPath string, err error) {
 		if err != nil {
-			fmt.Printf("[ERROR] ffmpeg failed to generate GIF for %s: %v\n[ffmpeg stderr]: %s\n", mc.filePath, err, stderr.String())
+			fmt.Printf("[ERROR] No preview for %s: %v\n", load.filePath, err)
 			return
 		}
-	}
-	img := canvas.NewImageFromFile(thumbPath)
-	img.FillMode = canvas.ImageFillContain
-	// Try to get image dimensions
-	if file, err := os.Open(thumbPath); err == nil {
-		defer file.Close()
-		if srcImg, _, err := image.DecodeConfig(file); err == nil {
-			mc.previewWidth = srcImg.Width
-			mc.previewHeight = srcImg.Height
+		mc.showPreviewFile(load, previewPath)
+	})
+}
+
+// showPreview replaces the placeholder with a loaded preview, unless the card
+// was rebound in the meantime.
+func (mc *MediaCard) showPreview(load previewLoad, content fyne.CanvasObject, width, height int, animatedGif *xwidget.AnimatedGif) {
+	fyne.Do(func() {
+		if load.id != mc.loadID {
+			if animatedGif != nil {
+				animatedGif.Stop()
+			}
+			return
 		}
-	}
-	mc.content = img
-	mc.Refresh()
+		mc.previewWidth, mc.previewHeight = width, height
+		mc.animatedGif = animatedGif
+		mc.hasAnimation = animatedGif != nil
+		mc.content = content
+		mc.Refresh()
+	})
 }
 
-func (mc *MediaCard) generateGifPreview() {
-	if _, err := os.Stat(mc.filePath); os.IsNotExist(err) {
-		fmt.Printf("[WARN] File does not exist, skipping GIF preview: %s\n", mc.filePath)
-		return
+// hasPreview reports whether a generated preview exists on disk.
+func hasPreview(previewPath string) bool {
+	if previewPath == "" {
+		return false
 	}
-	fmt.Printf("[DEBUG] Generating animated GIF preview for: %s\n", mc.filePath)
-	fmt.Printf("[DEBUG] generateGifPreview called for %s\n", mc.fileName)
-	if mc.mediaType != MediaTypeVideo {
-		return
-	}
-
-	// Only generate and use GIF for videos
-	homeDir, _ := os.UserHomeDir()
-	gifDir := filepath.Join(homeDir, ".media-manager", "previews")
-	os.MkdirAll(gifDir, 0755)
-
-	gifPath := filepath.Join(gifDir, strings.TrimSuffix(filepath.Base(mc.filePath), filepath.Ext(mc.filePath))+".gif")
+	_, err := os.Stat(previewPath)
+	return err == nil
+}
 
-	if _, err := os.Stat(gifPath); os.IsNotExist(err) {
-		var stderr bytes.Buffer
-		cmd := exec.Command("ffmpeg",
-			"-i", mc.filePath,
-			"-vf", "fps=12,scale=180:180:force_original_aspect_ratio=increase,crop=180:180",
-			"-frames:v", "24",
-			gifPath)
-		cmd.Stderr = &stderr
-		err := cmd.Run()
-		if err != nil {
-			fmt.Printf("[ERROR] ffmpeg failed to generate GIF for %s: %v\n[ffmpeg stderr]: %s\n", mc.filePath, err, stderr.String())
+// showPreviewFile shows the preview at previewPath: animated GIFs play on
+// hover, anything else is shown as a still image.
+func (mc *MediaCard) showPreviewFile(load previewLoad, previewPath string) {
+	fmt.Printf("[DEBUG] Showing preview %s for %s\n", previewPath, load.fileName)
+	preview.Touch(previewPath)
+	if strings.EqualFold(filepath.Ext(previewPath), ".gif") {
+		uri := storage.NewFileURI(previewPath)
+		if animatedGif, err := xwidget.NewAnimatedGif(uri); err == nil {
+			var width, height int
+			// Try to get GIF dimensions
+			if file, err := os.Open(previewPath); err == nil {
+				defer file.Close()
+				if cfg, err := gif.DecodeConfig(file); err == nil {
+					width, height = cfg.Width, cfg.Height
+				}
+			}
+			animatedGif.Stop() // Show first frame only
+			mc.showPreview(load, animatedGif, width, height, animatedGif)
 			return
 		}
-	}
-
-	uri := storage.NewFileURI(gifPath)
-	animatedGif, err := xwidget.NewAnimatedGif(uri)
-	if err != nil {
 		// fallback: use static image with stretch
-		img := canvas.NewImageFromFile(gifPath)
-		img.FillMode = canvas.ImageFillContain
-		mc.content = img
-		mc.Refresh()
-		return
 	}
-	// Try to get GIF dimensions
-	if file, err := os.Open(gifPath); err == nil {
+
+	img := canvas.NewImageFromFile(previewPath)
+	img.FillMode = canvas.ImageFillContain
+	var width, height int
+	// Try to get image dimensions
+	if file, err := os.Open(previewPath); err == nil {
 		defer file.Close()
-		if cfg, err := gif.DecodeConfig(file); err == nil {
-			mc.previewWidth = cfg.Width
-			mc.previewHeight = cfg.Height
+		if srcImg, _, err := image.DecodeConfig(file); err == nil {
+			width, height = srcImg.Width, srcImg.Height
 		}
 	}
-	animatedGif.Stop() // Show first frame only
-	fyne.Do(func() {
-		mc.animatedGif = animatedGif
-		mc.content = animatedGif
-		mc.hasAnimation = true
-		mc.Refresh()
-	})
+	mc.showPreview(load, img, width, height, nil)
 }
 
 var _ desktop.Hoverable = (*MediaCard)(nil)
@@ -212,6 +273,9 @@ func (mc *MediaCard) MouseIn(*desktop.MouseEvent) {
 	mc.isHovered = true
 	mc.background.FillColor = theme.Color(theme.ColorNameHover)
 	mc.background.Refresh()
+	if mc.onHover != nil {
+		mc.onHover(true)
+	}
 
 	fmt.Printf("[DEBUG] MouseIn: mediaType=%v, hasAnimation=%v, mc.content=%v\n", mc.mediaType, mc.hasAnimation, mc.content)
 	if mc.hasAnimation && mc.animatedGif != nil {
@@ -226,6 +290,9 @@ func (mc *MediaCard) MouseOut() {
 	mc.isHovered = false
 	mc.background.FillColor = theme.Color(theme.ColorNameInputBackground)
 	mc.background.Refresh()
+	if mc.onHover != nil {
+		mc.onHover(false)
+	}
 
 	if mc.hasAnimation && mc.animatedGif != nil {
 		mc.animatedGif.Stop()
@@ -238,6 +305,36 @@ func (mc *MediaCard) MouseMoved(*desktop.MouseEvent) {
 	// No action needed
 }
 
+var _ fyne.Draggable = (*MediaCard)(nil)
+
+// Dragged follows a drag of the card while it can be reordered.
+func (mc *MediaCard) Dragged(e *fyne.DragEvent) {
+	if mc.onDrop == nil {
+		return
+	}
+	if !mc.dragging {
+		mc.dragging = true
+		mc.background.StrokeColor = theme.Color(theme.ColorNamePrimary)
+		mc.background.StrokeWidth = 2
+		mc.background.Refresh()
+	}
+	mc.dragPos = e.AbsolutePosition
+}
+
+// DragEnd drops the card where the drag ended.
+func (mc *MediaCard) DragEnd() {
+	if !mc.dragging {
+		return
+	}
+	mc.dragging = false
+	mc.background.StrokeColor = color.NRGBA{100, 100, 100, 255}
+	mc.background.StrokeWidth = 1
+	mc.background.Refresh()
+	if mc.onDrop != nil {
+		mc.onDrop(mc.dragPos)
+	}
+}
+
 func (mc *MediaCard) Tapped(*fyne.PointEvent) {
 	// [DEBUG] MediaCard Tapped: %s\n", mc.filePath)
 	err := mc.openFile()
@@ -259,14 +356,181 @@ func (mc *MediaCard) TappedSecondary(e *fyne.PointEvent) {
 			mc.onDelete()
 		}
 	})
+	var items []*fyne.MenuItem
+	if mc.onEditTags != nil {
+		items = append(items, fyne.NewMenuItem("Edit Tags...", mc.onEditTags))
+	}
+	if mc.onEditNotes != nil {
+		items = append(items, fyne.NewMenuItem("Edit Notes...", mc.onEditNotes))
+	}
+	items = append(items, mc.markMenuItems()...)
+	if mc.onAddToAlbum != nil {
+		items = append(items, fyne.NewMenuItem("Add to Album...", mc.onAddToAlbum))
+	}
+	if mc.onRemoveAlbum != nil {
+		items = append(items, fyne.NewMenuItem("Remove from Album", mc.onRemoveAlbum))
+	}
+	if mc.onFindSimilar != nil {
+		items = append(items, fyne.NewMenuItem("Find Similar", mc.onFindSimilar))
+	}
+	if mc.onFixExtension != nil {
+		items = append(items, fyne.NewMenuItem("Rename to "+mc.fixExtensionTo, mc.onFixExtension))
+	}
+	items = append(items, deleteMenuItem)
 	canvas := fyne.CurrentApp().Driver().CanvasForObject(mc)
-	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", deleteMenuItem), canvas, e.AbsolutePosition)
+	widget.ShowPopUpMenuAtPosition(fyne.NewMenu("", items...), canvas, e.AbsolutePosition)
 }
 
 func (mc *MediaCard) SetOnDelete(callback func()) {
 	mc.onDelete = callback
 }
 
+// SetOnFindSimilar adds a "Find Similar" item to the context menu.
+func (mc *MediaCard) SetOnFindSimilar(callback func()) {
+	mc.onFindSimilar = callback
+}
+
+// SetOnEditTags adds an "Edit Tags..." item to the context menu.
+func (mc *MediaCard) SetOnEditTags(callback func()) {
+	mc.onEditTags = callback
+}
+
+// SetOnEditNotes adds an "Edit Notes..." item to the context menu.
+func (mc *MediaCard) SetOnEditNotes(callback func()) {
+	mc.onEditNotes = callback
+}
+
+// SetOnAddToAlbum adds an "Add to Album..." item to the context menu.
+func (mc *MediaCard) SetOnAddToAlbum(callback func()) {
+	mc.onAddToAlbum = callback
+}
+
+// SetOnRemoveFromAlbum adds a "Remove from Album" item to the context menu;
+// nil removes it.
+func (mc *MediaCard) SetOnRemoveFromAlbum(callback func()) {
+	mc.onRemoveAlbum = callback
+}
+
+// SetOnDrop makes the card draggable; callback receives the absolute
+// position it was dropped at. nil makes the card fixed again.
+func (mc *MediaCard) SetOnDrop(callback func(fyne.Position)) {
+	mc.onDrop = callback
+}
+
+// SetOnHover reports the mouse entering (true) and leaving the card.
+func (mc *MediaCard) SetOnHover(callback func(hovered bool)) {
+	mc.onHover = callback
+}
+
+// SetOnRate adds a "Rating" submenu to the context menu.
+func (mc *MediaCard) SetOnRate(callback func(rating int)) {
+	mc.onRate = callback
+}
+
+// SetOnFavorite adds a "Favorite" item to the context menu that toggles the
+// favorite flag.
+func (mc *MediaCard) SetOnFavorite(callback func(favorite bool)) {
+	mc.onFavorite = callback
+}
+
+// SetOnFlag adds a "Flag" submenu to pick or reject the file.
+func (mc *MediaCard) SetOnFlag(callback func(flag models.Flag)) {
+	mc.onFlag = callback
+}
+
+// SetOnColorLabel adds a "Color Label" submenu to the context menu.
+func (mc *MediaCard) SetOnColorLabel(callback func(label models.ColorLabel)) {
+	mc.onColorLabel = callback
+}
+
+// SetMarks shows the rating as stars, the favorite and pick or reject flags
+// as badges and the color label as a bar under the name. Rejected files are
+// dimmed.
+func (mc *MediaCard) SetMarks(rating int, favorite bool, flag models.Flag, label models.ColorLabel) {
+	mc.rating, mc.favorite, mc.flag, mc.colorLabel = rating, favorite, flag, label
+	mc.ratingChip = newRatingChip(rating)
+	mc.badgeChip = newBadgeChip(favorite, flag)
+	if c := LabelColor(label); c != nil {
+		mc.labelBar.FillColor = c
+	}
+	mc.Refresh()
+}
+
+// markMenuItems returns the context menu items that change the marks, with
+// the current ones checked.
+func (mc *MediaCard) markMenuItems() []*fyne.MenuItem {
+	var items []*fyne.MenuItem
+	if mc.onRate != nil {
+		var ratings []*fyne.MenuItem
+		for rating := 0; rating <= models.MaxRating; rating++ {
+			item := fyne.NewMenuItem(ratingLabel(rating), func() { mc.onRate(rating) })
+			item.Checked = rating == mc.rating
+			ratings = append(ratings, item)
+		}
+		item := fyne.NewMenuItem("Rating", nil)
+		item.ChildMenu = fyne.NewMenu("", ratings...)
+		items = append(items, item)
+	}
+	if mc.onFavorite != nil {
+		item := fyne.NewMenuItem("Favorite", func() { mc.onFavorite(!mc.favorite) })
+		item.Checked = mc.favorite
+		items = append(items, item)
+	}
+	if mc.onFlag != nil {
+		var flags []*fyne.MenuItem
+		for _, flag := range []struct {
+			name string
+			flag models.Flag
+		}{{"Pick", models.FlagPick}, {"Reject", models.FlagReject}, {"Unflagged", models.FlagNone}} {
+			item := fyne.NewMenuItem(flag.name, func() { mc.onFlag(flag.flag) })
+			item.Checked = flag.flag == mc.flag
+			flags = append(flags, item)
+		}
+		item := fyne.NewMenuItem("Flag", nil)
+		item.ChildMenu = fyne.NewMenu("", flags...)
+		items = append(items, item)
+	}
+	if mc.onColorLabel != nil {
+		none := fyne.NewMenuItem("None", func() { mc.onColorLabel(models.LabelNone) })
+		none.Checked = mc.colorLabel == models.LabelNone
+		labels := []*fyne.MenuItem{none}
+		for _, label := range models.ColorLabels {
+			name := strings.ToUpper(string(label[:1])) + string(label[1:])
+			item := fyne.NewMenuItem(name, func() { mc.onColorLabel(label) })
+			item.Checked = label == mc.colorLabel
+			labels = append(labels, item)
+		}
+		item := fyne.NewMenuItem("Color Label", nil)
+		item.ChildMenu = fyne.NewMenu("", labels...)
+		items = append(items, item)
+	}
+	return items
+}
+
+// SetTags shows the given tags as colored chips on top of the preview.
+func (mc *MediaCard) SetTags(tags []models.Tag) {
+	mc.tags = tags
+	mc.tagChips = nil
+	if len(tags) > 0 {
+		mc.tagChips = newCardChips(tags)
+	}
+	mc.Refresh()
+}
+
+// SetTypeMismatch flags a file whose content, detected as the format named
+// detected, contradicts its extension. When fix is not nil the context menu
+// offers to rename the file to ext. An empty detected clears the flag.
+func (mc *MediaCard) SetTypeMismatch(detected, ext string, fix func()) {
+	mc.typeWarning = nil
+	mc.onFixExtension = nil
+	if detected != "" {
+		mc.typeWarning = newChip(detected+" file", color.NRGBA{0xe6, 0x51, 0x00, 0xff})
+		mc.onFixExtension = fix
+		mc.fixExtensionTo = ext
+	}
+	mc.Refresh()
+}
+
 func (mc *MediaCard) openFile() error {
 	var cmd *exec.Cmd
 	switch runtime.GOOS {
@@ -292,8 +556,23 @@ func (mc *MediaCard) openFile() error {
 	return nil
 }
 
+// SetThumbnailSize sets the width of the preview area in pixels.
+func (mc *MediaCard) SetThumbnailSize(size int) {
+	if size <= 0 {
+		size = preview.DefaultThumbnailSize
+	}
+	mc.thumbnailSize = size
+	mc.Refresh()
+}
+
 func (mc *MediaCard) MinSize() fyne.Size {
-	return fyne.NewSize(180, 101)
+	return fyne.NewSize(float32(mc.thumbnailSize), float32(preview.VideoPreviewHeight(mc.thumbnailSize)))
+}
+
+// maxContentSize is the largest area a preview is fitted into.
+func (mc *MediaCard) maxContentSize() (float32, float32) {
+	size := float32(mc.thumbnailSize)
+	return size, size * 2 / 3
 }
 
 func (mc *MediaCard) CreateRenderer() fyne.WidgetRenderer {
@@ -317,7 +596,7 @@ type mediaCardRenderer struct {
 func (r *mediaCardRenderer) Layout(size fyne.Size) {
 	padding := float32(4)
 	w, h := r.card.previewWidth, r.card.previewHeight
-	maxW, maxH := float32(180), float32(120)
+	maxW, maxH := r.card.maxContentSize()
 	contentW, contentH := maxW, maxH
 	if w > 0 && h > 0 {
 		aspect := float32(w) / float32(h)
@@ -359,11 +638,39 @@ func (r *mediaCardRenderer) Layout(size fyne.Size) {
 
 	r.label.Resize(fyne.NewSize(labelWidth, labelHeight))
 	r.label.Move(fyne.NewPos(labelX, labelY))
+
+	r.card.labelBar.Resize(fyne.NewSize(labelWidth, padding-1))
+	r.card.labelBar.Move(fyne.NewPos(labelX, labelY+labelHeight))
+	r.card.rejectShade.Resize(fyne.NewSize(contentW, contentH))
+	r.card.rejectShade.Move(fyne.NewPos(padding, padding))
+
+	chipsWidth := contentW - 4
+	if badges := r.card.badgeChip; badges != nil {
+		badgesSize := badges.MinSize()
+		badges.Resize(badgesSize)
+		badges.Move(fyne.NewPos(padding+contentW-badgesSize.Width-2, padding+2))
+		chipsWidth -= badgesSize.Width + 2
+	}
+	if chips := r.card.tagChips; chips != nil {
+		chipsSize := chips.MinSize()
+		chips.Resize(fyne.NewSize(max(0, min(chipsSize.Width, chipsWidth)), chipsSize.Height))
+		chips.Move(fyne.NewPos(padding+2, padding+2))
+	}
+	if warning := r.card.typeWarning; warning != nil {
+		warningSize := warning.MinSize()
+		warning.Resize(warningSize)
+		warning.Move(fyne.NewPos(padding+contentW-warningSize.Width-2, padding+contentH-warningSize.Height-2))
+	}
+	if stars := r.card.ratingChip; stars != nil {
+		starsSize := stars.MinSize()
+		stars.Resize(starsSize)
+		stars.Move(fyne.NewPos(padding+2, padding+contentH-starsSize.Height-2))
+	}
 }
 
 func (r *mediaCardRenderer) MinSize() fyne.Size {
 	w, h := r.card.previewWidth, r.card.previewHeight
-	maxW, maxH := float32(180), float32(120)
+	maxW, maxH := r.card.maxContentSize()
 	contentW, contentH := maxW, maxH
 	if w > 0 && h > 0 {
 		aspect := float32(w) / float32(h)
@@ -397,12 +704,32 @@ func (r *mediaCardRenderer) Refresh() {
 	}
 	canvas.Refresh(r.labelBackground)
 	canvas.Refresh(r.label)
+	canvas.Refresh(r.card.labelBar)
 
 	r.Layout(r.background.Size())
 }
 
 func (r *mediaCardRenderer) Objects() []fyne.CanvasObject {
-	return []fyne.CanvasObject{r.background, r.content, r.labelBackground, r.label}
+	objects := []fyne.CanvasObject{r.background, r.content, r.labelBackground, r.label}
+	if r.card.flag == models.FlagReject {
+		objects = append(objects, r.card.rejectShade)
+	}
+	if r.card.colorLabel != models.LabelNone {
+		objects = append(objects, r.card.labelBar)
+	}
+	if r.card.tagChips != nil {
+		objects = append(objects, r.card.tagChips)
+	}
+	if r.card.typeWarning != nil {
+		objects = append(objects, r.card.typeWarning)
+	}
+	if r.card.ratingChip != nil {
+		objects = append(objects, r.card.ratingChip)
+	}
+	if r.card.badgeChip != nil {
+		objects = append(objects, r.card.badgeChip)
+	}
+	return objects
 }
 
 func (r *mediaCardRenderer) Destroy() {
@@ -411,12 +738,25 @@ func (r *mediaCardRenderer) Destroy() {
 	}
 }
 
+// MediaTypeOf returns the card type of a library file, going by the type
+// recorded during the scan.
+func MediaTypeOf(file models.MediaFile) MediaType {
+	switch models.MediaKind(file.FileType) {
+	case models.KindImage:
+		return MediaTypeImage
+	case models.KindVideo:
+		return MediaTypeVideo
+	default:
+		return GetMediaType(file.Filename)
+	}
+}
+
+// GetMediaType returns the card type of a file going by its extension.
 func GetMediaType(filename string) MediaType {
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp":
+	switch models.MediaTypes.Kind(filename) {
+	case models.KindImage:
 		return MediaTypeImage
-	case ".mp4", ".webm", ".ogv", ".flv", ".mov", ".avi", ".mkv", ".ts", ".3gp":
+	case models.KindVideo:
 		return MediaTypeVideo
 	default:
 		return MediaTypeFile
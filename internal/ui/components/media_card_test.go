@@ -1,10 +1,14 @@
 package components
 
 import (
+	"slices"
 	"testing"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/pkg/models"
 )
 
 func TestMediaCardLayout(t *testing.T) {
@@ -60,3 +64,131 @@ func TestMediaCardUniformSizing(t *testing.T) {
 		}
 	}
 }
+
+func TestMediaCardTagChips(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	card := NewMediaCard("/fake/path/test.jpg", "test.jpg", MediaTypeImage, "")
+	card.SetTags([]models.Tag{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}})
+
+	renderer := card.CreateRenderer()
+	renderer.Layout(fyne.NewSize(180, 180))
+	objects := renderer.Objects()
+	if len(objects) != 5 {
+		t.Fatalf("Expected 5 objects with tag chips, got %d", len(objects))
+	}
+	chips := objects[4].(*fyne.Container)
+	if len(chips.Objects) != maxCardChips+1 {
+		t.Errorf("Expected %d chips and an overflow chip, got %d", maxCardChips, len(chips.Objects))
+	}
+}
+
+func TestTagColorRoundTrip(t *testing.T) {
+	if got := HexColor(TagColor("#3366ff")); got != "#3366ff" {
+		t.Errorf("Expected #3366ff, got %s", got)
+	}
+	if got := HexColor(TagColor("")); got != DefaultTagColor {
+		t.Errorf("Expected an empty color to fall back to %s, got %s", DefaultTagColor, got)
+	}
+}
+
+func TestMediaCardBind(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	card := NewMediaCard("/fake/path/notes.txt", "notes.txt", MediaTypeFile, "")
+	stale := previewLoad{id: card.loadID}
+
+	card.Bind("/fake/path/other.txt", "a-very-long-file-name-for-a-card.txt", MediaTypeFile, "")
+	if card.label.Text != "a-very-long-file-na..." {
+		t.Errorf("Expected the label to follow the new file, got %q", card.label.Text)
+	}
+	placeholder := card.content
+
+	// A preview of the previous file finishing late must not replace the content
+	card.showPreview(stale, widget.NewLabel("stale"), 10, 10, nil)
+	if card.content != placeholder || card.previewWidth != 0 {
+		t.Errorf("Expected a stale preview to be dropped")
+	}
+
+	// Rebinding to the same file keeps what is already shown
+	loadID := card.loadID
+	card.Bind("/fake/path/other.txt", "a-very-long-file-name-for-a-card.txt", MediaTypeFile, "")
+	if card.loadID != loadID {
+		t.Errorf("Expected rebinding the same file not to reload its preview")
+	}
+}
+
+func TestMediaCardThumbnailSize(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	card := NewMediaCard("/fake/path/test.jpg", "test.jpg", MediaTypeImage, "")
+	card.SetThumbnailSize(300)
+	if expected := fyne.NewSize(300, 168); card.MinSize() != expected {
+		t.Errorf("Expected card MinSize to follow the thumbnail size, %v, got %v", expected, card.MinSize())
+	}
+	if w, h := card.maxContentSize(); w != 300 || h != 200 {
+		t.Errorf("Expected previews to fit 300x200, got %vx%v", w, h)
+	}
+
+	card.SetThumbnailSize(0)
+	if expected := fyne.NewSize(180, 101); card.MinSize() != expected {
+		t.Errorf("Expected an invalid size to fall back to the default, %v, got %v", expected, card.MinSize())
+	}
+}
+
+func TestMediaCardMarks(t *testing.T) {
+	testApp := test.NewApp()
+	defer testApp.Quit()
+
+	if stars := ratingIcons(3); len(stars) != 3 {
+		t.Errorf("Expected 3 stars, got %d", len(stars))
+	}
+	if stars := ratingIcons(models.MaxRating + 1); len(stars) != models.MaxRating {
+		t.Errorf("Expected at most %d stars, got %d", models.MaxRating, len(stars))
+	}
+	if badges := badgeIcons(true, models.FlagReject); !slices.Equal(badges, []fyne.Resource{heartIcon, rejectIcon}) {
+		t.Errorf("Expected favorite and reject badges, got %v", badges)
+	}
+
+	card := NewMediaCard("/fake/path/test.jpg", "test.jpg", MediaTypeImage, "")
+	card.SetMarks(3, true, models.FlagReject, models.LabelBlue)
+	renderer := card.CreateRenderer()
+	renderer.Layout(fyne.NewSize(180, 180))
+	objects := renderer.Objects()
+	for name, object := range map[string]fyne.CanvasObject{
+		"stars": card.ratingChip, "badges": card.badgeChip, "reject shade": card.rejectShade, "label bar": card.labelBar,
+	} {
+		if !slices.Contains(objects, object) {
+			t.Errorf("Expected the %s to be drawn", name)
+		}
+	}
+	if card.labelBar.FillColor != LabelColor(models.LabelBlue) {
+		t.Errorf("Expected a blue label bar, got %v", card.labelBar.FillColor)
+	}
+
+	var rated int
+	card.SetOnRate(func(rating int) { rated = rating })
+	card.SetOnFavorite(func(bool) {})
+	items := card.markMenuItems()
+	if len(items) != 2 || items[0].ChildMenu == nil || !items[1].Checked {
+		t.Fatalf("Expected a rating submenu and a checked favorite item, got %d items", len(items))
+	}
+	ratings := items[0].ChildMenu.Items
+	if len(ratings) != models.MaxRating+1 || !ratings[3].Checked || ratings[4].Checked {
+		t.Errorf("Expected the current rating to be checked")
+	}
+	ratings[5].Action()
+	if rated != 5 {
+		t.Errorf("Expected the menu to rate 5 stars, got %d", rated)
+	}
+
+	card.SetMarks(0, false, models.FlagNone, models.LabelNone)
+	objects = card.CreateRenderer().Objects()
+	if card.ratingChip != nil || card.badgeChip != nil ||
+		slices.Contains(objects, fyne.CanvasObject(card.rejectShade)) || slices.Contains(objects, fyne.CanvasObject(card.labelBar)) {
+		t.Errorf("Expected the marks to be cleared")
+	}
+}
@@ -0,0 +1,62 @@
+package components
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+
+	"github.com/user/media-manager/pkg/models"
+)
+
+// DefaultTagColor is used for tags that have no color of their own.
+const DefaultTagColor = "#607d8b"
+
+// maxCardChips is how many tag chips fit on a card before the rest are
+// summarised as "+N".
+const maxCardChips = 3
+
+// TagColor parses a "#rrggbb" tag color, falling back to DefaultTagColor.
+func TagColor(hex string) color.Color {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		fmt.Sscanf(DefaultTagColor, "#%02x%02x%02x", &r, &g, &b)
+	}
+	return color.NRGBA{r, g, b, 0xff}
+}
+
+// HexColor formats c as "#rrggbb" for storing it as a tag color.
+func HexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
+
+// NewTagChip returns a small rounded label in the tag's color.
+func NewTagChip(tag models.Tag) fyne.CanvasObject {
+	return newChip(tag.Name, TagColor(tag.Color))
+}
+
+func newChip(text string, fill color.Color) fyne.CanvasObject {
+	background := canvas.NewRectangle(fill)
+	background.CornerRadius = 4
+	label := canvas.NewText(text, color.White)
+	label.TextSize = theme.CaptionTextSize()
+	return container.NewStack(background, container.New(layout.NewCustomPaddedLayout(1, 1, 4, 4), label))
+}
+
+// newCardChips lays out the chips shown on top of a media card.
+func newCardChips(tags []models.Tag) *fyne.Container {
+	chips := container.NewHBox()
+	for i, tag := range tags {
+		if i == maxCardChips {
+			chips.Add(newChip(fmt.Sprintf("+%d", len(tags)-maxCardChips), color.NRGBA{0, 0, 0, 160}))
+			break
+		}
+		chips.Add(NewTagChip(tag))
+	}
+	return chips
+}
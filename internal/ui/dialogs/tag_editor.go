@@ -0,0 +1,139 @@
+package dialogs
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// TagEditorDialog lets the user pick the tags of a single media file and
+// create new tags on the fly.
+type TagEditorDialog struct {
+	customDialog dialog.Dialog
+	database     *db.Database
+	file         models.MediaFile
+	tagIDs       map[string]uint // tag path -> ID
+	initial      []string        // tag paths the file had when the dialog opened
+	checks       *widget.CheckGroup
+	newTagEntry  *widget.Entry
+	onChanged    func()
+	window       fyne.Window
+}
+
+// NewTagEditorDialog creates a tag editor for file. onChanged is called after
+// the file's tags were saved.
+func NewTagEditorDialog(database *db.Database, file models.MediaFile, onChanged func(), window fyne.Window) (*TagEditorDialog, error) {
+	editor := &TagEditorDialog{
+		database:  database,
+		file:      file,
+		tagIDs:    make(map[string]uint),
+		onChanged: onChanged,
+		window:    window,
+	}
+
+	paths, err := editor.loadTagPaths()
+	if err != nil {
+		return nil, err
+	}
+	fileTags, err := database.GetTagsForFiles([]uint{file.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags of %s: %w", file.Filename, err)
+	}
+	for _, tag := range fileTags[file.ID] {
+		editor.initial = append(editor.initial, paths[tag.ID])
+	}
+
+	editor.checks = widget.NewCheckGroup(slices.Sorted(maps.Keys(editor.tagIDs)), nil)
+	editor.checks.SetSelected(slices.Clone(editor.initial))
+
+	editor.newTagEntry = widget.NewEntry()
+	editor.newTagEntry.SetPlaceHolder("New tag, e.g. Places/Paris...")
+	editor.newTagEntry.OnSubmitted = func(string) { editor.addNewTag() }
+	addButton := widget.NewButton("Add", editor.addNewTag)
+
+	checkScroll := container.NewVScroll(editor.checks)
+	checkScroll.SetMinSize(fyne.NewSize(300, 250))
+	content := container.NewBorder(
+		container.NewBorder(nil, nil, nil, addButton, editor.newTagEntry),
+		nil, nil, nil,
+		checkScroll,
+	)
+
+	editor.customDialog = dialog.NewCustomConfirm("Tags of "+file.Filename, "Save", "Cancel", content, func(save bool) {
+		if save {
+			editor.save()
+		}
+	}, window)
+	return editor, nil
+}
+
+// Show displays the tag editor.
+func (e *TagEditorDialog) Show() {
+	e.customDialog.Show()
+}
+
+// loadTagPaths reads every tag into tagIDs and returns the tag paths by ID.
+func (e *TagEditorDialog) loadTagPaths() (map[uint]string, error) {
+	allTags, err := e.database.GetTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	paths := db.TagPaths(allTags)
+	for id, path := range paths {
+		e.tagIDs[path] = id
+	}
+	return paths, nil
+}
+
+// addNewTag creates the tag typed into the entry, along with any missing
+// parent tags, and checks it.
+func (e *TagEditorDialog) addNewTag() {
+	tag, err := e.database.GetOrCreateTag(e.newTagEntry.Text)
+	if err != nil {
+		dialog.ShowError(err, e.window)
+		return
+	}
+	e.newTagEntry.SetText("")
+	paths, err := e.loadTagPaths()
+	if err != nil {
+		dialog.ShowError(err, e.window)
+		return
+	}
+	e.checks.Options = slices.Sorted(maps.Keys(e.tagIDs))
+	e.checks.Refresh()
+	if path := paths[tag.ID]; !slices.Contains(e.checks.Selected, path) {
+		e.checks.SetSelected(append(e.checks.Selected, path))
+	}
+}
+
+// save applies the difference between the initial and the checked tags.
+func (e *TagEditorDialog) save() {
+	fileIDs := []uint{e.file.ID}
+	for _, name := range e.checks.Selected {
+		if !slices.Contains(e.initial, name) {
+			if err := e.database.AddTagToFiles(e.tagIDs[name], fileIDs); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to add tag %q: %w", name, err), e.window)
+				return
+			}
+		}
+	}
+	for _, name := range e.initial {
+		if !slices.Contains(e.checks.Selected, name) {
+			if err := e.database.RemoveTagFromFiles(e.tagIDs[name], fileIDs); err != nil {
+				dialog.ShowError(fmt.Errorf("failed to remove tag %q: %w", name, err), e.window)
+				return
+			}
+		}
+	}
+	if e.onChanged != nil {
+		e.onChanged()
+	}
+}
@@ -0,0 +1,233 @@
+package dialogs
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/internal/ui/components"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// TagManagerDialog lists every tag with its file count and lets the user
+// rename, recolor, move, merge and delete tags.
+type TagManagerDialog struct {
+	customDialog dialog.Dialog
+	database     *db.Database
+	tags         []db.TagWithCount // sorted by path
+	paths        map[uint]string   // tag ID -> path
+	selected     int
+	tagList      *widget.List
+	onChanged    func()
+	window       fyne.Window
+}
+
+// NewTagManagerDialog creates the tag manager. onChanged is called after
+// every change to the tags.
+func NewTagManagerDialog(database *db.Database, onChanged func(), window fyne.Window) *TagManagerDialog {
+	manager := &TagManagerDialog{
+		database:  database,
+		selected:  -1,
+		onChanged: onChanged,
+		window:    window,
+	}
+
+	manager.tagList = widget.NewList(
+		func() int {
+			return len(manager.tags)
+		},
+		func() fyne.CanvasObject {
+			return container.NewHBox(container.NewStack(), widget.NewLabel("Tag"))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			tag := manager.tags[id].Tag
+			tag.Name = manager.paths[tag.ID]
+			row.Objects[0].(*fyne.Container).Objects = []fyne.CanvasObject{components.NewTagChip(tag)}
+			row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%d files", manager.tags[id].FileCount))
+			row.Refresh()
+		},
+	)
+	manager.tagList.OnSelected = func(id widget.ListItemID) {
+		manager.selected = id
+	}
+	manager.tagList.OnUnselected = func(widget.ListItemID) {
+		manager.selected = -1
+	}
+
+	buttons := container.NewGridWithColumns(5,
+		widget.NewButton("Rename...", manager.renameSelected),
+		widget.NewButton("Color...", manager.recolorSelected),
+		widget.NewButton("Move...", manager.moveSelected),
+		widget.NewButton("Merge Into...", manager.mergeSelected),
+		widget.NewButton("Delete", manager.deleteSelected),
+	)
+	content := container.NewBorder(nil, buttons, nil, nil, manager.tagList)
+
+	manager.customDialog = dialog.NewCustom("Manage Tags", "Close", content, window)
+	manager.customDialog.Resize(fyne.NewSize(560, 420))
+	manager.reload()
+	return manager
+}
+
+// Show displays the tag manager.
+func (m *TagManagerDialog) Show() {
+	m.customDialog.Show()
+}
+
+func (m *TagManagerDialog) reload() {
+	tags, err := m.database.GetTagsWithCounts()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to load tags: %v\n", err)
+		return
+	}
+	m.paths = db.TagPaths(tagsOf(tags))
+	slices.SortFunc(tags, func(a, b db.TagWithCount) int {
+		return strings.Compare(strings.ToLower(m.paths[a.ID]), strings.ToLower(m.paths[b.ID]))
+	})
+	m.tags = tags
+	m.selected = -1
+	m.tagList.UnselectAll()
+	m.tagList.Refresh()
+}
+
+func tagsOf(counts []db.TagWithCount) []models.Tag {
+	tags := make([]models.Tag, len(counts))
+	for i, count := range counts {
+		tags[i] = count.Tag
+	}
+	return tags
+}
+
+// changed reloads the list and notifies the owner, or reports err.
+func (m *TagManagerDialog) changed(err error) {
+	if err != nil {
+		dialog.ShowError(err, m.window)
+		return
+	}
+	m.reload()
+	if m.onChanged != nil {
+		m.onChanged()
+	}
+}
+
+// selectedTag returns the selected tag, telling the user to pick one if none is.
+func (m *TagManagerDialog) selectedTag() (db.TagWithCount, bool) {
+	if m.selected < 0 || m.selected >= len(m.tags) {
+		dialog.ShowInformation("Manage Tags", "Select a tag first.", m.window)
+		return db.TagWithCount{}, false
+	}
+	return m.tags[m.selected], true
+}
+
+func (m *TagManagerDialog) renameSelected() {
+	tag, ok := m.selectedTag()
+	if !ok {
+		return
+	}
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(tag.Name)
+	items := []*widget.FormItem{widget.NewFormItem("Name", nameEntry)}
+	dialog.ShowForm("Rename Tag", "Rename", "Cancel", items, func(confirmed bool) {
+		if confirmed {
+			m.changed(m.database.RenameTag(tag.ID, nameEntry.Text))
+		}
+	}, m.window)
+}
+
+func (m *TagManagerDialog) recolorSelected() {
+	tag, ok := m.selectedTag()
+	if !ok {
+		return
+	}
+	picker := dialog.NewColorPicker("Tag Color", "Color of "+m.paths[tag.ID], func(c color.Color) {
+		m.changed(m.database.SetTagColor(tag.ID, components.HexColor(c)))
+	}, m.window)
+	picker.Advanced = true
+	picker.SetColor(components.TagColor(tag.Color))
+	picker.Show()
+}
+
+// moveSelected puts the selected tag beneath another tag or at the top level.
+func (m *TagManagerDialog) moveSelected() {
+	tag, ok := m.selectedTag()
+	if !ok {
+		return
+	}
+	beneath, err := m.database.GetTagDescendantIDs(tag.ID)
+	if err != nil {
+		dialog.ShowError(err, m.window)
+		return
+	}
+	const topLevel = "(top level)"
+	parents := []string{topLevel}
+	parentIDs := make(map[string]uint)
+	for _, other := range m.tags {
+		if !slices.Contains(beneath, other.ID) {
+			parents = append(parents, m.paths[other.ID])
+			parentIDs[m.paths[other.ID]] = other.ID
+		}
+	}
+	parentSelect := widget.NewSelect(parents, nil)
+	parentSelect.SetSelected(topLevel)
+	if tag.ParentID != nil {
+		parentSelect.SetSelected(m.paths[*tag.ParentID])
+	}
+	items := []*widget.FormItem{widget.NewFormItem("Move beneath", parentSelect)}
+	dialog.ShowForm("Move "+m.paths[tag.ID], "Move", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		var parentID *uint
+		if id, ok := parentIDs[parentSelect.Selected]; ok {
+			parentID = &id
+		}
+		m.changed(m.database.SetTagParent(tag.ID, parentID))
+	}, m.window)
+}
+
+func (m *TagManagerDialog) mergeSelected() {
+	tag, ok := m.selectedTag()
+	if !ok {
+		return
+	}
+	var targets []string
+	targetIDs := make(map[string]uint)
+	for _, other := range m.tags {
+		if other.ID != tag.ID {
+			targets = append(targets, m.paths[other.ID])
+			targetIDs[m.paths[other.ID]] = other.ID
+		}
+	}
+	if len(targets) == 0 {
+		dialog.ShowInformation("Merge Tags", "There is no other tag to merge into.", m.window)
+		return
+	}
+	targetSelect := widget.NewSelect(targets, nil)
+	items := []*widget.FormItem{widget.NewFormItem("Merge into", targetSelect)}
+	dialog.ShowForm("Merge "+m.paths[tag.ID], "Merge", "Cancel", items, func(confirmed bool) {
+		if confirmed && targetSelect.Selected != "" {
+			m.changed(m.database.MergeTags([]uint{tag.ID}, targetIDs[targetSelect.Selected]))
+		}
+	}, m.window)
+}
+
+func (m *TagManagerDialog) deleteSelected() {
+	tag, ok := m.selectedTag()
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("Delete the tag %q? It is removed from its files; tags beneath it move up one level.", m.paths[tag.ID])
+	dialog.ShowConfirm("Delete Tag", message, func(confirmed bool) {
+		if confirmed {
+			m.changed(m.database.DeleteTag(tag.ID))
+		}
+	}, m.window)
+}
@@ -0,0 +1,252 @@
+package views
+
+import (
+	"fmt"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// createAlbumsPanel builds the sidebar list of albums. Selecting one shows
+// its files in album order, where cards can be dragged to reorder them.
+func (v *MainView) createAlbumsPanel() fyne.CanvasObject {
+	var albums []db.AlbumWithCount
+	loadAlbums := func() {
+		var err error
+		albums, err = v.database.GetAlbumsWithCounts()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to load albums: %v\n", err)
+		}
+	}
+	loadAlbums()
+
+	list := widget.NewList(
+		func() int {
+			return len(albums)
+		},
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewLabel("0"), widget.NewLabel("Album"))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(albums[id].Name)
+			row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%d", albums[id].FileCount))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		album := albums[id].Album
+		fmt.Printf("[DEBUG] Selected album: %s\n", album.Name)
+		v.clearTagFilter()
+		v.clearSmartAlbum()
+		if v.foldersTree != nil {
+			v.foldersTree.UnselectAll()
+		}
+		v.album = &album
+		v.RefreshMediaGrid()
+	}
+	v.albumList = list
+	v.refreshAlbums = func() {
+		loadAlbums()
+		if v.album != nil {
+			// Show the renamed album, or nothing once it was deleted
+			id := v.album.ID
+			v.album = nil
+			for _, album := range albums {
+				if album.ID == id {
+					v.album = &album.Album
+				}
+			}
+		}
+		list.Refresh()
+	}
+
+	newBtn := widget.NewButton("New...", func() {
+		v.editAlbum(nil)
+	})
+	editBtn := widget.NewButton("Edit...", func() {
+		if v.album == nil {
+			dialog.ShowInformation("Albums", "Select an album to edit.", v.window)
+			return
+		}
+		v.editAlbum(v.album)
+	})
+	header := container.NewBorder(nil, nil, widget.NewLabel("Albums"), container.NewHBox(newBtn, editBtn))
+	return container.NewBorder(header, nil, nil, nil, list)
+}
+
+// clearAlbum leaves the album view without refreshing the grid.
+func (v *MainView) clearAlbum() {
+	if v.album == nil {
+		return
+	}
+	v.album = nil
+	if v.albumList != nil {
+		v.albumList.UnselectAll()
+	}
+}
+
+// editAlbum asks for the name of a new album, or renames album if it is not
+// nil, which can also be deleted.
+func (v *MainView) editAlbum(album *models.Album) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Name")
+	title := "New Album"
+	items := []*widget.FormItem{widget.NewFormItem("Name", nameEntry)}
+	var form *dialog.FormDialog
+	if album != nil {
+		title = "Edit Album"
+		nameEntry.SetText(album.Name)
+		deleteBtn := widget.NewButton("Delete Album", nil)
+		items = append(items, widget.NewFormItem("", deleteBtn))
+		deleteBtn.OnTapped = func() {
+			dialog.ShowConfirm("Delete Album", fmt.Sprintf("Delete %q? Its files are not affected.", album.Name), func(ok bool) {
+				if !ok {
+					return
+				}
+				form.Hide()
+				if err := v.database.DeleteAlbum(album.ID); err != nil {
+					dialog.ShowError(err, v.window)
+					return
+				}
+				v.clearAlbum()
+				v.refreshAlbums()
+				v.RefreshMediaGrid()
+			}, v.window)
+		}
+	}
+
+	form = dialog.NewForm(title, "Save", "Cancel", items, func(save bool) {
+		if !save {
+			return
+		}
+		var err error
+		if album == nil {
+			_, err = v.database.CreateAlbum(nameEntry.Text)
+		} else {
+			err = v.database.RenameAlbum(album.ID, nameEntry.Text)
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to save album: %v\n", err)
+			dialog.ShowError(err, v.window)
+			return
+		}
+		v.refreshAlbums()
+	}, v.window)
+	form.Resize(fyne.NewSize(360, 0))
+	form.Show()
+}
+
+// addToAlbum asks for an album, existing or new, and appends file to it.
+func (v *MainView) addToAlbum(file models.MediaFile) {
+	albums, err := v.database.GetAlbumsWithCounts()
+	if err != nil {
+		dialog.ShowError(err, v.window)
+		return
+	}
+	names := make([]string, len(albums))
+	byName := make(map[string]uint, len(albums))
+	for i, album := range albums {
+		names[i] = album.Name
+		byName[album.Name] = album.ID
+	}
+	nameEntry := widget.NewSelectEntry(names)
+	nameEntry.SetPlaceHolder("Album name")
+	if v.lastAlbum != "" {
+		nameEntry.SetText(v.lastAlbum)
+	}
+
+	form := dialog.NewForm("Add "+file.Filename+" to Album", "Add", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("Album", nameEntry)},
+		func(add bool) {
+			if !add {
+				return
+			}
+			albumID, ok := byName[nameEntry.Text]
+			if !ok {
+				album, err := v.database.CreateAlbum(nameEntry.Text)
+				if err != nil {
+					dialog.ShowError(err, v.window)
+					return
+				}
+				albumID = album.ID
+			}
+			if err := v.database.AddToAlbum(albumID, []uint{file.ID}); err != nil {
+				fmt.Printf("[ERROR] Failed to add %s to album: %v\n", file.Path, err)
+				dialog.ShowError(err, v.window)
+				return
+			}
+			v.lastAlbum = nameEntry.Text
+			v.refreshAlbums()
+			if v.album != nil && v.album.ID == albumID {
+				v.RefreshMediaGrid()
+			}
+		}, v.window)
+	form.Resize(fyne.NewSize(360, 0))
+	form.Show()
+}
+
+// removeFromAlbum takes file out of the album shown in the grid.
+func (v *MainView) removeFromAlbum(file models.MediaFile) {
+	if v.album == nil {
+		return
+	}
+	if err := v.database.RemoveFromAlbum(v.album.ID, []uint{file.ID}); err != nil {
+		fmt.Printf("[ERROR] Failed to remove %s from album: %v\n", file.Path, err)
+		dialog.ShowError(err, v.window)
+		return
+	}
+	v.refreshAlbums()
+	v.RefreshMediaGrid()
+}
+
+// dropAlbumItem moves file right before the card under pos, an absolute
+// position where its card was dropped, or after the last card if it was
+// dropped past it.
+func (v *MainView) dropAlbumItem(file models.MediaFile, pos fyne.Position) {
+	if v.album == nil || v.mediaGrid == nil {
+		return
+	}
+	origin := fyne.CurrentApp().Driver().AbsolutePositionForObject(v.mediaGrid)
+	index := gridIndexAt(pos.Subtract(origin), v.gridCellSize, theme.Padding(),
+		v.mediaGrid.ColumnCount(), v.mediaGrid.GetScrollOffset(), len(v.files))
+	if index < 0 {
+		return
+	}
+	// The grid may hide files of the album, so the card dropped on, rather
+	// than its place in the grid, says where the file goes
+	after := index == len(v.files)
+	if after {
+		index--
+	}
+	target := v.files[index].ID
+	if target == file.ID {
+		return
+	}
+	if err := v.database.MoveAlbumItem(v.album.ID, file.ID, target, after); err != nil {
+		fmt.Printf("[ERROR] Failed to reorder album: %v\n", err)
+		return
+	}
+	v.RefreshMediaGrid()
+}
+
+// gridIndexAt returns the index of the grid item at pos, relative to the
+// grid's top left corner, for count items in columns columns of cells
+// scrolled down by offset. Positions past the last item give count; -1
+// means the grid is empty.
+func gridIndexAt(pos fyne.Position, cell fyne.Size, padding float32, columns int, offset float32, count int) int {
+	if count == 0 {
+		return -1
+	}
+	col := int(math.Floor(float64(pos.X / (cell.Width + padding))))
+	row := int(math.Floor(float64((pos.Y + offset) / (cell.Height + padding))))
+	col = max(0, min(col, columns-1))
+	row = max(0, row)
+	return min(row*columns+col, count)
+}
@@ -0,0 +1,114 @@
+package views
+
+import (
+	"fmt"
+	"os"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// showDuplicatesView replaces the library with the list of duplicate groups.
+func (v *MainView) showDuplicatesView() {
+	v.window.SetContent(v.buildDuplicatesView())
+}
+
+// buildDuplicatesView lists every group of byte-identical files. For each
+// group the user picks the copy to keep and the other copies are deleted
+// from disk and from the library.
+func (v *MainView) buildDuplicatesView() fyne.CanvasObject {
+	backBtn := widget.NewButton("Back to Library", func() {
+		v.window.SetContent(v.Build())
+	})
+
+	groups, err := v.database.GetDuplicateGroups()
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to load duplicate groups: %v\n", err)
+		return container.NewBorder(backBtn, nil, nil, nil, widget.NewLabel("Failed to load duplicates: "+err.Error()))
+	}
+
+	var wasted int64
+	for _, group := range groups {
+		wasted += group.WastedBytes()
+	}
+	summary := widget.NewLabel(fmt.Sprintf("%d groups of identical files, %s reclaimable", len(groups), formatSize(wasted)))
+	header := container.NewBorder(nil, nil, backBtn, nil, summary)
+
+	if len(groups) == 0 {
+		return container.NewBorder(header, nil, nil, nil, widget.NewLabel("No duplicates found."))
+	}
+
+	groupList := container.NewVBox()
+	for _, group := range groups {
+		groupList.Add(v.createDuplicateGroupCard(group))
+	}
+	return container.NewBorder(header, nil, nil, nil, container.NewVScroll(groupList))
+}
+
+func (v *MainView) createDuplicateGroupCard(group db.DuplicateGroup) fyne.CanvasObject {
+	paths := make([]string, len(group.Files))
+	for i, file := range group.Files {
+		paths[i] = file.Path
+	}
+	choice := widget.NewRadioGroup(paths, nil)
+	choice.SetSelected(paths[0])
+	choice.Required = true
+
+	keepBtn := widget.NewButton("Keep Selected, Delete Others", func() {
+		keep := choice.Selected
+		message := fmt.Sprintf("Keep %s and permanently delete %d other copies?", keep, len(group.Files)-1)
+		dialog.ShowConfirm("Delete Duplicates", message, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			for _, file := range group.Files {
+				if file.Path == keep {
+					continue
+				}
+				if err := v.deleteMediaFile(file); err != nil {
+					dialog.ShowError(err, v.window)
+					break
+				}
+			}
+			v.showDuplicatesView()
+		}, v.window)
+	})
+
+	title := fmt.Sprintf("%s (%d copies)", group.Files[0].Filename, len(group.Files))
+	subtitle := fmt.Sprintf("%s each, %s reclaimable", formatSize(group.Size), formatSize(group.WastedBytes()))
+	return widget.NewCard(title, subtitle, container.NewVBox(choice, container.NewHBox(keepBtn)))
+}
+
+// deleteMediaFile removes a file from disk together with its library record and preview.
+func (v *MainView) deleteMediaFile(file models.MediaFile) error {
+	if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", file.Path, err)
+	}
+	if err := v.database.DeleteMediaFile(&file); err != nil {
+		return fmt.Errorf("failed to remove %s from the library: %w", file.Path, err)
+	}
+	if file.PreviewPath != "" {
+		os.Remove(file.PreviewPath)
+	}
+	fmt.Printf("[INFO] Deleted duplicate: %s\n", file.Path)
+	return nil
+}
+
+// formatSize renders a byte count for display, e.g. "1.5 MB".
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
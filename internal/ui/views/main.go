@@ -4,28 +4,63 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/widget"
 
 	"github.com/user/media-manager/internal/config"
 	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/internal/preview"
+	"github.com/user/media-manager/internal/scanner"
 	"github.com/user/media-manager/internal/ui/components"
 	"github.com/user/media-manager/pkg/models"
 )
 
 type MainView struct {
 	widget.BaseWidget
-	config             *config.Config
-	database           *db.Database
-	mediaGridContainer *fyne.Container
-	window             fyne.Window
-	mediaDir           string
-	foldersTree        *widget.Tree
-	filter             string
+	config       *config.Config
+	database     *db.Database
+	mediaGrid    *widget.GridWrap
+	contentSplit *container.Split   // sidebar and mediaGrid
+	files        []models.MediaFile // files shown in mediaGrid
+	filesByPath  map[string]models.MediaFile
+	fileTags     map[uint][]models.Tag
+	window       fyne.Window
+	mediaDir     string
+	foldersTree  *widget.Tree
+	search       db.Search     // parsed filter box query
+	searchError  *widget.Label // syntax error of the filter box query
+	tagFilter    *models.Tag   // when set, the grid shows the files beneath this tag instead of mediaDir
+	tagTree      *widget.Tree
+	refreshTags  func() // reloads the sidebar tag list
+	// smartAlbum, when set, makes the grid show the files matching a saved
+	// search instead of mediaDir
+	smartAlbum         *models.SmartAlbum
+	smartAlbumList     *widget.List
+	refreshSmartAlbums func() // reloads the sidebar smart albums and their counts
+	// album, when set, makes the grid show the files of an album in album
+	// order, which is changed by dragging cards
+	album          *models.Album
+	albumList      *widget.List
+	refreshAlbums  func()    // reloads the sidebar albums and their counts
+	lastAlbum      string    // album files were last added to, offered first
+	gridCellSize   fyne.Size // size of a mediaGrid cell
+	hoveredFileID  uint      // file of the card under the mouse, marked by typedMarkKey
+	recursive      bool      // include files in subfolders of mediaDir
+	sortBy         db.MediaSort
+	sortDescending bool
+	previews       *preview.Queue
+	previewCache   *preview.Cache
+	previewStatus  *widget.Label
+	previewBar     *widget.ProgressBar
+	// toolchainWarningDismissed hides the missing ffmpeg banner for the rest
+	// of the session
+	toolchainWarningDismissed bool
 }
 
 func (v *MainView) getChildDirs(path string) []string {
@@ -107,6 +142,9 @@ func (v *MainView) createFoldersTree() *widget.Tree {
 	tree.OnSelected = func(id string) {
 		fmt.Printf("[DEBUG] Selected folder: %s\n", id)
 		v.mediaDir = id
+		v.clearTagFilter()
+		v.clearSmartAlbum()
+		v.clearAlbum()
 		v.RefreshMediaGrid()
 		tree.OpenBranch(id)
 	}
@@ -114,73 +152,236 @@ func (v *MainView) createFoldersTree() *widget.Tree {
 	return tree
 }
 
+// filterMediaFiles applies the filter box query. A query with a syntax
+// error is reported below the filter box and leaves the grid as it is.
 func (v *MainView) filterMediaFiles(input string) {
-	v.filter = input
+	search, err := db.ParseSearch(input)
+	if err != nil {
+		v.searchError.SetText(err.Error())
+		v.searchError.Show()
+		return
+	}
+	v.searchError.Hide()
+	v.search = search
+	v.RefreshMediaGrid()
+}
+
+// rememberSearch adds a submitted query to the recent searches offered by
+// the filter box.
+func (v *MainView) rememberSearch(input string) {
+	if _, err := db.ParseSearch(input); err != nil {
+		return
+	}
+	v.config.AddRecentSearch(input)
+	if err := config.SaveConfig(v.config); err != nil {
+		fmt.Printf("[ERROR] Failed to save recent searches: %v\n", err)
+	}
+}
+
+// LibraryChanged refreshes everything showing library contents after the
+// scanner added, changed or removed files: the grid and the counts of tags
+// and smart albums.
+func (v *MainView) LibraryChanged() {
+	if v.refreshSmartAlbums != nil {
+		v.refreshSmartAlbums()
+	}
+	if v.refreshAlbums != nil {
+		v.refreshAlbums()
+	}
+	if v.refreshTags != nil {
+		v.refreshTags()
+	}
 	v.RefreshMediaGrid()
 }
 
 func (v *MainView) RefreshMediaGrid() {
 	fmt.Println("[DEBUG] views/main.go: RefreshMediaGrid called.")
-	if v.mediaGridContainer != nil {
-		v.mediaGridContainer.Objects = []fyne.CanvasObject{}
-
-		if v.mediaDir != "" {
-			mediaDir := v.mediaDir
-			files, err := os.ReadDir(mediaDir)
-			if err == nil {
-				for _, file := range files {
-					if !file.IsDir() {
-						if v.filter != "" && !strings.Contains(strings.ToLower(file.Name()), strings.ToLower(v.filter)) {
-							continue
-						}
-						filePath := filepath.Join(mediaDir, file.Name())
-						mediaType := components.GetMediaType(file.Name())
-						var thumbPath string
-						// Only use thumbPath for images if needed
-						card := components.NewMediaCard(filePath, file.Name(), mediaType, thumbPath)
-						card.SetOnDelete(func() {
-							v.mediaGridContainer.Remove(card)
-							v.mediaGridContainer.Refresh()
-						})
-						v.mediaGridContainer.Add(card)
-					}
-				}
+	v.loadMediaFiles()
+	if v.mediaGrid != nil {
+		v.mediaGrid.Refresh()
+	}
+	fmt.Println("Media grid refreshed")
+}
+
+// createMediaGrid builds a virtualized grid: cards exist only for the rows
+// in view and are rebound to other files while scrolling, so previews are
+// only loaded for files the user can see. Cells follow the thumbnail size.
+func (v *MainView) createMediaGrid() *widget.GridWrap {
+	thumbSize := v.previewCache.ThumbnailSize()
+	cardWidth := float32(thumbSize)
+	cardHeight := cardWidth * 8 / 9 // grid height, but cards will clamp to content
+	v.gridCellSize = fyne.NewSize(cardWidth, cardHeight)
+	v.loadMediaFiles()
+	v.mediaGrid = widget.NewGridWrap(
+		func() int {
+			return len(v.files)
+		},
+		func() fyne.CanvasObject {
+			card := components.NewMediaCard("", "", components.MediaTypeFile, "")
+			card.SetPreviewSource(v.requestCardPreview)
+			card.SetThumbnailSize(thumbSize)
+			// A one-cell grid layout gives the recycled card its cell size
+			return container.New(layout.NewGridWrapLayout(fyne.NewSize(cardWidth, cardHeight)), card)
+		},
+		func(id widget.GridWrapItemID, obj fyne.CanvasObject) {
+			if id >= len(v.files) {
+				return
 			}
+			v.bindMediaCard(obj.(*fyne.Container).Objects[0].(*components.MediaCard), v.files[id])
+		},
+	)
+	return v.mediaGrid
+}
+
+// loadMediaFiles queries the library files of the selected folder, or of
+// the selected tag while a tag filter is active, along with their tags.
+func (v *MainView) loadMediaFiles() {
+	v.files = nil
+	v.filesByPath = nil
+	v.fileTags = nil
+	query := db.MediaQuery{Search: v.search, Sort: v.sortBy, Descending: v.sortDescending}
+	if v.album != nil {
+		query.AlbumID = v.album.ID
+		query.Sort, query.Descending = db.SortByAlbumOrder, false
+	} else if v.smartAlbum != nil {
+		album, err := db.ParseSearch(v.smartAlbum.Query)
+		if err != nil {
+			fmt.Printf("[ERROR] Smart album %q has an invalid query: %v\n", v.smartAlbum.Name, err)
+			return
 		}
+		query.Search = album.And(v.search)
+	} else if v.tagFilter != nil {
+		query.TagID = v.tagFilter.ID
+	} else if v.mediaDir != "" {
+		query.Dir = v.mediaDir
+		query.Recursive = v.recursive
+	} else {
+		return
+	}
+	files, err := v.database.QueryMediaFiles(query)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to load media files: %v\n", err)
+		return
+	}
+	v.files = files
+	v.filesByPath = make(map[string]models.MediaFile, len(files))
 
-		v.mediaGridContainer.Refresh()
+	ids := make([]uint, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+		v.filesByPath[file.Path] = file
+	}
+	v.fileTags, err = v.database.GetTagsForFiles(ids)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to load tags: %v\n", err)
+	}
+}
+
+// bindMediaCard points a recycled card at file. The card shows the preview
+// rendition of the current thumbnail size, which is generated if missing.
+func (v *MainView) bindMediaCard(card *components.MediaCard, file models.MediaFile) {
+	previewPath := v.previewCache.PreviewPath(file.Path, file.DetectedType, file.Size, file.ModTime)
+	card.Bind(file.Path, file.Filename, components.MediaTypeOf(file), previewPath)
+	card.SetOnDelete(func() {
+		v.files = slices.DeleteFunc(v.files, func(f models.MediaFile) bool { return f.ID == file.ID })
+		v.mediaGrid.Refresh()
+	})
+	card.SetOnFindSimilar(func() { v.showSimilar(file.Path) })
+	card.SetOnEditTags(func() { v.editTags(file) })
+	card.SetOnEditNotes(func() { v.editNotes(file) })
+	card.SetOnAddToAlbum(func() { v.addToAlbum(file) })
+	if v.album != nil {
+		card.SetOnRemoveFromAlbum(func() { v.removeFromAlbum(file) })
+		card.SetOnDrop(func(pos fyne.Position) { v.dropAlbumItem(file, pos) })
+	} else {
+		card.SetOnRemoveFromAlbum(nil)
+		card.SetOnDrop(nil)
+	}
+	card.SetTags(v.fileTags[file.ID])
+	v.bindMarks(card, file)
+	if mediaType, ok := models.MediaTypes.ByMIMEType(file.DetectedType); file.TypeMismatch && ok && len(mediaType.Extensions) > 0 {
+		card.SetTypeMismatch(formatName(mediaType), mediaType.Extensions[0], func() { v.fixExtension(file) })
+	} else {
+		card.SetTypeMismatch("", "", nil)
 	}
-	fmt.Println("Media grid refreshed")
 }
 
-func (v *MainView) createMediaGrid() *fyne.Container {
-	const cardWidth = 180
-	const cardHeight = 160 // grid height, but cards will clamp to content
-	var cards []fyne.CanvasObject
-	if v.mediaDir != "" {
-		mediaDir := v.mediaDir
-		files, err := os.ReadDir(mediaDir)
-		if err == nil {
-			for _, file := range files {
-				if !file.IsDir() {
-					if v.filter != "" && !strings.Contains(strings.ToLower(file.Name()), strings.ToLower(v.filter)) {
-						continue
-					}
-					filePath := filepath.Join(mediaDir, file.Name())
-					mediaType := components.GetMediaType(file.Name())
-					var thumbPath string
-					card := components.NewMediaCard(filePath, file.Name(), mediaType, thumbPath)
-					card.SetOnDelete(func() {
-						// Remove from grid: not needed, grid will be rebuilt on refresh
-						v.RefreshMediaGrid()
-					})
-					cards = append(cards, card)
-				}
+// formatName returns a short name of a media type for badges, e.g. "JPEG"
+// for image/jpeg or "MATROSKA" for video/x-matroska.
+func formatName(mediaType models.MediaType) string {
+	_, subtype, _ := strings.Cut(mediaType.MIMEType, "/")
+	return strings.ToUpper(strings.TrimPrefix(subtype, "x-"))
+}
+
+// editNotes lets the user describe a file; notes are found by the filter
+// box like file names.
+func (v *MainView) editNotes(file models.MediaFile) {
+	entry := widget.NewMultiLineEntry()
+	entry.SetText(file.Notes)
+	entry.SetMinRowsVisible(4)
+	form := dialog.NewForm("Notes for "+file.Filename, "Save", "Cancel",
+		[]*widget.FormItem{widget.NewFormItem("", entry)},
+		func(save bool) {
+			if !save {
+				return
+			}
+			if err := v.database.SetNotes(file.ID, entry.Text); err != nil {
+				fmt.Printf("[ERROR] Failed to save notes: %v\n", err)
+				dialog.ShowError(err, v.window)
+				return
 			}
+			v.RefreshMediaGrid()
+		}, v.window)
+	form.Resize(fyne.NewSize(400, 0))
+	form.Show()
+}
+
+// fixExtension renames a file whose content contradicts its extension to
+// the extension of its detected type.
+func (v *MainView) fixExtension(file models.MediaFile) {
+	renamed, err := scanner.FixExtension(v.database, file)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to fix extension: %v\n", err)
+		dialog.ShowError(err, v.window)
+		return
+	}
+	fmt.Printf("[INFO] Renamed %s to %s\n", file.Path, renamed.Path)
+	v.RefreshMediaGrid()
+}
+
+// mediaSortOptions are the entries of the sort selector in the toolbar.
+var mediaSortOptions = []string{"Name", "Newest first", "Oldest first", "Largest first", "Smallest first", "Highest resolution", "Longest first", "Highest rated", "Best match"}
+
+func parseMediaSortOption(option string) (db.MediaSort, bool) {
+	switch option {
+	case "Newest first":
+		return db.SortByModTime, true
+	case "Oldest first":
+		return db.SortByModTime, false
+	case "Largest first":
+		return db.SortBySize, true
+	case "Smallest first":
+		return db.SortBySize, false
+	case "Highest resolution":
+		return db.SortByResolution, true
+	case "Longest first":
+		return db.SortByDuration, true
+	case "Highest rated":
+		return db.SortByRating, true
+	case "Best match":
+		return db.SortByRelevance, false
+	default:
+		return db.SortByName, false
+	}
+}
+
+func mediaSortOption(sortBy db.MediaSort, descending bool) string {
+	for _, option := range mediaSortOptions {
+		if s, d := parseMediaSortOption(option); s == sortBy && d == descending {
+			return option
 		}
 	}
-	v.mediaGridContainer = container.NewGridWrap(fyne.NewSize(cardWidth, cardHeight), cards...)
-	return v.mediaGridContainer
+	return mediaSortOptions[0]
 }
 
 func (v *MainView) Build() fyne.CanvasObject {
@@ -194,18 +395,32 @@ func (v *MainView) Build() fyne.CanvasObject {
 		treeScroll = container.NewVBox(widget.NewLabel("No folders found"))
 	}
 
+	albums := container.NewVSplit(v.createAlbumsPanel(), v.createSmartAlbumsPanel())
+	panels := container.NewVSplit(albums, v.createTagsPanel())
+	sidebar := container.NewVSplit(treeScroll, panels)
+	sidebar.SetOffset(0.5)
+
 	mediaGrid := v.createMediaGrid()
-	split := container.NewHSplit(treeScroll, mediaGrid)
+	split := container.NewHSplit(sidebar, mediaGrid)
+	v.contentSplit = split
 	// Set offset from config
 	split.SetOffset(float64(v.config.MainContentSplitOffset))
 	// Note: Fyne v2 does not support OnChanged for Split. Offset persistence not supported here.
 
 	// Toolbar: filter entry, refresh button, add folder button
-	filterEntry := widget.NewEntry()
-	filterEntry.SetPlaceHolder("Filter media...")
+	filterEntry := widget.NewSelectEntry(v.config.RecentSearches)
+	filterEntry.SetPlaceHolder("Search, e.g. beach tag:trip type:video size>100MB taken:2023-06..2023-08")
+	filterEntry.SetText(v.search.Input)
 	filterEntry.OnChanged = func(input string) {
 		v.filterMediaFiles(input)
 	}
+	filterEntry.OnSubmitted = func(input string) {
+		v.rememberSearch(input)
+		filterEntry.SetOptions(v.config.RecentSearches)
+	}
+	v.searchError = widget.NewLabel("")
+	v.searchError.Importance = widget.DangerImportance
+	v.searchError.Hide()
 	refreshBtn := widget.NewButton("Refresh", func() {
 		v.RefreshMediaGrid()
 	})
@@ -230,8 +445,21 @@ func (v *MainView) Build() fyne.CanvasObject {
 		}, v.window)
 		dialog.Show()
 	})
-	buttonBox := container.NewHBox(refreshBtn, addFolderBtn)
-	toolbar := container.NewBorder(nil, nil, nil, buttonBox, filterEntry)
+	duplicatesBtn := widget.NewButton("Duplicates", func() {
+		v.showDuplicatesView()
+	})
+	subfoldersCheck := widget.NewCheck("Subfolders", func(checked bool) {
+		v.recursive = checked
+		v.RefreshMediaGrid()
+	})
+	subfoldersCheck.SetChecked(v.recursive)
+	sortSelect := widget.NewSelect(mediaSortOptions, func(option string) {
+		v.sortBy, v.sortDescending = parseMediaSortOption(option)
+		v.RefreshMediaGrid()
+	})
+	sortSelect.SetSelected(mediaSortOption(v.sortBy, v.sortDescending))
+	buttonBox := container.NewHBox(subfoldersCheck, sortSelect, refreshBtn, addFolderBtn, duplicatesBtn)
+	toolbar := container.NewBorder(nil, v.searchError, nil, buttonBox, filterEntry)
 
 	// Pre-select the root media directory
 	if v.mediaDir != "" && v.foldersTree != nil {
@@ -240,15 +468,35 @@ func (v *MainView) Build() fyne.CanvasObject {
 		fmt.Println("[WARN] foldersTree is nil, cannot select root directory")
 	}
 
-	return container.NewVBox(toolbar, split)
+	v.window.Canvas().SetOnTypedRune(v.typedMarkKey)
+
+	top := fyne.CanvasObject(toolbar)
+	if banner := v.createToolchainBanner(); banner != nil {
+		top = container.NewVBox(banner, toolbar)
+	}
+	return container.NewBorder(top, v.createStatusBar(), nil, nil, split)
+}
+
+// SetThumbnailSize switches the grid to another thumbnail size, in pixels.
+// Previews missing at the new size are generated as their cards come into
+// view.
+func (v *MainView) SetThumbnailSize(size int) {
+	v.config.ThumbnailSize = size
+	if v.contentSplit == nil {
+		return
+	}
+	// The grid measures its cells once, so it is replaced rather than refreshed
+	v.contentSplit.Trailing = v.createMediaGrid()
+	v.contentSplit.Refresh()
 }
 
 func NewMainView(cfg *config.Config, db *db.Database, window fyne.Window, mediaDir string) *MainView {
 	mv := &MainView{
-		config:   cfg,
-		database: db,
-		window:   window,
-		mediaDir: mediaDir,
+		config:       cfg,
+		database:     db,
+		window:       window,
+		mediaDir:     mediaDir,
+		previewCache: preview.NewCache(cfg),
 	}
 
 	// Load all folders from DB on startup
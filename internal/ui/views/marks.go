@@ -0,0 +1,106 @@
+package views
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+
+	"github.com/user/media-manager/internal/ui/components"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// markLabelKeys are the keys that toggle color labels.
+var markLabelKeys = map[rune]models.ColorLabel{
+	'6': models.LabelRed,
+	'7': models.LabelYellow,
+	'8': models.LabelGreen,
+	'9': models.LabelBlue,
+}
+
+// bindMarks shows the marks of file on card and lets its context menu
+// change them.
+func (v *MainView) bindMarks(card *components.MediaCard, file models.MediaFile) {
+	card.SetMarks(file.Rating, file.Favorite, file.Flag, file.ColorLabel)
+	card.SetOnRate(func(rating int) { v.setRating(file, rating) })
+	card.SetOnFavorite(func(favorite bool) { v.setFavorite(file, favorite) })
+	card.SetOnFlag(func(flag models.Flag) { v.setFlag(file, flag) })
+	card.SetOnColorLabel(func(label models.ColorLabel) { v.setColorLabel(file, label) })
+	card.SetOnHover(func(hovered bool) {
+		if hovered {
+			v.hoveredFileID = file.ID
+		} else if v.hoveredFileID == file.ID {
+			v.hoveredFileID = 0
+		}
+	})
+}
+
+// typedMarkKey marks the file under the mouse, with the keys of common
+// photo organisers: 0 to 5 rate it, P picks, X rejects and U unflags it, F
+// toggles the favorite flag and 6 to 9 toggle the red, yellow, green and
+// blue labels. Keys typed into a text field do not get here.
+func (v *MainView) typedMarkKey(key rune) {
+	if v.hoveredFileID == 0 {
+		return
+	}
+	var file models.MediaFile
+	for _, f := range v.files {
+		if f.ID == v.hoveredFileID {
+			file = f
+		}
+	}
+	if file.ID == 0 {
+		return
+	}
+	switch {
+	case key >= '0' && key <= '0'+models.MaxRating:
+		v.setRating(file, int(key-'0'))
+	case key == 'p' || key == 'P':
+		v.setFlag(file, models.FlagPick)
+	case key == 'x' || key == 'X':
+		v.setFlag(file, models.FlagReject)
+	case key == 'u' || key == 'U':
+		v.setFlag(file, models.FlagNone)
+	case key == 'f' || key == 'F':
+		v.setFavorite(file, !file.Favorite)
+	default:
+		label, ok := markLabelKeys[key]
+		if !ok {
+			return
+		}
+		if file.ColorLabel == label {
+			label = models.LabelNone
+		}
+		v.setColorLabel(file, label)
+	}
+}
+
+func (v *MainView) setRating(file models.MediaFile, rating int) {
+	v.saveMarks(file, v.database.SetRating([]uint{file.ID}, rating))
+}
+
+func (v *MainView) setFavorite(file models.MediaFile, favorite bool) {
+	v.saveMarks(file, v.database.SetFavorite([]uint{file.ID}, favorite))
+}
+
+func (v *MainView) setFlag(file models.MediaFile, flag models.Flag) {
+	v.saveMarks(file, v.database.SetFlag([]uint{file.ID}, flag))
+}
+
+func (v *MainView) setColorLabel(file models.MediaFile, label models.ColorLabel) {
+	v.saveMarks(file, v.database.SetColorLabel([]uint{file.ID}, label))
+}
+
+// saveMarks reports the outcome of changing the marks of file. The grid is
+// reloaded, as the file may no longer match the search or may move in a
+// sort by rating, and so are the smart album counts.
+func (v *MainView) saveMarks(file models.MediaFile, err error) {
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to mark %s: %v\n", file.Path, err)
+		dialog.ShowError(err, v.window)
+		return
+	}
+	if v.refreshSmartAlbums != nil {
+		v.refreshSmartAlbums()
+	}
+	v.RefreshMediaGrid()
+}
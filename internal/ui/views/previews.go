@@ -0,0 +1,69 @@
+package views
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+
+	"github.com/user/media-manager/internal/preview"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// SetPreviewQueue makes the grid generate previews through queue and shows
+// the queue's progress in the status bar. onIdle, if not nil, is started in a
+// goroutine of its own whenever the queue runs dry. Call it before Build.
+func (v *MainView) SetPreviewQueue(queue *preview.Queue, onIdle func()) {
+	v.previews = queue
+	queue.SetOnProgress(func(progress preview.Progress) {
+		fyne.Do(func() { v.showPreviewProgress(progress) })
+		if onIdle != nil && progress.Idle() {
+			go onIdle()
+		}
+	})
+}
+
+// RequestPreview queues generation of the preview of file at the current
+// thumbnail size into the preview cache and records it in the database.
+// Requests for the same version of a file share one job, so a card scrolling
+// into view raises the priority of a queued background job. done, if not
+// nil, gets the preview's path. Files whose preview backend cannot run, such
+// as videos without ffmpeg, are not queued at all.
+func (v *MainView) RequestPreview(file models.MediaFile, priority preview.Priority, done func(previewPath string, err error)) *preview.Ticket {
+	if v.previews == nil {
+		return nil
+	}
+	if !preview.CanGenerate(file.Path, file.DetectedType) {
+		// The card keeps its placeholder; for videos the toolchain banner
+		// says why
+		return nil
+	}
+	thumbSize := v.previewCache.ThumbnailSize()
+	previewPath := v.previewCache.PreviewPath(file.Path, file.DetectedType, file.Size, file.ModTime)
+	return v.previews.Submit(previewPath, priority, func(ctx context.Context) error {
+		hash, err := preview.GeneratePreview(ctx, file.Path, file.DetectedType, previewPath, thumbSize)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to generate preview for %s: %v\n", file.Path, err)
+			return err
+		}
+		if err := v.database.SetPreview(file.ID, previewPath, hash); err != nil {
+			fmt.Printf("[ERROR] Failed to save preview of %s: %v\n", file.Path, err)
+			return err
+		}
+		fmt.Printf("[DEBUG] Generated preview for %s -> %s\n", file.Path, previewPath)
+		return nil
+	}, func(err error) {
+		if done != nil {
+			done(previewPath, err)
+		}
+	})
+}
+
+// requestCardPreview is the preview source of the grid's cards.
+func (v *MainView) requestCardPreview(filePath string, done func(previewPath string, err error)) *preview.Ticket {
+	file, ok := v.filesByPath[filePath]
+	if !ok {
+		return nil
+	}
+	return v.RequestPreview(file, preview.PriorityVisible, done)
+}
@@ -0,0 +1,56 @@
+package views
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/ui/components"
+)
+
+// similarMaxDistance is the largest Hamming distance between two perceptual
+// hashes that still counts as "similar". Resized or recompressed copies are
+// usually within a few bits; unrelated pictures are around 32 bits apart.
+const similarMaxDistance = 10
+
+// showSimilar shows the files that look like the file at filePath.
+func (v *MainView) showSimilar(filePath string) {
+	title := "Similar to " + filepath.Base(filePath)
+
+	record, err := v.database.GetMediaFileByPath(filePath)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to look up %s: %w", filePath, err), v.window)
+		return
+	}
+	if record == nil || record.PerceptualHash == nil {
+		dialog.ShowInformation(title, "This file has not been analysed yet.\nIts perceptual hash is computed when its preview is generated.", v.window)
+		return
+	}
+
+	similar, err := v.database.FindSimilarMediaFiles(record.ID, similarMaxDistance)
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("failed to find similar files: %w", err), v.window)
+		return
+	}
+	if len(similar) == 0 {
+		dialog.ShowInformation(title, "No similar files found.", v.window)
+		return
+	}
+
+	var cards []fyne.CanvasObject
+	for _, match := range similar {
+		card := components.NewMediaCard(match.File.Path, match.File.Filename, components.MediaTypeOf(match.File), match.File.PreviewPath)
+		distance := widget.NewLabel(fmt.Sprintf("%d bits apart", match.Distance))
+		distance.Alignment = fyne.TextAlignCenter
+		cards = append(cards, container.NewBorder(nil, distance, nil, nil, card))
+	}
+	grid := container.NewGridWrap(fyne.NewSize(180, 200), cards...)
+
+	similarDialog := dialog.NewCustom(fmt.Sprintf("%s (%d)", title, len(similar)), "Close", container.NewVScroll(grid), v.window)
+	similarDialog.Resize(fyne.NewSize(800, 600))
+	similarDialog.Show()
+}
@@ -0,0 +1,158 @@
+package views
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// createSmartAlbumsPanel builds the sidebar list of saved searches with the
+// number of files matching each. Selecting one shows its files, which the
+// filter box narrows down further.
+func (v *MainView) createSmartAlbumsPanel() fyne.CanvasObject {
+	var albums []db.SmartAlbumWithCount
+	loadAlbums := func() {
+		var err error
+		albums, err = v.database.GetSmartAlbumsWithCounts()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to load smart albums: %v\n", err)
+		}
+	}
+	loadAlbums()
+
+	list := widget.NewList(
+		func() int {
+			return len(albums)
+		},
+		func() fyne.CanvasObject {
+			return container.NewBorder(nil, nil, nil, widget.NewLabel("0"), widget.NewLabel("Album"))
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(albums[id].Name)
+			row.Objects[1].(*widget.Label).SetText(fmt.Sprintf("%d", albums[id].FileCount))
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		album := albums[id].SmartAlbum
+		fmt.Printf("[DEBUG] Selected smart album: %s\n", album.Name)
+		v.clearTagFilter()
+		v.clearAlbum()
+		if v.foldersTree != nil {
+			v.foldersTree.UnselectAll()
+		}
+		v.smartAlbum = &album
+		v.RefreshMediaGrid()
+	}
+	v.smartAlbumList = list
+	v.refreshSmartAlbums = func() {
+		loadAlbums()
+		if v.smartAlbum != nil {
+			// Show the edited album, or nothing once it was deleted
+			id := v.smartAlbum.ID
+			v.smartAlbum = nil
+			for _, album := range albums {
+				if album.ID == id {
+					v.smartAlbum = &album.SmartAlbum
+				}
+			}
+		}
+		list.Refresh()
+	}
+
+	saveBtn := widget.NewButton("Save Search...", func() {
+		v.editSmartAlbum(nil, v.search.Input)
+	})
+	editBtn := widget.NewButton("Edit...", func() {
+		if v.smartAlbum == nil {
+			dialog.ShowInformation("Smart Albums", "Select a smart album to edit.", v.window)
+			return
+		}
+		v.editSmartAlbum(v.smartAlbum, v.smartAlbum.Query)
+	})
+	header := container.NewBorder(nil, nil, widget.NewLabel("Smart Albums"), container.NewHBox(saveBtn, editBtn))
+	return container.NewBorder(header, nil, nil, nil, list)
+}
+
+// clearSmartAlbum leaves the smart album view without refreshing the grid.
+func (v *MainView) clearSmartAlbum() {
+	if v.smartAlbum == nil {
+		return
+	}
+	v.smartAlbum = nil
+	if v.smartAlbumList != nil {
+		v.smartAlbumList.UnselectAll()
+	}
+}
+
+// editSmartAlbum asks for the name and query of a new smart album, or of
+// album if it is not nil, which can also be deleted.
+func (v *MainView) editSmartAlbum(album *models.SmartAlbum, query string) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("Name")
+	queryEntry := widget.NewEntry()
+	queryEntry.SetText(query)
+	queryEntry.SetPlaceHolder("tag:beach type:video taken:2023")
+	queryEntry.Validator = func(text string) error {
+		_, err := db.ParseSearch(text)
+		return err
+	}
+	title := "Save Search"
+	if album != nil {
+		title = "Edit Smart Album"
+		nameEntry.SetText(album.Name)
+	}
+	items := []*widget.FormItem{
+		widget.NewFormItem("Name", nameEntry),
+		widget.NewFormItem("Query", queryEntry),
+	}
+	var form *dialog.FormDialog
+	if album != nil {
+		deleteBtn := widget.NewButton("Delete Smart Album", nil)
+		items = append(items, widget.NewFormItem("", deleteBtn))
+		deleteBtn.OnTapped = func() {
+			dialog.ShowConfirm("Delete Smart Album", fmt.Sprintf("Delete %q? Its files are not affected.", album.Name), func(ok bool) {
+				if !ok {
+					return
+				}
+				form.Hide()
+				if err := v.database.DeleteSmartAlbum(album.ID); err != nil {
+					dialog.ShowError(err, v.window)
+					return
+				}
+				v.clearSmartAlbum()
+				v.refreshSmartAlbums()
+				v.RefreshMediaGrid()
+			}, v.window)
+		}
+	}
+
+	form = dialog.NewForm(title, "Save", "Cancel", items, func(save bool) {
+		if !save {
+			return
+		}
+		name, query := strings.TrimSpace(nameEntry.Text), queryEntry.Text
+		var err error
+		if album == nil {
+			_, err = v.database.CreateSmartAlbum(name, query)
+		} else {
+			err = v.database.UpdateSmartAlbum(album.ID, name, query)
+		}
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to save smart album: %v\n", err)
+			dialog.ShowError(err, v.window)
+			return
+		}
+		v.refreshSmartAlbums()
+		v.RefreshMediaGrid()
+	}, v.window)
+	form.Resize(fyne.NewSize(420, 0))
+	form.Show()
+}
@@ -0,0 +1,63 @@
+package views
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/preview"
+)
+
+// createStatusBar builds the bar below the grid that reports background
+// preview generation.
+func (v *MainView) createStatusBar() fyne.CanvasObject {
+	v.previewStatus = widget.NewLabel("")
+	v.previewBar = widget.NewProgressBar()
+	v.previewBar.Hide()
+	if v.previews != nil {
+		v.showPreviewProgress(v.previews.Progress())
+	}
+	return container.NewBorder(nil, nil, nil, container.NewGridWrap(fyne.NewSize(200, v.previewBar.MinSize().Height), v.previewBar), v.previewStatus)
+}
+
+func (v *MainView) showPreviewProgress(progress preview.Progress) {
+	if v.previewStatus == nil {
+		return
+	}
+	if progress.Idle() {
+		v.previewStatus.SetText("")
+		v.previewBar.Hide()
+		return
+	}
+	total := progress.Done + progress.Running + progress.Queued
+	status := fmt.Sprintf("Generating previews: %d of %d", progress.Done, total)
+	if progress.Failed > 0 {
+		status += fmt.Sprintf(" (%d failed)", progress.Failed)
+	}
+	v.previewStatus.SetText(status)
+	v.previewBar.Max = float64(total)
+	v.previewBar.SetValue(float64(progress.Done))
+	v.previewBar.Show()
+}
+
+// createToolchainBanner warns that videos get no previews because ffmpeg is
+// missing or lacks features. It returns nil if there is nothing to warn
+// about or the user already dismissed the warning this session.
+func (v *MainView) createToolchainBanner() fyne.CanvasObject {
+	warning := preview.Tools().Warning()
+	if warning == "" || v.toolchainWarningDismissed {
+		return nil
+	}
+	label := widget.NewLabel(warning)
+	label.Wrapping = fyne.TextWrapWord
+	var banner *fyne.Container
+	dismiss := widget.NewButtonWithIcon("", theme.CancelIcon(), func() {
+		v.toolchainWarningDismissed = true
+		banner.Hide()
+	})
+	banner = container.NewBorder(nil, nil, widget.NewIcon(theme.WarningIcon()), dismiss, label)
+	return banner
+}
@@ -0,0 +1,120 @@
+package views
+
+import (
+	"fmt"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/user/media-manager/internal/db"
+	"github.com/user/media-manager/internal/ui/components"
+	"github.com/user/media-manager/internal/ui/dialogs"
+	"github.com/user/media-manager/pkg/models"
+)
+
+// createTagsPanel builds the sidebar tree of tags. Selecting a tag shows
+// every file carrying it or a tag beneath it; selecting a folder goes back
+// to browsing folders.
+func (v *MainView) createTagsPanel() fyne.CanvasObject {
+	tags := make(map[string]db.TagWithCount)
+	children := make(map[string][]string) // parent node ID ("" for the root) -> child node IDs
+	loadTags := func() {
+		clear(tags)
+		clear(children)
+		all, err := v.database.GetTagsWithCounts()
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to load tags: %v\n", err)
+		}
+		// all is sorted by name, so every child list is too
+		for _, tag := range all {
+			nodeID := strconv.FormatUint(uint64(tag.ID), 10)
+			parentID := ""
+			if tag.ParentID != nil {
+				parentID = strconv.FormatUint(uint64(*tag.ParentID), 10)
+			}
+			tags[nodeID] = tag
+			children[parentID] = append(children[parentID], nodeID)
+		}
+	}
+	loadTags()
+
+	v.tagTree = widget.NewTree(
+		func(id widget.TreeNodeID) []widget.TreeNodeID {
+			return children[id]
+		},
+		func(id widget.TreeNodeID) bool {
+			return id == "" || len(children[id]) > 0
+		},
+		func(branch bool) fyne.CanvasObject {
+			return container.NewBorder(nil, nil, container.NewStack(), widget.NewLabel("0"))
+		},
+		func(id widget.TreeNodeID, branch bool, obj fyne.CanvasObject) {
+			row := obj.(*fyne.Container)
+			tag := tags[id]
+			for _, child := range row.Objects {
+				switch child := child.(type) {
+				case *fyne.Container:
+					child.Objects = []fyne.CanvasObject{components.NewTagChip(tag.Tag)}
+				case *widget.Label:
+					child.SetText(fmt.Sprintf("%d", tag.FileCount))
+				}
+			}
+			row.Refresh()
+		},
+	)
+	v.tagTree.OnSelected = func(id widget.TreeNodeID) {
+		tag := tags[id].Tag
+		fmt.Printf("[DEBUG] Selected tag: %s\n", tag.Name)
+		v.tagFilter = &tag
+		v.clearSmartAlbum()
+		v.clearAlbum()
+		if v.foldersTree != nil {
+			v.foldersTree.UnselectAll()
+		}
+		v.RefreshMediaGrid()
+	}
+	v.refreshTags = func() {
+		loadTags()
+		v.tagTree.Refresh()
+	}
+
+	manageBtn := widget.NewButton("Manage...", func() {
+		dialogs.NewTagManagerDialog(v.database, func() {
+			v.clearTagFilter()
+			v.refreshTags()
+			v.RefreshMediaGrid()
+		}, v.window).Show()
+	})
+	header := container.NewBorder(nil, nil, widget.NewLabel("Tags"), manageBtn)
+	return container.NewBorder(header, nil, nil, nil, v.tagTree)
+}
+
+// clearTagFilter leaves the tag view without refreshing the grid.
+func (v *MainView) clearTagFilter() {
+	if v.tagFilter == nil {
+		return
+	}
+	v.tagFilter = nil
+	if v.tagTree != nil {
+		v.tagTree.UnselectAll()
+	}
+}
+
+// editTags opens the tag editor for a file and refreshes the tag list and
+// the grid once its tags changed.
+func (v *MainView) editTags(file models.MediaFile) {
+	editor, err := dialogs.NewTagEditorDialog(v.database, file, func() {
+		if v.refreshTags != nil {
+			v.refreshTags()
+		}
+		v.RefreshMediaGrid()
+	}, v.window)
+	if err != nil {
+		dialog.ShowError(err, v.window)
+		return
+	}
+	editor.Show()
+}
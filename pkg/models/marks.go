@@ -0,0 +1,67 @@
+package models
+
+import "strings"
+
+// The marks are a user's judgement of a media file: a star rating, a
+// favorite flag, a pick or reject flag for culling and a color label.
+
+// MaxRating is the highest star rating; 0 means unrated.
+const MaxRating = 5
+
+// Flag marks a file as picked or rejected while culling.
+type Flag int
+
+const (
+	FlagNone   Flag = 0
+	FlagPick   Flag = 1
+	FlagReject Flag = -1
+)
+
+func (f Flag) String() string {
+	switch f {
+	case FlagPick:
+		return "pick"
+	case FlagReject:
+		return "reject"
+	default:
+		return "none"
+	}
+}
+
+// ParseFlag parses "pick", "reject" or "none".
+func ParseFlag(name string) (Flag, bool) {
+	for _, flag := range []Flag{FlagNone, FlagPick, FlagReject} {
+		if strings.EqualFold(name, flag.String()) {
+			return flag, true
+		}
+	}
+	return FlagNone, false
+}
+
+// ColorLabel is a color the user files a media file under; "" is none.
+type ColorLabel string
+
+const (
+	LabelNone   ColorLabel = ""
+	LabelRed    ColorLabel = "red"
+	LabelYellow ColorLabel = "yellow"
+	LabelGreen  ColorLabel = "green"
+	LabelBlue   ColorLabel = "blue"
+	LabelPurple ColorLabel = "purple"
+)
+
+// ColorLabels are the labels in the order they are offered.
+var ColorLabels = []ColorLabel{LabelRed, LabelYellow, LabelGreen, LabelBlue, LabelPurple}
+
+// ParseColorLabel parses the name of a label, or "none".
+func ParseColorLabel(name string) (ColorLabel, bool) {
+	if strings.EqualFold(name, "none") {
+		return LabelNone, true
+	}
+	for _, label := range ColorLabels {
+		if strings.EqualFold(name, string(label)) {
+			return label, true
+		}
+	}
+	return LabelNone, false
+}
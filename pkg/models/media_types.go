@@ -0,0 +1,359 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MediaKind is the broad kind of a media file, as stored in
+// MediaFile.FileType.
+type MediaKind string
+
+const (
+	KindImage   MediaKind = "image"
+	KindVideo   MediaKind = "video"
+	KindAudio   MediaKind = "audio"
+	KindUnknown MediaKind = "unknown"
+)
+
+// MediaType is a supported media format.
+type MediaType struct {
+	MIMEType   string
+	Kind       MediaKind
+	Extensions []string // lower case, with the leading dot
+	// Container groups formats sharing a container, e.g. "isobmff" for MP4,
+	// QuickTime and HEIC. Muxers often label such files with a sibling's
+	// signature, so a file stored under a sibling's extension of the same
+	// kind is not a mismatch.
+	Container string
+	// Sniff reports whether the first bytes of a file are in this format; nil
+	// if the format cannot be recognised by content
+	Sniff func(header []byte) bool
+}
+
+// SniffLen is how many leading bytes of a file content detection looks at.
+const SniffLen = 512
+
+// prefixSniffer recognises a format starting with one of prefixes.
+func prefixSniffer(prefixes ...string) func([]byte) bool {
+	return func(header []byte) bool {
+		for _, prefix := range prefixes {
+			if bytes.HasPrefix(header, []byte(prefix)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// riffSniffer recognises a RIFF container of the given form type, e.g. "WEBP".
+func riffSniffer(form string) func([]byte) bool {
+	return func(header []byte) bool {
+		return len(header) >= 12 && string(header[:4]) == "RIFF" && string(header[8:12]) == form
+	}
+}
+
+// bmpSniffer recognises a Windows bitmap. "BM" alone is too common a start
+// of text, so the reserved header fields must be zero as well.
+func bmpSniffer(header []byte) bool {
+	return len(header) >= 14 && string(header[:2]) == "BM" && string(header[6:10]) == "\x00\x00\x00\x00"
+}
+
+// ftypSniffer recognises an ISO base media file (MP4, QuickTime, HEIC, ...)
+// whose major brand is one of brands.
+func ftypSniffer(brands ...string) func([]byte) bool {
+	return func(header []byte) bool {
+		if len(header) < 12 || string(header[4:8]) != "ftyp" {
+			return false
+		}
+		for _, brand := range brands {
+			if string(header[8:12]) == brand {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ebmlSniffer recognises an EBML file, such as Matroska or WebM, of the given
+// document type.
+func ebmlSniffer(docType string) func([]byte) bool {
+	return func(header []byte) bool {
+		if !bytes.HasPrefix(header, []byte("\x1a\x45\xdf\xa3")) {
+			return false
+		}
+		// The DocType element (ID 0x4282) follows a few bytes into the header;
+		// its size is a one-byte variable length integer in practice
+		i := bytes.Index(header, []byte("\x42\x82"))
+		if i < 0 || i+3 > len(header) || header[i+2]&0x80 == 0 {
+			return false
+		}
+		size := int(header[i+2] & 0x7f)
+		start := i + 3
+		if start+size > len(header) {
+			return false
+		}
+		return string(bytes.TrimRight(header[start:start+size], "\x00")) == docType
+	}
+}
+
+// DefaultMediaTypes are the formats the library knows out of the box.
+var DefaultMediaTypes = []MediaType{
+	{MIMEType: "image/jpeg", Kind: KindImage, Extensions: []string{".jpg", ".jpeg", ".jpe"}, Sniff: prefixSniffer("\xff\xd8\xff")},
+	{MIMEType: "image/png", Kind: KindImage, Extensions: []string{".png"}, Sniff: prefixSniffer("\x89PNG\r\n\x1a\n")},
+	{MIMEType: "image/gif", Kind: KindImage, Extensions: []string{".gif"}, Sniff: prefixSniffer("GIF87a", "GIF89a")},
+	{MIMEType: "image/webp", Kind: KindImage, Extensions: []string{".webp"}, Container: "riff", Sniff: riffSniffer("WEBP")},
+	{MIMEType: "image/bmp", Kind: KindImage, Extensions: []string{".bmp"}, Sniff: bmpSniffer},
+	{MIMEType: "image/tiff", Kind: KindImage, Extensions: []string{".tif", ".tiff"}, Sniff: prefixSniffer("II*\x00", "MM\x00*")},
+	{MIMEType: "image/heic", Kind: KindImage, Extensions: []string{".heic"}, Container: "isobmff", Sniff: ftypSniffer("heic", "heix", "heim", "heis", "hevc", "hevx")},
+	{MIMEType: "image/heif", Kind: KindImage, Extensions: []string{".heif"}, Container: "isobmff", Sniff: ftypSniffer("mif1", "msf1")},
+	{MIMEType: "image/avif", Kind: KindImage, Extensions: []string{".avif"}, Container: "isobmff", Sniff: ftypSniffer("avif", "avis")},
+	{MIMEType: "video/mp4", Kind: KindVideo, Extensions: []string{".mp4"}, Container: "isobmff",
+		Sniff: ftypSniffer("isom", "iso2", "iso4", "iso5", "iso6", "mp41", "mp42", "avc1", "dash", "mmp4", "MSNV", "NDAS", "f4v ")},
+	{MIMEType: "video/x-m4v", Kind: KindVideo, Extensions: []string{".m4v"}, Container: "isobmff", Sniff: ftypSniffer("M4V ", "M4VH", "M4VP")},
+	{MIMEType: "video/quicktime", Kind: KindVideo, Extensions: []string{".mov"}, Container: "isobmff", Sniff: ftypSniffer("qt  ")},
+	{MIMEType: "video/x-msvideo", Kind: KindVideo, Extensions: []string{".avi"}, Container: "riff", Sniff: riffSniffer("AVI ")},
+	{MIMEType: "video/x-matroska", Kind: KindVideo, Extensions: []string{".mkv"}, Container: "matroska", Sniff: ebmlSniffer("matroska")},
+	{MIMEType: "video/webm", Kind: KindVideo, Extensions: []string{".webm"}, Container: "matroska", Sniff: ebmlSniffer("webm")},
+	{MIMEType: "video/3gpp", Kind: KindVideo, Extensions: []string{".3gp"}, Container: "isobmff", Sniff: ftypSniffer("3gp4", "3gp5", "3gp6", "3gg6", "3ge6", "3gs6")},
+	{MIMEType: "video/ogg", Kind: KindVideo, Extensions: []string{".ogv"}, Sniff: prefixSniffer("OggS")},
+	{MIMEType: "video/x-flv", Kind: KindVideo, Extensions: []string{".flv"}, Sniff: prefixSniffer("FLV\x01")},
+	{MIMEType: "video/x-ms-wmv", Kind: KindVideo, Extensions: []string{".wmv"}, Sniff: prefixSniffer("\x30\x26\xb2\x75\x8e\x66\xcf\x11")},
+	{MIMEType: "video/mp2t", Kind: KindVideo, Extensions: []string{".mts", ".m2ts"}},
+}
+
+// MediaTypeRegistry maps file extensions and contents to media types. It is
+// safe for concurrent use.
+type MediaTypeRegistry struct {
+	mu      sync.RWMutex
+	types   []MediaType
+	byExt   map[string]int // extension -> index into types
+	ignored map[string]bool
+}
+
+// NewMediaTypeRegistry returns a registry holding DefaultMediaTypes.
+func NewMediaTypeRegistry() *MediaTypeRegistry {
+	r := &MediaTypeRegistry{byExt: make(map[string]int), ignored: make(map[string]bool)}
+	for _, t := range DefaultMediaTypes {
+		r.Add(t)
+	}
+	return r
+}
+
+// MediaTypes is the registry the scanner, the preview generator and the UI
+// share.
+var MediaTypes = NewMediaTypeRegistry()
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// Add registers a media type. Its extensions take precedence over earlier
+// registrations of the same extensions.
+func (r *MediaTypeRegistry) Add(t MediaType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.Extensions = slices.Clone(t.Extensions)
+	for i, ext := range t.Extensions {
+		t.Extensions[i] = normalizeExt(ext)
+	}
+	r.types = append(r.types, t)
+	for _, ext := range t.Extensions {
+		r.byExt[ext] = len(r.types) - 1
+	}
+}
+
+// AddExtension maps another extension to mimeType. A MIME type the registry
+// does not know yet becomes a new media type, whose kind is taken from the
+// MIME type's major type.
+func (r *MediaTypeRegistry) AddExtension(ext, mimeType string) {
+	ext = normalizeExt(ext)
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if ext == "" || mimeType == "" {
+		return
+	}
+	r.mu.Lock()
+	for i := range r.types {
+		if r.types[i].MIMEType == mimeType {
+			r.types[i].Extensions = append(r.types[i].Extensions, ext)
+			r.byExt[ext] = i
+			r.mu.Unlock()
+			return
+		}
+	}
+	r.mu.Unlock()
+	r.Add(MediaType{MIMEType: mimeType, Kind: kindOfMIMEType(mimeType), Extensions: []string{ext}})
+}
+
+// Ignore excludes files with the given extension from the library, even if
+// their type is known.
+func (r *MediaTypeRegistry) Ignore(ext string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ignored[normalizeExt(ext)] = true
+}
+
+// Configure adds user extensions, as extension to MIME type, and ignored
+// extensions.
+func (r *MediaTypeRegistry) Configure(extensions map[string]string, ignored []string) {
+	for ext, mimeType := range extensions {
+		r.AddExtension(ext, mimeType)
+	}
+	for _, ext := range ignored {
+		r.Ignore(ext)
+	}
+}
+
+// ByExtension returns the media type of the file at path going by its
+// extension. Ignored extensions have no media type.
+func (r *MediaTypeRegistry) ByExtension(path string) (MediaType, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.ignored[ext] {
+		return MediaType{}, false
+	}
+	i, ok := r.byExt[ext]
+	if !ok {
+		return MediaType{}, false
+	}
+	return r.types[i], true
+}
+
+// ByContent returns the media type whose signature the leading bytes of a
+// file match.
+func (r *MediaTypeRegistry) ByContent(header []byte) (MediaType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.types {
+		if t.Sniff != nil && t.Sniff(header) {
+			return t, true
+		}
+	}
+	return MediaType{}, false
+}
+
+// ByFile returns the media type of the file at path going by its first
+// SniffLen bytes.
+func (r *MediaTypeRegistry) ByFile(path string) (MediaType, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return MediaType{}, false
+	}
+	defer f.Close()
+	header := make([]byte, SniffLen)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return MediaType{}, false
+	}
+	return r.ByContent(header[:n])
+}
+
+// Detection is what a file turned out to be going by its extension and by
+// its content. Either type is the zero MediaType if it is not known.
+type Detection struct {
+	ByExtension MediaType
+	ByContent   MediaType
+}
+
+// Type returns the type the file should be treated as: its content wins
+// over its extension.
+func (d Detection) Type() (MediaType, bool) {
+	if d.ByContent.MIMEType != "" {
+		return d.ByContent, true
+	}
+	return d.ByExtension, d.ByExtension.MIMEType != ""
+}
+
+// Mismatch reports whether the file's content contradicts its extension,
+// e.g. a JPEG named clip.mp4. Siblings of the same kind and container, such
+// as an MP4 named .mov, are not flagged.
+func (d Detection) Mismatch() bool {
+	ext, content := d.ByExtension, d.ByContent
+	if ext.MIMEType == "" || content.MIMEType == "" || ext.MIMEType == content.MIMEType {
+		return false
+	}
+	return ext.Kind != content.Kind || ext.Container == "" || ext.Container != content.Container
+}
+
+// Detect identifies the file at path by extension and by content. ok is
+// false if the file is not media: its type is unknown either way, or its
+// extension is ignored. To keep scans cheap, the content of files with an
+// extension the system knows to be something else, such as .txt, is not
+// read.
+func (r *MediaTypeRegistry) Detect(path string) (d Detection, ok bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	r.mu.RLock()
+	ignored := r.ignored[ext]
+	r.mu.RUnlock()
+	if ignored {
+		return d, false
+	}
+	byExt, known := r.ByExtension(path)
+	if !known && ext != "" && mime.TypeByExtension(ext) != "" {
+		return d, false
+	}
+	d.ByExtension = byExt
+	d.ByContent, _ = r.ByFile(path)
+	_, ok = d.Type()
+	return d, ok
+}
+
+// ByMIMEType returns the media type with the given MIME type.
+func (r *MediaTypeRegistry) ByMIMEType(mimeType string) (MediaType, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.types {
+		if t.MIMEType == mimeType {
+			return t, true
+		}
+	}
+	return MediaType{}, false
+}
+
+// IsMedia reports whether the file at path has a known, not ignored, type.
+func (r *MediaTypeRegistry) IsMedia(path string) bool {
+	_, ok := r.ByExtension(path)
+	return ok
+}
+
+// Kind returns the kind of the file at path, KindUnknown if its type is not
+// known.
+func (r *MediaTypeRegistry) Kind(path string) MediaKind {
+	if t, ok := r.ByExtension(path); ok {
+		return t.Kind
+	}
+	return KindUnknown
+}
+
+// MIMEType returns the MIME type of the file at path, falling back to the
+// system's MIME database and then to "application/octet-stream".
+func (r *MediaTypeRegistry) MIMEType(path string) string {
+	if t, ok := r.ByExtension(path); ok {
+		return t.MIMEType
+	}
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+func kindOfMIMEType(mimeType string) MediaKind {
+	major, _, _ := strings.Cut(mimeType, "/")
+	switch MediaKind(major) {
+	case KindImage, KindVideo, KindAudio:
+		return MediaKind(major)
+	}
+	return KindUnknown
+}
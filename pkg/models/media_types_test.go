@@ -0,0 +1,80 @@
+package models
+
+import "testing"
+
+func TestMediaTypeRegistry(t *testing.T) {
+	r := NewMediaTypeRegistry()
+
+	if kind := r.Kind("/photos/IMG_0001.JPG"); kind != KindImage {
+		t.Errorf("Expected an image, got %s", kind)
+	}
+	if mimeType := r.MIMEType("/videos/clip.mkv"); mimeType != "video/x-matroska" {
+		t.Errorf("Expected video/x-matroska, got %s", mimeType)
+	}
+	if r.IsMedia("/code/main.ts") || r.IsMedia("/notes.txt") {
+		t.Errorf("Expected source files and text not to be media")
+	}
+
+	t.Run("content", func(t *testing.T) {
+		cases := map[string]string{
+			"\xff\xd8\xff\xe0\x00\x10JFIF":                             "image/jpeg",
+			"\x89PNG\r\n\x1a\n\x00\x00":                                "image/png",
+			"RIFF\x10\x00\x00\x00WEBPVP8 ":                             "image/webp",
+			"RIFF\x10\x00\x00\x00AVI LIST":                             "video/x-msvideo",
+			"\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic":         "image/heic",
+			"\x00\x00\x00\x20ftypisom\x00\x00\x02\x00isomiso2":         "video/mp4",
+			"\x00\x00\x00\x14ftypqt  \x20\x05\x03\x00qt  ":             "video/quicktime",
+			"\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm":     "video/webm",
+			"\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\x82\x88matroska": "video/x-matroska",
+		}
+		for header, expected := range cases {
+			if mediaType, ok := r.ByContent([]byte(header)); !ok || mediaType.MIMEType != expected {
+				t.Errorf("Expected %q to be %s, got %+v", header, expected, mediaType)
+			}
+		}
+		for _, text := range []string{"plain text", "BMW service notes"} {
+			if _, ok := r.ByContent([]byte(text)); ok {
+				t.Errorf("Expected %q not to match any signature", text)
+			}
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		jpeg, _ := r.ByMIMEType("image/jpeg")
+		mp4, _ := r.ByMIMEType("video/mp4")
+		mov, _ := r.ByMIMEType("video/quicktime")
+		heic, _ := r.ByMIMEType("image/heic")
+		cases := []struct {
+			detection Detection
+			mismatch  bool
+		}{
+			{Detection{ByExtension: mp4, ByContent: jpeg}, true},
+			{Detection{ByExtension: mp4, ByContent: heic}, true},
+			{Detection{ByExtension: mov, ByContent: mp4}, false},
+			{Detection{ByExtension: jpeg, ByContent: jpeg}, false},
+			{Detection{ByExtension: jpeg}, false},
+		}
+		for _, c := range cases {
+			if got := c.detection.Mismatch(); got != c.mismatch {
+				t.Errorf("Expected mismatch %v for %s content named as %s, got %v",
+					c.mismatch, c.detection.ByContent.MIMEType, c.detection.ByExtension.MIMEType, got)
+			}
+		}
+		if mediaType, ok := (Detection{ByExtension: mp4, ByContent: jpeg}).Type(); !ok || mediaType.Kind != KindImage {
+			t.Errorf("Expected the content to decide the type, got %+v", mediaType)
+		}
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		r.Configure(map[string]string{"jfif": "image/jpeg", ".cr2": "image/x-canon-cr2"}, []string{".gif"})
+		if mediaType, ok := r.ByExtension("scan.JFIF"); !ok || mediaType.MIMEType != "image/jpeg" {
+			t.Errorf("Expected a user extension to map to a known type, got %+v", mediaType)
+		}
+		if kind := r.Kind("raw.cr2"); kind != KindImage {
+			t.Errorf("Expected a new image type for a user MIME type, got %s", kind)
+		}
+		if r.IsMedia("animation.gif") {
+			t.Errorf("Expected ignored extensions not to be media")
+		}
+	})
+}
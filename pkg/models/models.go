@@ -5,26 +5,94 @@ import (
 )
 
 type MediaFile struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Path        string    `json:"path" gorm:"uniqueIndex"`
-	Filename    string    `json:"filename"`
-	Size        int64     `json:"size"`
-	ModTime     time.Time `json:"mod_time"`
-	FileType    string    `json:"file_type"` // image, video
-	MimeType    string    `json:"mime_type"`
-	PreviewPath string    `json:"preview_path"`
-	Width       int       `json:"width"`
-	Height      int       `json:"height"`
-	Duration    int       `json:"duration"` // for videos, in seconds
-	Tags        []Tag     `json:"tags" gorm:"many2many:file_tags;"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Path           string         `json:"path" gorm:"uniqueIndex"`
+	Filename       string         `json:"filename"`
+	Size           int64          `json:"size"`
+	ModTime        time.Time      `json:"mod_time"`
+	FileType       string         `json:"file_type"`                  // image, video
+	MimeType       string         `json:"mime_type"`                  // going by the extension
+	DetectedType   string         `json:"detected_type"`              // MIME type going by the content; empty if unrecognised
+	TypeMismatch   bool           `json:"type_mismatch" gorm:"index"` // the content contradicts the extension
+	PreviewPath    string         `json:"preview_path"`
+	Width          int            `json:"width"`
+	Height         int            `json:"height"`
+	Duration       int            `json:"duration"`                  // for videos, in seconds
+	VideoCodec     string         `json:"video_codec"`               // e.g. h264, hevc
+	AudioCodec     string         `json:"audio_codec"`               // e.g. aac, opus
+	Bitrate        int64          `json:"bitrate"`                   // overall bits per second
+	FrameRate      float64        `json:"frame_rate"`                // frames per second
+	Rotation       int            `json:"rotation"`                  // clockwise degrees; Width and Height are already rotated
+	ProbedAt       *time.Time     `json:"probed_at,omitempty"`       // when the metadata above was last read; nil until then
+	Missing        bool           `json:"missing" gorm:"index"`      // file vanished from disk since the last scan
+	Fingerprint    string         `json:"fingerprint" gorm:"index"`  // hash of size, first and last 64 KiB
+	ContentHash    string         `json:"content_hash" gorm:"index"` // full SHA-256, computed for duplicate candidates
+	PerceptualHash *int64         `json:"perceptual_hash,omitempty"` // dHash of the image or sampled video frames; nil until previewed
+	Notes          string         `json:"notes"`                     // user description, searched along with the name
+	Rating         int            `json:"rating" gorm:"index"`       // 0 to MaxRating stars, 0 is unrated
+	Favorite       bool           `json:"favorite" gorm:"index"`
+	Flag           Flag           `json:"flag" gorm:"index"`        // pick or reject
+	ColorLabel     ColorLabel     `json:"color_label" gorm:"index"` // empty for none
+	Tags           []Tag          `json:"tags" gorm:"many2many:file_tags;"`
+	Metadata       *MediaMetadata `json:"metadata,omitempty" gorm:"foreignKey:MediaFileID"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
 }
 
+// MediaMetadata holds the EXIF data of a photo. Only photos that carry EXIF
+// have a row.
+type MediaMetadata struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	MediaFileID  uint       `json:"media_file_id" gorm:"uniqueIndex"`
+	CameraMake   string     `json:"camera_make"`
+	CameraModel  string     `json:"camera_model" gorm:"index"`
+	LensModel    string     `json:"lens_model"`
+	ExposureTime float64    `json:"exposure_time"` // seconds
+	FNumber      float64    `json:"f_number"`
+	ISO          int        `json:"iso"`
+	FocalLength  float64    `json:"focal_length"` // millimetres
+	Orientation  int        `json:"orientation"`  // EXIF orientation, 1 is upright
+	CapturedAt   *time.Time `json:"captured_at" gorm:"index"`
+	Latitude     *float64   `json:"latitude"`
+	Longitude    *float64   `json:"longitude"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Tag is a label attached to media files. Tags form a hierarchy through
+// ParentID ("Places/Paris/Louvre"); names are unique among siblings.
 type Tag struct {
-	ID    uint   `json:"id" gorm:"primaryKey"`
-	Name  string `json:"name" gorm:"uniqueIndex"`
-	Color string `json:"color"` // hex color for UI
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"not null"`
+	ParentID *uint  `json:"parent_id,omitempty" gorm:"index"` // nil for top-level tags
+	Color    string `json:"color"`                            // hex color for UI
+}
+
+// SmartAlbum is a saved search, shown in the sidebar with the number of
+// files currently matching it.
+type SmartAlbum struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null"`
+	Query     string    `json:"query"` // in the query language of the filter box
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Album is a hand-picked collection of media files from any folders, kept
+// in the user's order without moving the files on disk.
+type Album struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlbumItem places a media file in an album. Items are shown in ascending
+// Position.
+type AlbumItem struct {
+	AlbumID     uint `json:"album_id" gorm:"primaryKey"`
+	MediaFileID uint `json:"media_file_id" gorm:"primaryKey;index"`
+	Position    int  `json:"position"`
 }
 
 type Folder struct {
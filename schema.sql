@@ -8,23 +8,88 @@ CREATE TABLE media_files (
     size INTEGER NOT NULL,
     mod_time DATETIME NOT NULL,
     file_type TEXT NOT NULL, -- 'image' or 'video'
-    mime_type TEXT NOT NULL,
+    mime_type TEXT NOT NULL, -- going by the extension
+    detected_type TEXT, -- MIME type going by the content; empty if unrecognised
+    type_mismatch BOOLEAN DEFAULT 0, -- the content contradicts the extension
     preview_path TEXT,
     width INTEGER,
     height INTEGER,
     duration INTEGER, -- for videos, in seconds
+    video_codec TEXT,
+    audio_codec TEXT,
+    bitrate INTEGER, -- bits per second
+    frame_rate REAL,
+    rotation INTEGER, -- clockwise degrees; width/height are already rotated
+    probed_at DATETIME, -- when the metadata was last read, even if none was found
+    missing BOOLEAN DEFAULT 0, -- file vanished from disk since the last scan
+    fingerprint TEXT, -- SHA-256 of size, first and last 64 KiB; used to track moves
+    content_hash TEXT, -- full SHA-256; computed for duplicate candidates
+    perceptual_hash INTEGER, -- 64-bit dHash for near-duplicate search; set with the preview
+    notes TEXT, -- user description, searched along with the name
+    rating INTEGER DEFAULT 0, -- 0 to 5 stars, 0 is unrated
+    favorite BOOLEAN DEFAULT 0,
+    flag INTEGER DEFAULT 0, -- 1 pick, -1 reject, 0 none
+    color_label TEXT DEFAULT '', -- red, yellow, green, blue or purple; empty for none
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+-- EXIF metadata of photos; only photos carrying EXIF have a row
+CREATE TABLE media_metadata (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    media_file_id INTEGER UNIQUE NOT NULL,
+    camera_make TEXT,
+    camera_model TEXT,
+    lens_model TEXT,
+    exposure_time REAL, -- seconds
+    f_number REAL,
+    iso INTEGER,
+    focal_length REAL, -- millimetres
+    orientation INTEGER, -- EXIF orientation, 1 is upright
+    captured_at DATETIME,
+    latitude REAL,
+    longitude REAL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (media_file_id) REFERENCES media_files(id) ON DELETE CASCADE
+);
+
 -- Tags table
 CREATE TABLE tags (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
-    name TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    parent_id INTEGER, -- NULL for top-level tags
     color TEXT DEFAULT '#007bff', -- hex color for UI
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Saved searches shown as smart albums in the sidebar
+CREATE TABLE smart_albums (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    query TEXT, -- in the query language of the filter box
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Hand-picked collections of files from any folders
+CREATE TABLE albums (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Ordered many-to-many relationship between albums and files
+CREATE TABLE album_items (
+    album_id INTEGER NOT NULL,
+    media_file_id INTEGER NOT NULL,
+    position INTEGER NOT NULL, -- items are shown in ascending position
+    PRIMARY KEY (album_id, media_file_id),
+    FOREIGN KEY (album_id) REFERENCES albums(id) ON DELETE CASCADE,
+    FOREIGN KEY (media_file_id) REFERENCES media_files(id) ON DELETE CASCADE
+);
+
 -- Many-to-many relationship between files and tags
 CREATE TABLE file_tags (
     file_id INTEGER NOT NULL,
@@ -49,6 +114,28 @@ CREATE TABLE folders (
 CREATE INDEX idx_media_files_type ON media_files(file_type);
 CREATE INDEX idx_media_files_mod_time ON media_files(mod_time);
 CREATE INDEX idx_media_files_size ON media_files(size);
+CREATE INDEX idx_media_metadata_camera_model ON media_metadata(camera_model);
+CREATE INDEX idx_media_metadata_captured_at ON media_metadata(captured_at);
+CREATE INDEX idx_media_files_missing ON media_files(missing);
+CREATE INDEX idx_media_files_fingerprint ON media_files(fingerprint);
+CREATE INDEX idx_media_files_content_hash ON media_files(content_hash);
+CREATE INDEX idx_media_files_type_mismatch ON media_files(type_mismatch);
+CREATE INDEX idx_media_files_rating ON media_files(rating);
+CREATE INDEX idx_media_files_favorite ON media_files(favorite);
+CREATE INDEX idx_media_files_flag ON media_files(flag);
+CREATE INDEX idx_media_files_color_label ON media_files(color_label);
+CREATE INDEX idx_tags_parent_id ON tags(parent_id);
+-- Tag names are unique among siblings; top-level tags have parent 0
+CREATE UNIQUE INDEX idx_tags_parent_name ON tags(COALESCE(parent_id, 0), name);
 CREATE INDEX idx_file_tags_file_id ON file_tags(file_id);
 CREATE INDEX idx_file_tags_tag_id ON file_tags(tag_id);
-CREATE INDEX idx_folders_path ON folders(path);
\ No newline at end of file
+CREATE INDEX idx_folders_path ON folders(path);
+CREATE INDEX idx_album_items_media_file_id ON album_items(media_file_id);
+
+-- Full-text index of file names, folders, tag names and notes (rowid = media_files.id);
+-- needs SQLite with FTS5 (go-sqlite3 built with -tags sqlite_fts5) and is kept
+-- up to date by triggers, see internal/db/fts.go
+CREATE VIRTUAL TABLE media_search USING fts5(
+    filename, folders, tags, notes,
+    tokenize = 'unicode61 remove_diacritics 2'
+);